@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// alarmFields maps the field names usable in alarm rules to their value and
+// unit in a reading
+var alarmFields = map[string]struct {
+	unit  string
+	value func(*tc66c.Reading) float64
+}{
+	"voltage":     {"V", func(r *tc66c.Reading) float64 { return r.Voltage }},
+	"current":     {"A", func(r *tc66c.Reading) float64 { return r.Current }},
+	"power":       {"W", func(r *tc66c.Reading) float64 { return r.Power }},
+	"resistance":  {"Ω", func(r *tc66c.Reading) float64 { return r.Resistance }},
+	"temperature": {"°C", func(r *tc66c.Reading) float64 { return r.Temperature }},
+	"dplus":       {"V", func(r *tc66c.Reading) float64 { return r.DPlusVoltage }},
+	"dminus":      {"V", func(r *tc66c.Reading) float64 { return r.DMinusVoltage }},
+	"mah":         {"mAh", func(r *tc66c.Reading) float64 { return float64(r.Group0MAh) }},
+	"mwh":         {"mWh", func(r *tc66c.Reading) float64 { return float64(r.Group0MWh) }},
+}
+
+// alarmOperators lists the comparison operators of alarm rules, two
+// character ones first so ">=" is not read as ">"
+var alarmOperators = []string{">=", "<=", ">", "<"}
+
+// AlarmRule is a threshold on a reading field, e.g. "voltage>5.5"
+type AlarmRule struct {
+	Field     string
+	Operator  string
+	Threshold float64
+}
+
+// parseAlarmRule parses a rule of the form <field><operator><threshold>
+func parseAlarmRule(rule string) (*AlarmRule, error) {
+	for _, op := range alarmOperators {
+		field, threshold, found := strings.Cut(rule, op)
+		if !found {
+			continue
+		}
+
+		field = strings.ToLower(strings.TrimSpace(field))
+		if _, ok := alarmFields[field]; !ok {
+			return nil, fmt.Errorf("invalid alarm %q: unknown field %q (available: %s)", rule, field, strings.Join(alarmFieldNames(), ", "))
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(threshold), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alarm %q: threshold is not a number", rule)
+		}
+
+		return &AlarmRule{Field: field, Operator: op, Threshold: value}, nil
+	}
+
+	return nil, fmt.Errorf("invalid alarm %q: expected <field><op><value> with op one of %s", rule, strings.Join(alarmOperators, " "))
+}
+
+// alarmFieldNames returns the sorted field names usable in alarm rules
+func alarmFieldNames() []string {
+	names := make([]string, 0, len(alarmFields))
+	for name := range alarmFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the rule in the form it is written on the command line
+func (r *AlarmRule) String() string {
+	return r.Field + r.Operator + strconv.FormatFloat(r.Threshold, 'f', -1, 64)
+}
+
+// Value returns the value of the rule's field in a reading
+func (r *AlarmRule) Value(reading *tc66c.Reading) float64 {
+	return alarmFields[r.Field].value(reading)
+}
+
+// Check reports whether a reading breaks the rule
+func (r *AlarmRule) Check(reading *tc66c.Reading) bool {
+	value := r.Value(reading)
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	default:
+		return value <= r.Threshold
+	}
+}
+
+// AlarmEvent is an alarm being raised or cleared
+type AlarmEvent struct {
+	Rule   *AlarmRule
+	Raised bool
+	Value  float64
+}
+
+// String describes the event, e.g. "ALARM voltage>5.5: 5.612 V"
+func (e AlarmEvent) String() string {
+	state := "ALARM"
+	if !e.Raised {
+		state = "CLEARED"
+	}
+	return fmt.Sprintf("%s %s: %.3f %s", state, e.Rule, e.Value, alarmFields[e.Rule.Field].unit)
+}
+
+// AlarmMonitor evaluates rules on every reading and reports only changes,
+// so a rule broken for many readings is raised once and cleared once
+type AlarmMonitor struct {
+	rules  []*AlarmRule
+	active []bool
+}
+
+// NewAlarmMonitor parses the given rules
+func NewAlarmMonitor(rules []string) (*AlarmMonitor, error) {
+	monitor := &AlarmMonitor{}
+	for _, rule := range rules {
+		parsed, err := parseAlarmRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		monitor.rules = append(monitor.rules, parsed)
+	}
+	monitor.active = make([]bool, len(monitor.rules))
+	return monitor, nil
+}
+
+// Evaluate checks a reading against every rule and returns the alarms
+// raised or cleared by it
+func (m *AlarmMonitor) Evaluate(reading *tc66c.Reading) []AlarmEvent {
+	var events []AlarmEvent
+	for i, rule := range m.rules {
+		broken := rule.Check(reading)
+		if broken != m.active[i] {
+			m.active[i] = broken
+			events = append(events, AlarmEvent{Rule: rule, Raised: broken, Value: rule.Value(reading)})
+		}
+	}
+	return events
+}
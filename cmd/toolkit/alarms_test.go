@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+func TestParseAlarmRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want AlarmRule
+	}{
+		{"voltage>5.5", AlarmRule{Field: "voltage", Operator: ">", Threshold: 5.5}},
+		{"Current >= 3", AlarmRule{Field: "current", Operator: ">=", Threshold: 3}},
+		{"temperature<-5", AlarmRule{Field: "temperature", Operator: "<", Threshold: -5}},
+		{"mah<=100", AlarmRule{Field: "mah", Operator: "<=", Threshold: 100}},
+	}
+	for _, tt := range tests {
+		rule, err := parseAlarmRule(tt.rule)
+		if err != nil || *rule != tt.want {
+			t.Errorf("parseAlarmRule(%q) = %+v, %v, want %+v", tt.rule, rule, err, tt.want)
+		}
+	}
+
+	for rule, wantErr := range map[string]string{
+		"voltage=5":  "expected <field><op><value>",
+		"volts>5":    `unknown field "volts"`,
+		"voltage>":   "not a number",
+		"power>high": "not a number",
+	} {
+		if _, err := parseAlarmRule(rule); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("parseAlarmRule(%q) error = %v, want it to contain %q", rule, err, wantErr)
+		}
+	}
+}
+
+func TestAlarmMonitorEdges(t *testing.T) {
+	monitor, err := NewAlarmMonitor([]string{"voltage>5.5", "current<=0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		voltage, current float64
+		want             []string
+	}{
+		{5.0, 1, nil},
+		{5.6, 1, []string{"ALARM voltage>5.5: 5.600 V"}},
+		{5.7, 1, nil},
+		{5.7, 0.1, []string{"ALARM current<=0.1: 0.100 A"}},
+		{5.0, 2, []string{"CLEARED voltage>5.5: 5.000 V", "CLEARED current<=0.1: 2.000 A"}},
+	}
+	for i, step := range steps {
+		var got []string
+		for _, event := range monitor.Evaluate(&tc66c.Reading{Voltage: step.voltage, Current: step.current}) {
+			got = append(got, event.String())
+		}
+		if strings.Join(got, "|") != strings.Join(step.want, "|") {
+			t.Errorf("step %d: events %q, want %q", i, got, step.want)
+		}
+	}
+}
+
+func TestReadingOutputs(t *testing.T) {
+	dir := t.TempDir()
+	jsonlPath := filepath.Join(dir, "readings.jsonl")
+	csvPath := filepath.Join(dir, "readings.csv")
+
+	var logged []string
+	logf := func(format string, args ...any) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Sinks are appended to, and the CSV header is written once
+	for range 2 {
+		outputs, err := newReadingOutputs([]string{"jsonl:" + jsonlPath, "csv:" + csvPath}, []string{"voltage>5"}, logf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs.Handle("/dev/ttyA", at, &tc66c.Reading{Voltage: 5.1, Current: 0.5})
+		outputs.Handle("/dev/ttyB", at, &tc66c.Reading{Voltage: 4.9})
+		outputs.Close()
+	}
+	if len(logged) != 2 || logged[0] != "ALARM voltage>5: 5.100 V on /dev/ttyA" {
+		t.Errorf("logged %q, want one alarm per session for /dev/ttyA", logged)
+	}
+
+	file, err := os.Open(jsonlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []jsonlRecord
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var record jsonlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("jsonl line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 4 || records[0].Port != "/dev/ttyA" || records[0].Reading.Voltage != 5.1 || !records[0].Time.Equal(at) {
+		t.Errorf("jsonl records = %+v", records)
+	}
+
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "time,port,") || !strings.HasPrefix(lines[1], "2026-01-02T03:04:05Z,/dev/ttyA,0,5.1,0.5,") {
+		t.Errorf("csv = %q", lines)
+	}
+
+	for spec, wantErr := range map[string]string{
+		"readings.jsonl":      "expected <format>:<path>",
+		"xml:" + jsonlPath:    `unknown format "xml"`,
+		"csv:" + dir + "/x/y": "failed to open sink",
+	} {
+		if _, err := newReadingOutputs([]string{spec}, nil, logf); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("sink %q error = %v, want it to contain %q", spec, err, wantErr)
+		}
+	}
+	if _, err := newReadingOutputs(nil, []string{"voltage"}, logf); err == nil {
+		t.Error("an invalid alarm rule was accepted")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchmarkSamplesFlag  int
+	benchmarkIntervalFlag time.Duration
+	benchmarkJSONFlag     bool
+)
+
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark",
+	Short: "Measure how fast and reliably the device can be polled",
+	Long: `Takes readings as fast as possible (or at --interval) and reports the
+achieved sample rate, reading latency percentiles and error rate, along with
+the fastest polling interval that leaves the meter idle between readings.
+
+Retries are disabled while benchmarking so every lost reading is counted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		device := connectMeterDirect(portFlag, nil)
+		defer device.Close()
+		executeBenchmark(device, benchmarkSamplesFlag, benchmarkIntervalFlag, benchmarkJSONFlag)
+	},
+}
+
+func init() {
+	benchmarkCmd.Flags().IntVarP(&benchmarkSamplesFlag, "samples", "n", 200, "Number of readings to take")
+	benchmarkCmd.Flags().DurationVarP(&benchmarkIntervalFlag, "interval", "i", 0, "Interval between readings (0 for back to back)")
+	benchmarkCmd.Flags().BoolVarP(&benchmarkJSONFlag, "json", "j", false, "Output in JSON format")
+	rootCmd.AddCommand(benchmarkCmd)
+}
+
+// BenchmarkLatency holds reading latency percentiles in milliseconds
+type BenchmarkLatency struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// BenchmarkResult is the outcome of a benchmark run
+type BenchmarkResult struct {
+	Port              string           `json:"port"`
+	Samples           int              `json:"samples"`
+	Errors            int              `json:"errors"`
+	Timeouts          int              `json:"timeouts"`
+	BadPackets        int              `json:"bad_packets"`
+	Resyncs           uint64           `json:"resyncs"`
+	ErrorRate         float64          `json:"error_rate"` // Fraction of readings lost
+	DurationSeconds   float64          `json:"duration_seconds"`
+	SamplesPerSecond  float64          `json:"samples_per_second"` // Successful readings per second
+	LatencyMs         BenchmarkLatency `json:"latency_ms"`
+	FastestIntervalMs int64            `json:"fastest_interval_ms"`
+}
+
+// String returns a human readable report of the benchmark
+func (r *BenchmarkResult) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Samples:          %d (%d ok, %d errors: %d timeouts, %d bad packets)\n",
+		r.Samples, r.Samples-r.Errors, r.Errors, r.Timeouts, r.BadPackets)
+	fmt.Fprintf(&sb, "Error rate:       %.2f%%\n", r.ErrorRate*100)
+	fmt.Fprintf(&sb, "Resyncs:          %d\n", r.Resyncs)
+	fmt.Fprintf(&sb, "Duration:         %.2fs\n", r.DurationSeconds)
+	fmt.Fprintf(&sb, "Rate:             %.1f samples/s\n", r.SamplesPerSecond)
+	fmt.Fprintf(&sb, "Latency:          min %.1fms  p50 %.1fms  p90 %.1fms  p99 %.1fms  max %.1fms\n",
+		r.LatencyMs.Min, r.LatencyMs.P50, r.LatencyMs.P90, r.LatencyMs.P99, r.LatencyMs.Max)
+	fmt.Fprintf(&sb, "Fastest interval: %dms", r.FastestIntervalMs)
+
+	return sb.String()
+}
+
+// executeBenchmark takes readings from the device and reports how it coped
+func executeBenchmark(device tc66c.Meter, samples int, interval time.Duration, jsonOutput bool) {
+	if samples < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --samples must be at least 1\n")
+		os.Exit(1)
+	}
+
+	// Count every lost reading instead of hiding it behind a retry
+	policy := retryPolicy()
+	policy.Attempts = 1
+	setRetryPolicy(device, policy)
+
+	if interval > 0 {
+		fmt.Fprintf(os.Stderr, "Taking %d readings every %v...\n", samples, interval)
+	} else {
+		fmt.Fprintf(os.Stderr, "Taking %d readings back to back...\n", samples)
+	}
+
+	result := &BenchmarkResult{Port: device.PortName(), Samples: samples}
+	latencies := make([]time.Duration, 0, samples)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	readings, errs := tc66c.Stream(ctx, device, tc66c.StreamOptions{
+		Interval:     interval,
+		Backpressure: tc66c.BackpressureBlock,
+	})
+	for taken := 0; taken < samples; {
+		select {
+		case sample, ok := <-readings:
+			if !ok {
+				// The stream ended on an error, which is still to be read
+				readings = nil
+				continue
+			}
+			latencies = append(latencies, sample.Latency)
+			taken++
+		case err := <-errs:
+			taken++
+			switch {
+			case errors.Is(err, tc66c.ErrDisconnected), errors.Is(err, tc66c.ErrWrongMode):
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			case errors.Is(err, tc66c.ErrTimeout):
+				result.Errors++
+				result.Timeouts++
+			case errors.Is(err, tc66c.ErrBadPacket):
+				result.Errors++
+				result.BadPackets++
+			default:
+				result.Errors++
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	cancel()
+
+	sorted := sortedDurations(latencies)
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	result.Resyncs = device.Stats().Resyncs
+	result.ErrorRate = float64(result.Errors) / float64(samples)
+	result.DurationSeconds = elapsed.Seconds()
+	result.SamplesPerSecond = float64(len(latencies)) / elapsed.Seconds()
+	if len(sorted) > 0 {
+		result.LatencyMs = BenchmarkLatency{
+			Min: ms(sorted[0]),
+			P50: ms(percentile(sorted, 50)),
+			P90: ms(percentile(sorted, 90)),
+			P99: ms(percentile(sorted, 99)),
+			Max: ms(sorted[len(sorted)-1]),
+		}
+		result.FastestIntervalMs = fastestInterval(sorted).Milliseconds()
+	}
+
+	if jsonOutput {
+		data, err := json.Marshal(result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Fprintln(os.Stderr)
+		fmt.Println(result.String())
+	}
+
+	if len(sorted) == 0 {
+		fmt.Fprintf(os.Stderr, "\nWarning: no reading succeeded\n")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonIntervalFlag time.Duration
+	daemonHistoryFlag  int
+	daemonRescanFlag   time.Duration
+	daemonPortsFlag    []string
+	daemonAlarmFlag    []string
+	daemonSinkFlag     []string
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Own all attached meters and share them over a local control socket",
+	Long: `Run in the foreground, holding every attached meter open and polling it.
+The REST API of the web server is served on a Unix domain socket, and get,
+poll, recording and screen talk to the daemon through it instead of opening
+the serial port, so several processes can use the same meter. Commands that
+need the port for themselves refuse to run while the daemon holds it.
+
+Only USB ports with the TC66C identity (0483:5740) are opened, plus the
+ports given with --ports, so other serial devices are never probed. UM
+meters, which use Bluetooth serial ports, are only taken from --ports.
+Ports that fail to open are retried on later rescans, with backoff.
+
+Readings of every meter can be stored with --sink and checked with --alarm,
+as in poll. Alarms are logged.
+
+The daemon notifies systemd when it is ready (Type=notify) and reloads the
+config file and rescans the serial ports on SIGHUP.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		executeDaemon(cmd)
+	},
+}
+
+func init() {
+	daemonCmd.Flags().DurationVarP(&daemonIntervalFlag, "interval", "i", time.Second, "Background polling interval for every meter")
+	daemonCmd.Flags().IntVar(&daemonHistoryFlag, "history", 10000, "Number of readings kept in memory per meter (0 disables history)")
+	daemonCmd.Flags().DurationVar(&daemonRescanFlag, "rescan", 10*time.Second, "Interval between scans for new meters (0 only scans on start and SIGHUP)")
+	daemonCmd.Flags().StringSliceVar(&daemonPortsFlag, "ports", nil, "Serial ports to open besides the TC66C USB ports, e.g. /dev/rfcomm0 (repeatable)")
+	daemonCmd.Flags().StringSliceVar(&daemonAlarmFlag, "alarm", nil, "Log when a reading matches a rule, e.g. voltage>5.5 (repeatable)")
+	daemonCmd.Flags().StringSliceVar(&daemonSinkFlag, "sink", nil, "Store every reading in a file, as jsonl:<path> or csv:<path> (repeatable)")
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// meterDaemon keeps a background subscription to every attached meter
+type meterDaemon struct {
+	broker   *DeviceBroker
+	interval time.Duration
+
+	meters map[string]*Subscription
+	// ignored holds ports without a usable meter, e.g. one in bootloader
+	// mode or held by another program. They are probed again with backoff,
+	// and right away when they disappear and come back or on SIGHUP
+	ignored map[string]*ignoredPort
+
+	// outputs receives every reading, and is replaced when the config is
+	// reloaded
+	outputs atomic.Pointer[readingOutputs]
+
+	// inventoryMu serialises inventory updates from the subscriptions
+	inventoryMu sync.Mutex
+}
+
+func executeDaemon(cmd *cobra.Command) {
+	if daemonHistoryFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --history must be 0 or more, got %d\n", daemonHistoryFlag)
+		os.Exit(1)
+	}
+
+	outputs, err := newReadingOutputs(daemonSinkFlag, daemonAlarmFlag, log.Printf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	socketPath := daemonSocketPath()
+	listener, err := listenDaemonSocket(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.Remove(socketPath)
+
+	d := &meterDaemon{
+		broker:   NewDeviceBroker(daemonHistoryFlag),
+		interval: daemonIntervalFlag,
+		meters:   make(map[string]*Subscription),
+		ignored:  make(map[string]*ignoredPort),
+	}
+	d.outputs.Store(outputs)
+
+	mux := http.NewServeMux()
+	registerAPI(mux, d.broker)
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Control socket failed: %v", err)
+		}
+	}()
+
+	log.Printf("Listening on %s", socketPath)
+	if err := requireTC66C(); err != nil && len(daemonPortsFlag) == 0 {
+		log.Printf("No --ports given, %ss are only opened from --ports", meterName())
+	}
+
+	d.scan()
+	d.notifyReady()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+
+	var rescan <-chan time.Time
+	if daemonRescanFlag > 0 {
+		ticker := time.NewTicker(daemonRescanFlag)
+		defer ticker.Stop()
+		rescan = ticker.C
+	}
+
+	for {
+		select {
+		case <-rescan:
+			d.scan()
+
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				sdNotify(fmt.Sprintf("RELOADING=1\nMONOTONIC_USEC=%d", monotonicMicroseconds()))
+				log.Printf("Reloading configuration")
+
+				if err := applyConfig(cmd); err != nil {
+					log.Printf("Failed to reload configuration, keeping the current one: %v", err)
+				} else {
+					d.setInterval(daemonIntervalFlag)
+					d.reopenOutputs()
+				}
+
+				clear(d.ignored)
+				d.scan()
+				d.notifyReady()
+				continue
+			}
+
+			sdNotify("STOPPING=1")
+			log.Printf("Shutting down")
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			server.Shutdown(ctx)
+			cancel()
+
+			for port, sub := range d.meters {
+				sub.Unsubscribe()
+				delete(d.meters, port)
+			}
+			d.outputs.Load().Close()
+			return
+		}
+	}
+}
+
+// listenDaemonSocket listens on the control socket, replacing a stale
+// socket file left by a daemon that did not exit cleanly
+func listenDaemonSocket(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, 200*time.Millisecond); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	// Only the user running the daemon may control the meters
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
+
+// candidates returns the ports the daemon may open: the TC66C USB ports
+// when polling TC66C meters, and the ports listed with --ports. Listed
+// ports are only present while their device file exists
+func candidates(ports []SerialPortInfo) []string {
+	var names []string
+	if model, err := tc66c.ParseModel(meterFlag); err == nil && model == tc66c.ModelTC66C {
+		for _, port := range tc66cPorts(ports) {
+			names = append(names, port.Name)
+		}
+	}
+	for _, name := range daemonPortsFlag {
+		if _, err := os.Stat(name); err == nil && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scan drops meters whose port has disappeared and takes every new
+// candidate port that has a meter in firmware mode
+func (d *meterDaemon) scan() {
+	ports, err := listSerialPorts()
+	if err != nil {
+		log.Printf("Scan failed: %v", err)
+		return
+	}
+
+	names := candidates(ports)
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+
+	for port, sub := range d.meters {
+		if !present[port] {
+			sub.Unsubscribe()
+			delete(d.meters, port)
+			log.Printf("Meter on %s disconnected", port)
+		}
+	}
+	for port := range d.ignored {
+		if !present[port] {
+			delete(d.ignored, port)
+		}
+	}
+
+	now := time.Now()
+	for _, name := range names {
+		ignored := d.ignored[name]
+		if d.meters[name] != nil || (ignored != nil && now.Before(ignored.retryAt)) {
+			continue
+		}
+
+		sub, _, err := d.broker.Subscribe(name, d.interval)
+		if err != nil {
+			ignored = ignored.retry(now)
+			d.ignored[name] = ignored
+			log.Printf("Ignoring %s: %v (retrying in %v)", name, err, ignored.backoff)
+			continue
+		}
+		delete(d.ignored, name)
+
+		d.track(name, sub)
+		log.Printf("Meter on %s connected", name)
+	}
+}
+
+// Backoff between attempts to open an ignored port
+const (
+	ignoredRetryMin = 10 * time.Second
+	ignoredRetryMax = 5 * time.Minute
+)
+
+// ignoredPort is a candidate port whose meter could not be opened
+type ignoredPort struct {
+	retryAt time.Time
+	backoff time.Duration
+}
+
+// retry returns the state of a port after another failed attempt at now.
+// The backoff starts at ignoredRetryMin and doubles up to ignoredRetryMax
+func (p *ignoredPort) retry(now time.Time) *ignoredPort {
+	backoff := ignoredRetryMin
+	if p != nil {
+		backoff = min(2*p.backoff, ignoredRetryMax)
+	}
+	return &ignoredPort{retryAt: now.Add(backoff), backoff: backoff}
+}
+
+// track starts a background subscription, recording the meter in the
+// inventory once its first reading arrives
+func (d *meterDaemon) track(port string, sub *Subscription) {
+	var once sync.Once
+
+	sub.Start(func(sample *HistorySample, err error) {
+		if err != nil {
+			return
+		}
+		once.Do(func() {
+			d.inventoryMu.Lock()
+			defer d.inventoryMu.Unlock()
+			recordSighting(port, sample.Reading)
+		})
+		d.outputs.Load().Handle(port, sample.Timestamp, sample.Reading)
+	})
+
+	d.meters[port] = sub
+}
+
+// setInterval moves every background subscription to a new polling
+// interval, subscribing again before unsubscribing so ports stay open
+func (d *meterDaemon) setInterval(interval time.Duration) {
+	if interval == d.interval {
+		return
+	}
+	d.interval = interval
+
+	for port, old := range d.meters {
+		sub, _, err := d.broker.Subscribe(port, interval)
+		if err != nil {
+			log.Printf("Failed to change polling interval of %s: %v", port, err)
+			continue
+		}
+		d.track(port, sub)
+		old.Unsubscribe()
+	}
+
+	log.Printf("Polling every %v", interval)
+}
+
+// reopenOutputs replaces the sinks and alarm rules with the ones of the
+// reloaded config, keeping the current ones if the new ones are invalid
+func (d *meterDaemon) reopenOutputs() {
+	outputs, err := newReadingOutputs(daemonSinkFlag, daemonAlarmFlag, log.Printf)
+	if err != nil {
+		log.Printf("Failed to reload sinks and alarms, keeping the current ones: %v", err)
+		return
+	}
+	d.outputs.Swap(outputs).Close()
+}
+
+// notifyReady tells systemd the daemon is ready, with the meter count as
+// its status
+func (d *meterDaemon) notifyReady() {
+	sdNotify(fmt.Sprintf("READY=1\nSTATUS=Serving %d meter(s)", len(d.meters)))
+}
+
+// sdNotify sends a state update to systemd if the daemon was started as a
+// Type=notify service
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Printf("Failed to notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("Failed to notify systemd: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+func TestDaemonCandidates(t *testing.T) {
+	rfcomm := filepath.Join(t.TempDir(), "rfcomm0")
+	if err := os.WriteFile(rfcomm, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ports := []SerialPortInfo{
+		{Name: "/dev/ttyACM0", IsUSB: true, VID: "0483", PID: "5740"},
+		{Name: "/dev/ttyUSB0", IsUSB: true, VID: "1A86", PID: "7523"},
+		{Name: "/dev/ttyS0"},
+	}
+
+	tests := []struct {
+		name   string
+		meter  string
+		listed []string
+		want   []string
+	}{
+		{name: "TC66C USB only", meter: "tc66c", want: []string{"/dev/ttyACM0"}},
+		{name: "listed port", meter: "tc66c", listed: []string{rfcomm, "/dev/ttyACM0"}, want: []string{"/dev/ttyACM0", rfcomm}},
+		{name: "missing listed port", meter: "tc66c", listed: []string{rfcomm + "-gone"}, want: []string{"/dev/ttyACM0"}},
+		{name: "UM without ports", meter: "um"},
+		{name: "UM listed port", meter: "um", listed: []string{rfcomm}, want: []string{rfcomm}},
+	}
+
+	savedMeter, savedPorts := meterFlag, daemonPortsFlag
+	t.Cleanup(func() { meterFlag, daemonPortsFlag = savedMeter, savedPorts })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meterFlag, daemonPortsFlag = tt.meter, tt.listed
+			if got := candidates(ports); !slices.Equal(got, tt.want) {
+				t.Errorf("candidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIgnoredPortBackoff(t *testing.T) {
+	now := time.Now()
+
+	var port *ignoredPort
+	var backoffs []time.Duration
+	for range 7 {
+		port = port.retry(now)
+		backoffs = append(backoffs, port.backoff)
+	}
+
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 160 * time.Second, 5 * time.Minute, 5 * time.Minute}
+	if !slices.Equal(backoffs, want) {
+		t.Errorf("backoffs = %v, want %v", backoffs, want)
+	}
+	if !port.retryAt.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("retryAt = %v, want now plus the backoff", port.retryAt)
+	}
+}
+
+func TestDaemonRetriesIgnoredPorts(t *testing.T) {
+	useInventory(t)
+	rfcomm := filepath.Join(t.TempDir(), "rfcomm0")
+	if err := os.WriteFile(rfcomm, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	savedPorts := daemonPortsFlag
+	t.Cleanup(func() { daemonPortsFlag = savedPorts })
+	daemonPortsFlag = []string{rfcomm}
+
+	// The meter cannot be opened until it leaves bootloader mode
+	broker, meters := fakeBroker(0)
+	var bootloader atomic.Bool
+	bootloader.Store(true)
+	attempts := 0
+	broker.open = func(port string) (tc66c.Meter, error) {
+		if port != rfcomm {
+			return meters.open(port)
+		}
+		attempts++
+		if bootloader.Load() {
+			return nil, &tc66c.ModeError{Want: tc66c.ModeFirmware, Got: tc66c.ModeBootloader}
+		}
+		return meters.open(port)
+	}
+
+	d := &meterDaemon{
+		broker:   broker,
+		interval: time.Second,
+		meters:   make(map[string]*Subscription),
+		ignored:  make(map[string]*ignoredPort),
+	}
+	outputs, err := newReadingOutputs(nil, nil, log.Printf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.outputs.Store(outputs)
+	t.Cleanup(func() {
+		for _, sub := range d.meters {
+			sub.Unsubscribe()
+		}
+	})
+
+	d.scan()
+	if d.ignored[rfcomm] == nil || d.ignored[rfcomm].backoff != ignoredRetryMin || attempts != 1 {
+		t.Fatalf("after a failed open: ignored %+v, %d attempts", d.ignored[rfcomm], attempts)
+	}
+
+	// Rescans before the backoff has passed leave the port alone
+	d.scan()
+	if attempts != 1 {
+		t.Errorf("port retried %d times before its backoff passed", attempts-1)
+	}
+
+	// Once due it is retried, and failures back off further
+	d.ignored[rfcomm].retryAt = time.Now()
+	d.scan()
+	if attempts != 2 || d.ignored[rfcomm].backoff != 2*ignoredRetryMin {
+		t.Errorf("after a second failure: ignored %+v, %d attempts", d.ignored[rfcomm], attempts)
+	}
+
+	bootloader.Store(false)
+	d.ignored[rfcomm].retryAt = time.Now()
+	d.scan()
+	if d.meters[rfcomm] == nil || d.ignored[rfcomm] != nil {
+		t.Errorf("meter out of bootloader mode not taken: ignored %+v", d.ignored[rfcomm])
+	}
+}
+
+func TestDaemonClientScreen(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "daemon.sock")
+	listener, err := listenDaemonSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker, meters := fakeBroker(0)
+	mux := http.NewServeMux()
+	registerAPI(mux, broker)
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	savedSocket, savedNoDaemon := daemonSocketFlag, noDaemonFlag
+	t.Cleanup(func() { daemonSocketFlag, noDaemonFlag = savedSocket, savedNoDaemon })
+	daemonSocketFlag, noDaemonFlag = socket, false
+
+	const port = "/dev/ttyFAKE0"
+	client := dialDaemon(port)
+	if client == nil {
+		t.Fatal("no daemon found on the test socket")
+	}
+	defer client.Close()
+
+	if client.Holds() {
+		t.Error("daemon holds a port it has not opened")
+	}
+
+	// The daemon holds the port while it polls it, e.g. for a subscriber
+	sub, _, err := broker.Subscribe(port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	if !client.Holds() {
+		t.Error("daemon does not hold the port it polls")
+	}
+
+	if err := client.RotateScreen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.NextPage(); err != nil {
+		t.Fatal(err)
+	}
+	commands := meters.ports(port)[0].Commands()
+	if !slices.Contains(commands, tc66c.CmdRotat) || !slices.Contains(commands, tc66c.CmdNextP) {
+		t.Errorf("meter received %q, want the screen commands", commands)
+	}
+
+	// With --no-daemon the client is not used, but the port is still seen as held
+	noDaemonFlag = true
+	if dialDaemon(port) != nil {
+		t.Error("dialDaemon used the daemon with --no-daemon")
+	}
+	if held := dialDaemonSocket(port); held == nil || !held.Holds() {
+		t.Error("dialDaemonSocket does not see the port held with --no-daemon")
+	}
+}
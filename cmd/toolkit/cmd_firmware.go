@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var (
+	firmwareInfoJSONFlag bool
+	firmwareBaseURLFlag  string
+	firmwareProductFlag  string
+	firmwareCacheDirFlag string
+)
+
+var firmwareCmd = &cobra.Command{
+	Use:   "firmware",
+	Short: "Inspect, list and download firmware images",
+}
+
+var firmwareListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List firmware versions published in the manifest",
+	Run: func(cmd *cobra.Command, args []string) {
+		executeFirmwareList(newFirmwareCatalog(), firmwareCacheDir())
+	},
+}
+
+var firmwareFetchCmd = &cobra.Command{
+	Use:   "fetch <version>",
+	Short: "Download a firmware version into the local cache",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := fetchFirmware(newFirmwareCatalog(), firmwareCacheDir(), args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	},
+}
+
+var firmwareInfoCmd = &cobra.Command{
+	Use:   "info <file>",
+	Short: "Show what can be learnt from a firmware image",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeFirmwareInfo(args[0], firmwareInfoJSONFlag)
+	},
+}
+
+func init() {
+	firmwareInfoCmd.Flags().BoolVarP(&firmwareInfoJSONFlag, "json", "j", false, "Output in JSON format")
+	firmwareCmd.AddCommand(firmwareInfoCmd)
+	firmwareCmd.AddCommand(firmwareListCmd)
+	firmwareCmd.AddCommand(firmwareFetchCmd)
+	addFirmwareCatalogFlags(firmwareListCmd)
+	addFirmwareCatalogFlags(firmwareFetchCmd)
+	rootCmd.AddCommand(firmwareCmd)
+}
+
+// addFirmwareCatalogFlags adds the flags selecting where firmware is fetched from
+func addFirmwareCatalogFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&firmwareBaseURLFlag, "firmware-url", tc66c.DefaultFirmwareBaseURL, "Base URL (or local directory) of the firmware manifest mirror")
+	cmd.Flags().StringVar(&firmwareProductFlag, "product", "TC66", "Product whose manifest is used")
+	cmd.Flags().StringVar(&firmwareCacheDirFlag, "cache-dir", "", "Firmware cache directory (default: user cache directory)")
+}
+
+// newFirmwareCatalog creates a catalog from the command line flags
+func newFirmwareCatalog() *tc66c.FirmwareCatalog {
+	return tc66c.NewFirmwareCatalog(firmwareBaseURLFlag, firmwareProductFlag)
+}
+
+// firmwareCacheDir returns the directory downloaded images are kept in
+func firmwareCacheDir() string {
+	if firmwareCacheDirFlag != "" {
+		return firmwareCacheDirFlag
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "tc66c-toolkit", "firmware")
+}
+
+// cachedFirmwarePath returns where a firmware version is stored in the
+// cache. The version comes from the manifest or the command line, so it is
+// checked before it becomes part of a path
+func cachedFirmwarePath(cacheDir, product, version string) (string, error) {
+	if err := tc66c.CheckFirmwareVersion(version); err != nil {
+		return "", err
+	}
+	return filepath.Join(cacheDir, product, fmt.Sprintf("%s_v%s.bin", product, version)), nil
+}
+
+// executeFirmwareList prints the versions published in the manifest
+func executeFirmwareList(catalog *tc66c.FirmwareCatalog, cacheDir string) {
+	fmt.Fprintf(os.Stderr, "Fetching %s...\n", catalog.ManifestURL())
+
+	manifest, err := catalog.Manifest()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-8s | %-10s | %-8s | %-6s | %s\n", "Version", "Date", "Size", "Cached", "File")
+	fmt.Println("---------+------------+----------+--------+----------------")
+
+	for _, entry := range manifest.Entries {
+		cached := "no"
+		if path, err := cachedFirmwarePath(cacheDir, catalog.Product, entry.Version); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				cached = "yes"
+			}
+		}
+		size := "-"
+		if entry.Size > 0 {
+			size = fmt.Sprintf("%d", entry.Size)
+		}
+		fmt.Printf("%-8s | %-10s | %-8s | %-6s | %s\n", entry.Version, entry.Date, size, cached, entry.File)
+	}
+}
+
+// fetchFirmware makes sure the given version is in the cache, downloading
+// and verifying it if needed, and returns its path. When the manifest
+// cannot be reached a previously cached image is used
+func fetchFirmware(catalog *tc66c.FirmwareCatalog, cacheDir, version string) (string, error) {
+	manifest, err := catalog.Manifest()
+	if err != nil {
+		path, pathErr := cachedFirmwarePath(cacheDir, catalog.Product, version)
+		if pathErr != nil {
+			return "", pathErr
+		}
+		if _, statErr := os.Stat(path); statErr == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v, using cached image\n", err)
+			return path, nil
+		}
+		return "", err
+	}
+
+	entry, err := manifest.Find(version)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := cachedFirmwarePath(cacheDir, catalog.Product, entry.Version)
+	if err != nil {
+		return "", err
+	}
+
+	// Reuse the cached image if it still matches the manifest
+	if data, err := os.ReadFile(path); err == nil {
+		if err := entry.Verify(catalog.Product, data); err == nil {
+			fmt.Fprintf(os.Stderr, "Using cached firmware %s\n", path)
+			return path, nil
+		}
+		fmt.Fprintf(os.Stderr, "Cached firmware %s is stale, downloading again\n", path)
+	}
+
+	fmt.Fprintf(os.Stderr, "Downloading firmware %s...\n", entry.Version)
+	data, err := catalog.Download(entry)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write cached firmware: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Saved firmware %s (%d bytes)\n", entry.Version, len(data))
+	return path, nil
+}
+
+// executeFirmwareInfo inspects a firmware file and prints the result
+func executeFirmwareInfo(firmwareFile string, jsonOutput bool) {
+	firmwareData, err := os.ReadFile(firmwareFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading firmware file: %v\n", err)
+		os.Exit(1)
+	}
+
+	info := tc66c.InspectFirmware(firmwareData)
+	validationErr := info.Validate(false)
+
+	if jsonOutput {
+		out := struct {
+			*tc66c.FirmwareInfo
+			Valid bool   `json:"valid"`
+			Error string `json:"error,omitempty"`
+		}{FirmwareInfo: info, Valid: validationErr == nil}
+		if validationErr != nil {
+			out.Error = validationErr.Error()
+		}
+
+		data, err := json.Marshal(out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("File: %s\n", firmwareFile)
+	fmt.Println(info.String())
+
+	if validationErr != nil {
+		fmt.Printf("Valid: no (%v)\n", validationErr)
+	} else {
+		fmt.Println("Valid: yes")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var fleetJSONFlag bool
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Manage the inventory of meters seen by the toolkit",
+	Long: `Keep track of every meter seen: device serial number, firmware version,
+run count, last port and last-seen time. Labels attached here can be used
+anywhere a port is accepted, e.g. --port label:bench-3-left.
+
+The inventory is stored in the user configuration directory, or in the
+file named by the TC66C_INVENTORY environment variable.`,
+}
+
+var fleetListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the meters in the inventory",
+	Run: func(cmd *cobra.Command, args []string) {
+		executeFleetList(fleetJSONFlag)
+	},
+}
+
+var fleetScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Identify the meters on every TC66C USB port and record them",
+	Run: func(cmd *cobra.Command, args []string) {
+		executeFleetScan()
+	},
+}
+
+var fleetLabelCmd = &cobra.Command{
+	Use:   "label <serial-number> <label>",
+	Short: "Attach a label to a meter (an empty label removes it)",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		executeFleetLabel(args[0], args[1])
+	},
+}
+
+func init() {
+	fleetListCmd.Flags().BoolVarP(&fleetJSONFlag, "json", "j", false, "Output in JSON format")
+	fleetCmd.AddCommand(fleetListCmd)
+	fleetCmd.AddCommand(fleetScanCmd)
+	fleetCmd.AddCommand(fleetLabelCmd)
+	rootCmd.AddCommand(fleetCmd)
+}
+
+// executeFleetList prints the inventory
+func executeFleetList(jsonOutput bool) {
+	inv, err := loadInventory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		type fleetEntry struct {
+			*InventoryRecord
+			Outdated bool `json:"outdated"`
+		}
+		entries := make([]fleetEntry, 0, len(inv.Devices))
+		for _, record := range inv.Devices {
+			entries = append(entries, fleetEntry{InventoryRecord: record, Outdated: record.Outdated()})
+		}
+		data, err := json.Marshal(entries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(inv.Devices) == 0 {
+		fmt.Println("No meters in the inventory yet, run 'tc66c-toolkit fleet scan'")
+		return
+	}
+
+	fmt.Printf("%-10s | %-16s | %-7s | %-10s | %-6s | %-14s | %s\n", "Serial", "Label", "Product", "Firmware", "Runs", "Last Port", "Last Seen")
+	fmt.Println("-----------+------------------+---------+------------+--------+----------------+--------------------")
+
+	outdated := 0
+	for _, record := range inv.Devices {
+		firmware := record.FirmwareVersion
+		if record.Outdated() {
+			firmware += " (!)"
+			outdated++
+		}
+		fmt.Printf("%-10d | %-16s | %-7s | %-10s | %-6d | %-14s | %s\n",
+			record.SerialNumber, record.Label, record.Product, firmware, record.NumRuns,
+			record.LastPort, record.LastSeen.Local().Format(time.DateTime))
+	}
+
+	fmt.Printf("\nTotal meters: %d\n", len(inv.Devices))
+	if outdated > 0 {
+		fmt.Printf("Outdated firmware (!): %d\n", outdated)
+	}
+}
+
+// executeFleetScan identifies the meter on every TC66C USB port
+func executeFleetScan() {
+	if err := requireTC66C(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: the fleet inventory tracks meters by serial number: %v\n", err)
+		os.Exit(1)
+	}
+
+	all, err := listSerialPorts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	ports := tc66cPorts(all)
+	if skipped := len(all) - len(ports); skipped > 0 {
+		fmt.Printf("Skipping %d port(s) that are not a TC66C (USB %s:%s)\n", skipped, tc66c.TC66CUSBVID, tc66c.TC66CUSBPID)
+	}
+
+	// Labels are shown from the inventory as it was before the scan, which
+	// is only locked to store the meters found
+	known, err := loadInventory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	found := map[string]*tc66c.Reading{}
+	for _, port := range ports {
+		fmt.Printf("Scanning %s... ", port.Name)
+
+		device, err := openDevice(port.Name, nil)
+		if err != nil {
+			fmt.Println("no meter")
+			continue
+		}
+		if device.Mode != tc66c.ModeFirmware {
+			device.Close()
+			fmt.Printf("meter in %s mode, skipped\n", device.Mode)
+			continue
+		}
+		reading, err := device.GetReading()
+		device.Close()
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			continue
+		}
+
+		found[port.Name] = reading
+
+		fmt.Printf("%s v%s serial %d", reading.Product, reading.Version, reading.SerialNumber)
+		if record := known.Find(reading.SerialNumber); record != nil && record.Label != "" {
+			fmt.Printf(" [%s]", record.Label)
+		}
+		fmt.Println()
+	}
+
+	err = updateInventory(func(inv *Inventory) error {
+		for port, reading := range found {
+			inv.Observe(port, reading)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\nFound %d meter(s)\n", len(found))
+}
+
+// executeFleetLabel attaches a label to a meter in the inventory
+func executeFleetLabel(serial, label string) {
+	serialNumber, err := strconv.ParseUint(serial, 10, 32)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid serial number %q\n", serial)
+		os.Exit(1)
+	}
+
+	err = updateInventory(func(inv *Inventory) error {
+		record := inv.Find(uint32(serialNumber))
+		if record == nil {
+			return fmt.Errorf("meter %d is not in the inventory, run 'tc66c-toolkit fleet scan' first", serialNumber)
+		}
+
+		if other := inv.FindLabel(label); label != "" && other != nil && other != record {
+			return fmt.Errorf("label %q is already used by meter %d", label, other.SerialNumber)
+		}
+
+		record.Label = label
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if label == "" {
+		fmt.Printf("Removed label from meter %d\n", serialNumber)
+	} else {
+		fmt.Printf("Labelled meter %d as %q (use --port %s%s)\n", serialNumber, label, labelPortPrefix, label)
+	}
+}
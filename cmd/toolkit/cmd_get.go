@@ -1,20 +1,33 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
 	"github.com/spf13/cobra"
 )
 
-var getJSONFlag bool
+var (
+	getJSONFlag    bool
+	getCaptureFlag string
+)
 
 var getCmd = &cobra.Command{
 	Use:   "get",
 	Short: "Get a single reading from the device",
 	Run: func(cmd *cobra.Command, args []string) {
-		device := connectDevice(portFlag)
+		// Captures need the raw packet, which only a direct connection has
+		if getCaptureFlag != "" {
+			device := connectDevice(portFlag)
+			defer device.Close()
+			executeCapture(device, getCaptureFlag, getJSONFlag)
+			return
+		}
+
+		device := connectMeter(portFlag)
 		defer device.Close()
 		executeGet(device, getJSONFlag)
 	},
@@ -22,17 +35,84 @@ var getCmd = &cobra.Command{
 
 func init() {
 	getCmd.Flags().BoolVarP(&getJSONFlag, "json", "j", false, "Output in JSON format")
+	getCmd.Flags().StringVar(&getCaptureFlag, "capture", "", "Also save the raw packet and decoded reading to this directory (decoder golden corpus format)")
 	rootCmd.AddCommand(getCmd)
 }
 
 // executeGet gets a single reading from the device
-func executeGet(tc66c *tc66c.TC66C, jsonOutput bool) {
-	reading, err := tc66c.GetReading()
+func executeGet(device meterConn, jsonOutput bool) {
+	reading, err := device.GetReading()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting reading: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The daemon keeps the inventory up to date itself
+	if direct, ok := device.(*tc66c.TC66C); ok {
+		recordSighting(direct.PortName(), reading)
+	}
+
+	printGetReading(reading, jsonOutput)
+}
+
+// executeCapture gets a single reading and saves the raw packet with it
+func executeCapture(device *tc66c.TC66C, dir string, jsonOutput bool) {
+	packet, err := device.GetRawReading()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting reading: %v\n", err)
 		os.Exit(1)
 	}
 
+	decrypted, err := tc66c.DecryptPacket(packet)
+	if err == nil {
+		var reading *tc66c.Reading
+		reading, err = tc66c.ParseReading(decrypted)
+		if err == nil {
+			recordSighting(device.PortName(), reading)
+			saveCapture(dir, tc66c.NewPacketCapture(packet, reading))
+			printGetReading(reading, jsonOutput)
+			return
+		}
+	}
+
+	// Keep packets the decoder rejects, they are the interesting ones
+	path := filepath.Join(dir, "rejected.bin")
+	if werr := os.WriteFile(path, packet, 0o644); werr == nil {
+		fmt.Fprintf(os.Stderr, "Saved undecodable packet to %s\n", path)
+	}
+	fmt.Fprintf(os.Stderr, "Error decoding reading: %v\n", err)
+	os.Exit(1)
+}
+
+// saveCapture writes a packet capture to dir
+func saveCapture(dir string, capture *tc66c.PacketCapture) {
+	data, err := json.MarshalIndent(capture, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error formatting capture: %v\n", err)
+		os.Exit(1)
+	}
+
+	reading := capture.Reading
+	name := fmt.Sprintf("%s_v%s_%d_%s.json", reading.Product, reading.Version, reading.SerialNumber, capture.CapturedAt.Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating capture directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing capture: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Saved capture to %s\n", path)
+}
+
+// printGetReading prints a reading in the requested format
+func printGetReading(reading *tc66c.Reading, jsonOutput bool) {
+	if reading.LayoutFallback {
+		fmt.Fprintln(os.Stderr, layoutWarning(reading.Product, reading.Version))
+	}
 	if jsonOutput {
 		jsonStr, err := reading.JSON()
 		if err != nil {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66cpb"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+var (
+	grpcAddrFlag string
+	grpcPortFlag string
+)
+
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "Start a gRPC server for test harness integration",
+	Long: `Start a gRPC server exposing the tc66c.v1.Meter service: device listing,
+readings, reading streams with field masks, recordings, screen control and
+firmware updates with progress. The protobuf definitions are in
+proto/tc66c/v1/tc66c.proto, and server reflection is enabled for tools
+like grpcurl.
+
+Use an address of unix:/path/to/socket to listen on a Unix domain socket.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		executeGRPC(grpcAddrFlag, grpcPortFlag)
+	},
+}
+
+func init() {
+	grpcCmd.Flags().StringVarP(&grpcAddrFlag, "address", "a", "localhost", "Address to bind the gRPC server (or unix:<path>)")
+	grpcCmd.Flags().StringVar(&grpcPortFlag, "grpc-port", "50051", "Port for the gRPC server")
+	rootCmd.AddCommand(grpcCmd)
+}
+
+func executeGRPC(addr, port string) {
+	network, address := "tcp", net.JoinHostPort(addr, port)
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", address, err)
+	}
+
+	server := grpc.NewServer()
+	tc66cpb.RegisterMeterServer(server, &meterServer{broker: NewDeviceBroker(0)})
+	reflection.Register(server)
+
+	fmt.Printf("Starting gRPC server on %s\n", address)
+	if err := server.Serve(listener); err != nil {
+		log.Fatalf("gRPC server failed: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var infoJSONFlag bool
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show device identity and firmware details",
+	Run: func(cmd *cobra.Command, args []string) {
+		device := connectMeterDirect(portFlag, nil)
+		defer device.Close()
+		executeInfo(device, infoJSONFlag)
+	},
+}
+
+func init() {
+	infoCmd.Flags().BoolVarP(&infoJSONFlag, "json", "j", false, "Output in JSON format")
+	rootCmd.AddCommand(infoCmd)
+}
+
+// executeInfo prints what is known about the connected device
+func executeInfo(device tc66c.Meter, jsonOutput bool) {
+	info, err := device.Info()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting device info: %v\n", err)
+		os.Exit(1)
+	}
+
+	if info.Product != "" {
+		recordSighting(device.PortName(), &tc66c.Reading{
+			Product:      info.Product,
+			Version:      info.FirmwareVersion,
+			SerialNumber: info.SerialNumber,
+			NumRuns:      info.NumRuns,
+		})
+	}
+
+	if jsonOutput {
+		data, err := json.Marshal(info)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error formatting JSON: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Fprintln(os.Stderr)
+		fmt.Println(info.String())
+	}
+
+	if info.Outdated {
+		fmt.Fprintf(os.Stderr, "\nWarning: firmware %s is older than %s, the newest version known to the toolkit\n", info.FirmwareVersion, info.LatestVersion)
+		fmt.Fprintf(os.Stderr, "Run 'tc66c-toolkit update --version %s' in bootloader mode to update it\n", info.LatestVersion)
+	}
+	if info.UnknownLayout {
+		fmt.Fprintf(os.Stderr, "\n%s\n", layoutWarning(info.Product, info.FirmwareVersion))
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
@@ -10,52 +14,150 @@ import (
 )
 
 var (
-	intervalFlag time.Duration
-	pollJSONFlag bool
+	intervalFlag  time.Duration
+	pollJSONFlag  bool
+	pollAlarmFlag []string
+	pollSinkFlag  []string
 )
 
 var pollCmd = &cobra.Command{
 	Use:   "poll",
 	Short: "Continuously poll readings from the device",
+	Long: `Continuously poll readings from the device.
+
+Alarm rules compare a reading field with a threshold, e.g. "voltage>5.5" or
+"current<=0.05". Fields are voltage, current, power, resistance,
+temperature, dplus, dminus, mah and mwh, and operators are >, >=, < and <=.
+A warning is printed on stderr when a rule starts and stops matching.
+
+Sinks store every reading, given as <format>:<path> with format jsonl or
+csv. Files are appended to.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		device := connectDevice(portFlag)
+		outputs, err := newReadingOutputs(pollSinkFlag, pollAlarmFlag, func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer outputs.Close()
+
+		device := connectMeter(portFlag)
 		defer device.Close()
-		executePoll(device, intervalFlag, pollJSONFlag)
+		executePoll(device, intervalFlag, pollJSONFlag, outputs)
 	},
 }
 
 func init() {
 	pollCmd.Flags().DurationVarP(&intervalFlag, "interval", "i", 500*time.Millisecond, "Polling interval")
 	pollCmd.Flags().BoolVarP(&pollJSONFlag, "json", "j", false, "Output in JSON format")
+	pollCmd.Flags().StringSliceVar(&pollAlarmFlag, "alarm", nil, "Warn when a reading matches a rule, e.g. voltage>5.5 (repeatable)")
+	pollCmd.Flags().StringSliceVar(&pollSinkFlag, "sink", nil, "Store every reading in a file, as jsonl:<path> or csv:<path> (repeatable)")
 	rootCmd.AddCommand(pollCmd)
 }
 
 // executePoll continuously polls readings from the device
-func executePoll(tc66c *tc66c.TC66C, interval time.Duration, jsonOutput bool) {
+func executePoll(device meterConn, interval time.Duration, jsonOutput bool, outputs *readingOutputs) {
 	if !jsonOutput {
 		fmt.Printf("Polling readings every %v (press Ctrl+C to stop)...\n\n", interval)
 	}
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	// Report the session counters when polling is stopped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Warn about an unknown packet layout once, not on every reading
+	layoutWarned := false
+	handle := func(reading *tc66c.Reading, at time.Time) {
+		if reading.LayoutFallback && !layoutWarned {
+			fmt.Fprintln(os.Stderr, layoutWarning(reading.Product, reading.Version))
+			layoutWarned = true
+		}
+		printPollReading(reading, at, jsonOutput)
+		outputs.Handle(device.PortName(), at, reading)
+	}
+
+	// Share the daemon's poller instead of requesting each reading
+	if client, ok := device.(*DaemonClient); ok {
+		go func() {
+			<-ctx.Done()
+			printPollStats(device)
+			outputs.Close()
+			os.Exit(0)
+		}()
 
-	// Get first reading immediately
-	printReading(tc66c, jsonOutput)
+		disconnected := false
+		err := client.Stream(interval, func(sample *HistorySample, err error) {
+			if err != nil {
+				// The daemon reopens the port by itself, so only report the first failure
+				if errors.Is(err, tc66c.ErrDisconnected) {
+					if !disconnected {
+						fmt.Fprintln(os.Stderr, "Meter disconnected, waiting for it to be plugged back in...")
+					}
+					disconnected = true
+					return
+				}
+				fmt.Fprintf(os.Stderr, "Error getting reading: %v\n", err)
+				return
+			}
+			if disconnected {
+				fmt.Fprintln(os.Stderr, "Meter reconnected")
+				disconnected = false
+			}
+			handle(sample.Reading, sample.Timestamp)
+		})
+		outputs.Close()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Poll at specified interval
-	for range ticker.C {
-		printReading(tc66c, jsonOutput)
+	// Get first reading immediately, then poll at specified interval
+	samples, errs := tc66c.Stream(ctx, device, tc66c.StreamOptions{Interval: interval})
+	for samples != nil || errs != nil {
+		select {
+		case sample, ok := <-samples:
+			if !ok {
+				samples = nil
+				continue
+			}
+			handle(sample.Reading, sample.Time)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			// Lost readings are reported and skipped, but the stream stops
+			// if the meter cannot answer anymore
+			if errors.Is(err, tc66c.ErrDisconnected) || errors.Is(err, tc66c.ErrWrongMode) {
+				outputs.Close()
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Error getting reading: %v\n", err)
+		}
 	}
+
+	printPollStats(device)
 }
 
-// printReading gets and prints a single reading
-func printReading(tc66c *tc66c.TC66C, jsonOutput bool) {
-	reading, err := tc66c.GetReading()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting reading: %v\n", err)
-		return
+// printPollStats prints the reading counters of the polling session
+func printPollStats(device meterConn) {
+	var stats tc66c.Stats
+	switch device := device.(type) {
+	case *DaemonClient:
+		var err error
+		if stats, err = device.Stats(); err != nil {
+			return
+		}
+	case tc66c.Meter:
+		stats = device.Stats()
 	}
 
+	fmt.Fprintf(os.Stderr, "\nSession: %s\n", stats)
+}
+
+// printPollReading prints a reading taken at the given time
+func printPollReading(reading *tc66c.Reading, at time.Time, jsonOutput bool) {
 	if jsonOutput {
 		jsonStr, err := reading.JSON()
 		if err != nil {
@@ -65,7 +167,7 @@ func printReading(tc66c *tc66c.TC66C, jsonOutput bool) {
 		fmt.Println(jsonStr)
 	} else {
 		// Print a compact one-line format for polling
-		timestamp := time.Now().Format("15:04:05")
+		timestamp := at.Local().Format("15:04:05")
 		fmt.Printf("[%s] %s\n", timestamp, reading.ShortString())
 	}
 }
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var (
+	probeWordlistFlag     string
+	probeDelayFlag        time.Duration
+	probeTimeoutFlag      time.Duration
+	probeAllowFlag        []string
+	probeIncludeKnownFlag bool
+	probeDryRunFlag       bool
+	probeJSONFlag         bool
+	probeOutputFlag       string
+)
+
+// minProbeDelay keeps --delay from hammering the meter
+const minProbeDelay = 100 * time.Millisecond
+
+var probeCmd = &cobra.Command{
+	Use:   "probe",
+	Short: "Try candidate commands to map undocumented protocol features",
+	Long: `Sends candidate 5-character commands one at a time and records which ones
+the meter answers, how many bytes it answers with, whether the answer is text
+and whether it decrypts to pac blocks like a getva packet.
+
+Candidates come from a built-in wordlist or from --wordlist, one per line
+(# starts a comment). The documented commands are skipped unless
+--include-known is given.
+
+Probing is careful by design:
+  - Commands that look like they change settings or clear data (clr, reset,
+    set, save...) are skipped unless lifted with --allow.
+  - Commands that look like they could reflash or recalibrate the meter
+    (upd, boot, flash, wr, cal...) are always skipped. Send them with the
+    raw command if you really mean to.
+  - Commands are sent at most once per --delay.
+  - After every command the meter must still answer query with "firm",
+    otherwise probing stops and the last command is reported.
+
+Use --dry-run to review the candidates and what the blocklists skip
+before sending anything.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		candidates, err := probeCandidates(probeWordlistFlag, probeIncludeKnownFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if probeDryRunFlag {
+			printProbePlan(candidates, probeAllowFlag)
+			return
+		}
+
+		var output io.Writer
+		if probeOutputFlag != "" {
+			file, err := os.Create(probeOutputFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: failed to create output file: %v\n", err)
+				os.Exit(1)
+			}
+			defer file.Close()
+			output = file
+		}
+
+		device := connectDevice(portFlag)
+		defer device.Close()
+
+		if device.Mode != tc66c.ModeFirmware {
+			fmt.Fprintf(os.Stderr, "Error: device must be in firmware mode to probe (current mode: %s)\n", device.Mode)
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := executeProbe(ctx, device, candidates, output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			device.Close()
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	probeCmd.Flags().StringVarP(&probeWordlistFlag, "wordlist", "f", "", "File with candidate commands, one per line (default: built-in list)")
+	probeCmd.Flags().DurationVarP(&probeDelayFlag, "delay", "d", 500*time.Millisecond, "Minimum time between two probed commands (at least 100ms)")
+	probeCmd.Flags().DurationVarP(&probeTimeoutFlag, "timeout", "t", 300*time.Millisecond, "How long the device may stay quiet before a response is considered complete")
+	probeCmd.Flags().StringSliceVar(&probeAllowFlag, "allow", nil, "Blocklist patterns to lift, e.g. --allow clr,set (reflash patterns cannot be lifted)")
+	probeCmd.Flags().BoolVar(&probeIncludeKnownFlag, "include-known", false, "Also probe the documented commands")
+	probeCmd.Flags().BoolVar(&probeDryRunFlag, "dry-run", false, "List the candidates and blocked commands without opening the port")
+	probeCmd.Flags().BoolVarP(&probeJSONFlag, "json", "j", false, "Output one JSON result per line")
+	probeCmd.Flags().StringVarP(&probeOutputFlag, "output", "o", "", "Also write every result as a JSON line to this file")
+	rootCmd.AddCommand(probeCmd)
+}
+
+// defaultProbeCandidates builds the built-in wordlist from guesses at how
+// the documented commands are named: get/gt prefixes, page and screen
+// verbs, and a few whole words cut to 5 characters
+func defaultProbeCandidates() []string {
+	var candidates []string
+	for _, suffix := range []string{
+		"va", "vi", "st", "ss", "in", "id", "sn", "vr", "vs", "fw", "hw", "md",
+		"th", "tr", "lm", "al", "cf", "cg", "pr", "pa", "gp", "gr", "ah", "wh",
+		"tm", "ti", "rt", "tp", "dp", "dm", "lt", "br", "sc", "pg", "rc", "rn",
+	} {
+		candidates = append(candidates, "get"+suffix)
+	}
+	for _, suffix := range []string{
+		"sta", "inf", "ver", "cfg", "thr", "lim", "grp", "cnt", "tim", "tmp",
+		"idn", "ser", "pag", "scr", "brt", "lgt", "rcd", "log",
+	} {
+		candidates = append(candidates, "gt"+suffix)
+	}
+	candidates = append(candidates,
+		"state", "stats", "statu", "infor", "versn", "modl?", "ident", "seria",
+		"confg", "param", "group", "grp00", "grp01", "count", "total", "runtm",
+		"thres", "limit", "alarm", "light", "brigh", "scren", "scrnx", "pages",
+		"homep", "mainp", "page0", "page1", "page2", "prevp", "firsp", "rotaq",
+		"lastv", "nextv", "lastr", "nextr", "recst", "recon", "recof", "gtrcs",
+		"hello", "help?", "test?", "echo?", "ping?", "vers?", "*idn?",
+		"clrgp", "clrrc", "clear", "reset", "rstgp", "setth", "setlm", "savec",
+		"updat", "boot?", "flash", "calib",
+	)
+	return candidates
+}
+
+// probeCandidates returns the candidate commands from wordlist, or the
+// built-in list if wordlist is empty, without duplicates. The documented
+// commands are left out unless includeKnown is set
+func probeCandidates(wordlist string, includeKnown bool) ([]string, error) {
+	candidates := defaultProbeCandidates()
+	if wordlist != "" {
+		var err error
+		if candidates, err = readProbeWordlist(wordlist); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []string
+	for _, candidate := range candidates {
+		if slices.Contains(result, candidate) {
+			continue
+		}
+		if !includeKnown && slices.Contains(tc66c.KnownCommands, candidate) {
+			continue
+		}
+		result = append(result, candidate)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no candidate commands to probe")
+	}
+	return result, nil
+}
+
+// readProbeWordlist reads one candidate command per line, skipping blank
+// lines and # comments. Candidates must be 5 printable ASCII characters
+func readProbeWordlist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wordlist: %w", err)
+	}
+	defer file.Close()
+
+	var candidates []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		candidate := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(candidate) == "" || strings.HasPrefix(candidate, "#") {
+			continue
+		}
+		if len(candidate) != 5 || strings.IndexFunc(candidate, func(r rune) bool { return r < 0x20 || r > 0x7e }) >= 0 {
+			return nil, fmt.Errorf("%s:%d: %q is not 5 printable ASCII characters", path, line, candidate)
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	return candidates, nil
+}
+
+// printProbePlan lists what probing would send and what it would skip
+func printProbePlan(candidates []string, allow []string) {
+	var send, blocked int
+	for _, candidate := range candidates {
+		if pattern := tc66c.ProbeBlocked(candidate, allow); pattern != "" {
+			fmt.Printf("skip  %-7q blocked (matches %q)\n", candidate, pattern)
+			blocked++
+		} else {
+			fmt.Printf("send  %q\n", candidate)
+			send++
+		}
+	}
+	fmt.Printf("\n%d to send, %d blocked\n", send, blocked)
+}
+
+// executeProbe probes every candidate, rate limited to one per --delay,
+// until they are done, ctx is cancelled or the device misbehaves. Results
+// are printed as they come and a summary of the answered commands at the end
+func executeProbe(ctx context.Context, device *tc66c.TC66C, candidates []string, output io.Writer) error {
+	delay := max(probeDelayFlag, minProbeDelay)
+	fmt.Fprintf(os.Stderr, "Probing %d candidates, one every %v. Press Ctrl+C to stop\n", len(candidates), delay)
+
+	var answered []*tc66c.ProbeResult
+	var sent, blocked int
+	var lastSent time.Time
+	for i, candidate := range candidates {
+		if tc66c.ProbeBlocked(candidate, probeAllowFlag) == "" {
+			select {
+			case <-ctx.Done():
+				fmt.Fprintln(os.Stderr, "Interrupted")
+				printProbeSummary(answered, sent, blocked)
+				return nil
+			case <-time.After(time.Until(lastSent.Add(delay))):
+			}
+			lastSent = time.Now()
+			sent++
+		} else {
+			blocked++
+		}
+
+		result, err := device.Probe(candidate, probeTimeoutFlag, probeAllowFlag)
+		if result != nil {
+			if result.Length > 0 {
+				answered = append(answered, result)
+			}
+			printProbeResult(i+1, len(candidates), result)
+			if output != nil {
+				writeProbeResult(output, result)
+			}
+		}
+		if err != nil {
+			printProbeSummary(answered, sent, blocked)
+			return fmt.Errorf("stopped probing: %w", err)
+		}
+	}
+
+	printProbeSummary(answered, sent, blocked)
+	return nil
+}
+
+// printProbeResult prints one result as a line of text or, with --json,
+// as a JSON line
+func printProbeResult(n, total int, result *tc66c.ProbeResult) {
+	if probeJSONFlag {
+		writeProbeResult(os.Stdout, result)
+		return
+	}
+
+	prefix := fmt.Sprintf("[%*d/%d] %-7q", len(fmt.Sprint(total)), n, total, result.Command)
+	switch {
+	case result.Blocked != "":
+		fmt.Printf("%s blocked (matches %q)\n", prefix, result.Blocked)
+	case result.Length == 0:
+		fmt.Printf("%s no response\n", prefix)
+	default:
+		fmt.Printf("%s %s\n", prefix, describeProbeResponse(result))
+	}
+}
+
+// describeProbeResponse summarizes a response: its length, and its text or
+// the pac blocks it decrypts to
+func describeProbeResponse(result *tc66c.ProbeResult) string {
+	desc := fmt.Sprintf("%d bytes", result.Length)
+	if result.Text {
+		if text, err := hex.DecodeString(result.Response); err == nil {
+			desc += fmt.Sprintf(" text %q", text)
+		}
+	}
+	for _, block := range result.PacBlocks {
+		crc := "bad CRC"
+		if block.ValidCRC {
+			crc = "CRC ok"
+		}
+		desc += fmt.Sprintf(", %s at %d (%s)", block.Prefix, block.Offset, crc)
+	}
+	return desc
+}
+
+// printProbeSummary prints the commands the device answered
+func printProbeSummary(answered []*tc66c.ProbeResult, sent, blocked int) {
+	if probeJSONFlag {
+		return
+	}
+
+	fmt.Printf("\nSent %d commands, %d blocked, %d answered\n", sent, blocked, len(answered))
+	for _, result := range answered {
+		fmt.Printf("  %-7q %s\n", result.Command, describeProbeResponse(result))
+	}
+}
+
+// writeProbeResult writes result as a JSON line
+func writeProbeResult(w io.Writer, result *tc66c.ProbeResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to marshal result: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", data)
+}
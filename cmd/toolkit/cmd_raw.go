@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rawLengthFlag  int
+	rawTimeoutFlag time.Duration
+)
+
+var rawCmd = &cobra.Command{
+	Use:   "raw [command]",
+	Short: "Send a raw protocol command and hex-dump the response",
+	Long: `Sends a command string to the device, followed by \r\n like every TC66C
+command, and hex-dumps whatever it answers. Meant for debugging and reverse
+engineering the protocol. The response is read until --length bytes have
+arrived, or until the device has been quiet for --timeout.
+
+Without a command, starts an interactive console that sends one command
+per line. A line may end with the expected response length, e.g.
+"getva 192". Type .help for the console commands.
+
+Combine with --trace - to also see flushed stray bytes and read timeouts.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		device := connectDevice(portFlag)
+		defer device.Close()
+
+		if len(args) == 0 {
+			executeRawConsole(device, rawTimeoutFlag)
+			return
+		}
+		if err := sendRaw(device, args[0], rawLengthFlag, rawTimeoutFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rawCmd.Flags().IntVarP(&rawLengthFlag, "length", "n", 0, "Expected response length in bytes (0 reads until the device goes quiet)")
+	rawCmd.Flags().DurationVarP(&rawTimeoutFlag, "timeout", "t", 500*time.Millisecond, "How long the device may stay quiet before the response is considered complete")
+	rootCmd.AddCommand(rawCmd)
+}
+
+// rawConsoleHelp is printed by the .help console command
+const rawConsoleHelp = `Enter a command to send it, optionally followed by the expected length:
+  query           Send "query" and read until the device goes quiet
+  getva 192       Send "getva" and read 192 bytes
+Console commands:
+  .timeout <d>    Set how long the device may stay quiet (e.g. 200ms)
+  .help           Show this help
+  .quit           Leave the console (or press Ctrl+D)`
+
+// sendRaw sends one command and prints the response as a hex dump. A
+// partial response is printed before the error is returned
+func sendRaw(device *tc66c.TC66C, command string, length int, timeout time.Duration) error {
+	fmt.Printf("-> %q\n", command+"\r\n")
+
+	start := time.Now()
+	response, err := device.RawCommand(command, length, timeout)
+	elapsed := time.Since(start)
+
+	if len(response) == 0 {
+		fmt.Printf("<- no response in %v\n", elapsed.Round(time.Millisecond))
+	} else {
+		fmt.Printf("<- %d bytes in %v\n", len(response), elapsed.Round(time.Millisecond))
+		fmt.Print(hex.Dump(response))
+	}
+
+	return err
+}
+
+// executeRawConsole reads commands from stdin and sends them until EOF
+func executeRawConsole(device *tc66c.TC66C, timeout time.Duration) {
+	fmt.Fprintf(os.Stderr, "Raw console on %s (device mode: %s). Type .help for help\n", device.PortName(), device.Mode)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "tc66c> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(os.Stderr)
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == ".quit" || line == ".exit":
+			return
+		case line == ".help":
+			fmt.Println(rawConsoleHelp)
+			continue
+		case strings.HasPrefix(line, ".timeout"):
+			d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(line, ".timeout")))
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid timeout, expected a duration like 200ms\n")
+				continue
+			}
+			timeout = d
+			fmt.Printf("Timeout set to %v\n", timeout)
+			continue
+		case strings.HasPrefix(line, "."):
+			fmt.Fprintf(os.Stderr, "Error: unknown console command %s, type .help for help\n", line)
+			continue
+		}
+
+		command, length := parseRawLine(line)
+		err := sendRaw(device, command, length, timeout)
+		if errors.Is(err, tc66c.ErrDisconnected) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// parseRawLine splits a console line into the command and the expected
+// response length, given as a trailing number
+func parseRawLine(line string) (string, int) {
+	i := strings.LastIndexByte(line, ' ')
+	if i < 0 {
+		return line, 0
+	}
+
+	length, err := strconv.Atoi(line[i+1:])
+	if err != nil || length < 0 {
+		return line, 0
+	}
+	return strings.TrimSpace(line[:i]), length
+}
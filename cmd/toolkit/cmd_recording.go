@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +11,7 @@ var recordingCmd = &cobra.Command{
 	Use:   "recording",
 	Short: "Retrieve recordings from the device",
 	Run: func(cmd *cobra.Command, args []string) {
-		device := connectDevice(portFlag)
+		device := connectMeter(portFlag)
 		defer device.Close()
 		executeRecording(device)
 	},
@@ -23,10 +22,10 @@ func init() {
 }
 
 // executeRecording retrieves recordings from the device
-func executeRecording(tc66c *tc66c.TC66C) {
+func executeRecording(device meterConn) {
 	fmt.Println("Retrieving recordings...")
 
-	recordings, err := tc66c.GetRecordings()
+	recordings, err := device.GetRecordings()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting recordings: %v\n", err)
 		os.Exit(1)
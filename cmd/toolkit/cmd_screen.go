@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var screenCmd = &cobra.Command{
+	Use:       "screen <next|prev|rotate>",
+	Short:     "Control the device screen",
+	Long:      `Switch to the next or previous page, or rotate the device screen.`,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"next", "prev", "rotate"},
+	Run: func(cmd *cobra.Command, args []string) {
+		var device screenControl
+		if client := useDaemon(portFlag); client != nil {
+			device = client
+		} else {
+			device = connectMeterDirect(portFlag, nil)
+		}
+		defer device.Close()
+		executeScreen(device, args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(screenCmd)
+}
+
+// screenControl is a meter screen, reached directly or through the daemon
+type screenControl interface {
+	NextPage() error
+	PreviousPage() error
+	RotateScreen() error
+	Close() error
+}
+
+// executeScreen sends a screen control command to the device
+func executeScreen(device screenControl, action string) {
+	var err error
+
+	switch action {
+	case "next":
+		err = device.NextPage()
+	case "prev":
+		err = device.PreviousPage()
+	case "rotate":
+		err = device.RotateScreen()
+	default:
+		err = fmt.Errorf("unknown screen action: %s", action)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error controlling screen: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Screen command '%s' sent\n", action)
+}
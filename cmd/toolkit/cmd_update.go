@@ -1,14 +1,54 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
 	"github.com/spf13/cobra"
 )
 
-var firmwareFileFlag string
+var (
+	firmwareFileFlag       string
+	updateForceFlag        bool
+	updateVersionFlag      string
+	updateChunkRetriesFlag int
+	updateResponseTimeout  time.Duration
+	updateHandshakeTimeout time.Duration
+	updateLogFileFlag      string
+	updateRecoverFlag      bool
+	updateRecoverAttempts  int
+	updateDryRunFlag       bool
+	updateTranscriptFlag   string
+)
+
+// bootloaderInstructions explains how to put the device into bootloader mode
+const bootloaderInstructions = `To enter bootloader mode:
+  1. Unplug the device
+  2. Press and hold the K1 button
+  3. While holding K1, plug in the device
+  4. Release K1`
+
+// firmwareRecoveryGuidance is shown when a firmware update fails midway
+const firmwareRecoveryGuidance = `WARNING: Your device may not boot normally in this state.
+Put the device back into bootloader mode and run the update again, or use
+--recover to have the toolkit restart the update until it completes.
+The update does not touch the bootloader, so it can be retried.`
+
+// updateSettings holds the options of a firmware update session
+type updateSettings struct {
+	force      bool
+	recover    bool
+	attempts   int
+	logFile    string
+	dryRun     bool
+	transcript io.Writer
+	options    tc66c.FirmwareUpdateOptions
+}
 
 var updateCmd = &cobra.Command{
 	Use:   "update",
@@ -16,39 +56,83 @@ var updateCmd = &cobra.Command{
 	Long: `Update the device firmware from a binary file.
 
 The device must be in bootloader mode before running this command.
-To enter bootloader mode:
-  1. Unplug the device
-  2. Press and hold the K1 button
-  3. While holding K1, plug in the device
-  4. Release K1`,
+` + bootloaderInstructions,
 	Run: func(cmd *cobra.Command, args []string) {
-		if firmwareFileFlag == "" {
-			fmt.Fprintf(os.Stderr, "Error: -file flag is required\n")
+		if firmwareFileFlag == "" && updateVersionFlag == "" {
+			fmt.Fprintf(os.Stderr, "Error: --file or --version flag is required\n")
 			cmd.Usage()
 			os.Exit(1)
 		}
-		device := connectDevice(portFlag)
-		defer device.Close()
-		executeUpdate(device, firmwareFileFlag)
+		if updateVersionFlag != "" {
+			if firmwareFileFlag != "" {
+				fmt.Fprintf(os.Stderr, "Error: --file and --version are mutually exclusive\n")
+				os.Exit(1)
+			}
+			path, err := fetchFirmware(newFirmwareCatalog(), firmwareCacheDir(), updateVersionFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			firmwareFileFlag = path
+		}
+
+		options := tc66c.DefaultFirmwareUpdateOptions()
+		options.ChunkRetries = updateChunkRetriesFlag
+		options.ResponseTimeout = updateResponseTimeout
+		options.HandshakeTimeout = updateHandshakeTimeout
+
+		// Record every byte exchanged with the device if requested
+		var transcript io.Writer
+		if updateTranscriptFlag != "" {
+			transcriptFile, err := os.Create(updateTranscriptFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating transcript file: %v\n", err)
+				os.Exit(1)
+			}
+			defer transcriptFile.Close()
+			transcript = transcriptFile
+		}
+
+		device := connectDeviceWithTranscript(portFlag, transcript)
+		executeUpdate(device, portFlag, firmwareFileFlag, updateSettings{
+			force:      updateForceFlag,
+			recover:    updateRecoverFlag,
+			attempts:   updateRecoverAttempts,
+			logFile:    updateLogFileFlag,
+			dryRun:     updateDryRunFlag,
+			transcript: transcript,
+			options:    options,
+		})
 	},
 }
 
 func init() {
-	updateCmd.Flags().StringVarP(&firmwareFileFlag, "file", "f", "", "Firmware file (required)")
+	defaults := tc66c.DefaultFirmwareUpdateOptions()
+
+	updateCmd.Flags().StringVarP(&firmwareFileFlag, "file", "f", "", "Firmware file")
+	updateCmd.Flags().StringVar(&updateVersionFlag, "version", "", "Firmware version to fetch from the manifest instead of --file")
+	addFirmwareCatalogFlags(updateCmd)
+	updateCmd.Flags().BoolVar(&updateForceFlag, "force", false, "Flash images that fail validation or are not known-good")
+	updateCmd.Flags().IntVar(&updateChunkRetriesFlag, "chunk-retries", defaults.ChunkRetries, "Extra response timeouts to wait for each chunk acknowledgement")
+	updateCmd.Flags().DurationVar(&updateResponseTimeout, "response-timeout", defaults.ResponseTimeout, "Time to wait for each chunk acknowledgement")
+	updateCmd.Flags().DurationVar(&updateHandshakeTimeout, "handshake-timeout", defaults.HandshakeTimeout, "Time to wait for the bootloader to enter update mode")
+	updateCmd.Flags().StringVar(&updateLogFileFlag, "log", "", "Write a JSON lines log of the update session to this file")
+	updateCmd.Flags().BoolVar(&updateRecoverFlag, "recover", false, "Restart the whole update on failure until the image is written")
+	updateCmd.Flags().IntVar(&updateRecoverAttempts, "attempts", 5, "Maximum number of update attempts with --recover")
+	updateCmd.Flags().BoolVar(&updateDryRunFlag, "dry-run", false, "Check the device and image and print the chunk plan without writing anything")
+	updateCmd.Flags().StringVar(&updateTranscriptFlag, "transcript", "", "Record every byte sent and received to this file (JSON lines)")
 	rootCmd.AddCommand(updateCmd)
 }
 
 // executeUpdate updates the device firmware
-func executeUpdate(device *tc66c.TC66C, firmwareFile string) {
+func executeUpdate(device *tc66c.TC66C, port, firmwareFile string, settings updateSettings) {
+	defer func() { device.Close() }()
+
 	// Check if device is in bootloader mode
 	if device.Mode != tc66c.ModeBootloader {
 		fmt.Fprintf(os.Stderr, "Error: Device must be in bootloader mode to update firmware\n")
 		fmt.Fprintf(os.Stderr, "Current mode: %s\n", device.Mode)
-		fmt.Fprintf(os.Stderr, "\nTo enter bootloader mode:\n")
-		fmt.Fprintf(os.Stderr, "1. Unplug the device\n")
-		fmt.Fprintf(os.Stderr, "2. Press and hold the K1 button\n")
-		fmt.Fprintf(os.Stderr, "3. While holding K1, plug in the device\n")
-		fmt.Fprintf(os.Stderr, "4. Release K1\n")
+		fmt.Fprintf(os.Stderr, "\n%s\n", bootloaderInstructions)
 		os.Exit(1)
 	}
 
@@ -60,30 +144,77 @@ func executeUpdate(device *tc66c.TC66C, firmwareFile string) {
 		os.Exit(1)
 	}
 
-	fileSize := len(firmwareData)
-	chunkCount := (fileSize + tc66c.FirmwareChunkSize - 1) / tc66c.FirmwareChunkSize
-	fmt.Printf("Firmware file size: %d bytes (%d chunks of %d bytes)\n\n", fileSize, chunkCount, tc66c.FirmwareChunkSize)
+	// Validate the image before touching the bootloader
+	info := tc66c.InspectFirmware(firmwareData)
+	fmt.Println(info.String())
+	fmt.Println()
+
+	if err := info.Validate(settings.force); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Use --force to flash it anyway.\n")
+		os.Exit(1)
+	}
+	if info.Known == nil {
+		fmt.Println("WARNING: Flashing an unknown firmware image (--force)")
+		fmt.Println()
+	}
+
+	if settings.dryRun {
+		printChunkPlan(tc66c.PlanFirmwareChunks(firmwareData))
+		fmt.Println()
+		fmt.Println("Dry run: device is in bootloader mode and the image is valid. Nothing was written.")
+		return
+	}
+
+	// Log every step of the session as JSON lines if requested
+	if settings.logFile != "" {
+		logFile, err := os.Create(settings.logFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		encoder := json.NewEncoder(logFile)
+		settings.options.Log = func(event tc66c.FirmwareUpdateEvent) {
+			encoder.Encode(event)
+		}
+	}
 
 	fmt.Println("WARNING: Do not disconnect the device during the update!")
 	fmt.Println("Starting firmware update...")
 	fmt.Println()
 
-	// Update firmware with progress callback
-	err = device.UpdateFirmware(firmwareData, func(progress tc66c.FirmwareUpdateProgress) {
-		percentage := float64(progress.BytesSent) / float64(progress.TotalBytes) * 100
-		fmt.Printf("\r[>] Progress: %d/%d bytes (%.0f%%) - Chunk %d/%d OK",
-			progress.BytesSent, progress.TotalBytes, percentage,
-			progress.ChunksSent, progress.TotalChunks)
-	})
+	attempts := 1
+	if settings.recover && settings.attempts > 1 {
+		attempts = settings.attempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		// Update firmware with progress callback
+		err = device.UpdateFirmwareWithOptions(firmwareData, settings.options, func(progress tc66c.FirmwareUpdateProgress) {
+			percentage := float64(progress.BytesSent) / float64(progress.TotalBytes) * 100
+			fmt.Printf("\r[>] Progress: %d/%d bytes (%.0f%%) - Chunk %d/%d OK",
+				progress.BytesSent, progress.TotalBytes, percentage,
+				progress.ChunksSent, progress.TotalChunks)
+		})
 
-	fmt.Println() // New line after progress
+		fmt.Println() // New line after progress
+
+		if err == nil {
+			break
+		}
 
-	if err != nil {
 		fmt.Fprintf(os.Stderr, "\nError: Firmware update failed: %v\n", err)
-		fmt.Fprintf(os.Stderr, "\nWARNING: Your device may not boot normally in this state.\n")
-		fmt.Fprintf(os.Stderr, "Try running the update again. If it still fails, you may need to\n")
-		fmt.Fprintf(os.Stderr, "use recovery procedures specific to your device.\n")
-		os.Exit(1)
+
+		if attempt >= attempts {
+			fmt.Fprintf(os.Stderr, "\n%s\n", firmwareRecoveryGuidance)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(os.Stderr, "\nRestarting update (attempt %d of %d)...\n", attempt+1, attempts)
+		device.Close()
+		device = reconnectBootloader(port, settings.transcript)
 	}
 
 	fmt.Println()
@@ -91,3 +222,53 @@ func executeUpdate(device *tc66c.TC66C, firmwareFile string) {
 	fmt.Println()
 	fmt.Println("You can now unplug and replug the device to boot into the new firmware.")
 }
+
+// reconnectBootloader reopens the port and returns the device once it is in
+// bootloader mode. It first tries on its own and then guides the user
+// through re-entering bootloader mode
+func reconnectBootloader(port string, transcript io.Writer) *tc66c.TC66C {
+	stdin := bufio.NewReader(os.Stdin)
+
+	for {
+		// Give the bootloader a moment to give up on the interrupted chunk
+		time.Sleep(time.Second)
+
+		device, err := openDevice(port, transcript)
+		if err == nil && device.Mode == tc66c.ModeBootloader {
+			return device
+		}
+
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Device is in %s mode.\n", device.Mode)
+			device.Close()
+		} else {
+			fmt.Fprintf(os.Stderr, "Could not reconnect: %v\n", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "\n%s\n\nPress Enter when the device is in bootloader mode (Ctrl+C to abort)...", bootloaderInstructions)
+		if _, err := stdin.ReadString('\n'); err != nil {
+			fmt.Fprintf(os.Stderr, "\nAborted.\n\n%s\n", firmwareRecoveryGuidance)
+			os.Exit(1)
+		}
+	}
+}
+
+// printChunkPlan prints the chunks that would be sent to the bootloader,
+// eliding the middle of long plans
+func printChunkPlan(chunks []tc66c.FirmwareChunk) {
+	const edge = 3
+
+	fmt.Printf("Chunk plan (%d chunks):\n", len(chunks))
+	fmt.Printf("%-7s | %-8s | %-5s\n", "Chunk", "Offset", "Size")
+	fmt.Println("--------+----------+------")
+
+	for i, chunk := range chunks {
+		if len(chunks) > 2*edge && i == edge {
+			fmt.Printf("%-7s | %-8s | %-5s\n", "...", "...", "...")
+		}
+		if len(chunks) > 2*edge && i >= edge && i < len(chunks)-edge {
+			continue
+		}
+		fmt.Printf("%-7d | 0x%06X | %-5d\n", chunk.Index, chunk.Offset, chunk.Size)
+	}
+}
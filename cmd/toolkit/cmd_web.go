@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -20,8 +21,10 @@ import (
 var webuiFS embed.FS
 
 var (
-	webPortFlag string
-	webAddrFlag string
+	webPortFlag        string
+	webAddrFlag        string
+	webHistoryFlag     int
+	webFirmwareDirFlag string
 )
 
 var webCmd = &cobra.Command{
@@ -30,13 +33,19 @@ var webCmd = &cobra.Command{
 	Long: `Start a web server that serves a UI for interacting with TC66C devices.
 The UI provides real-time monitoring via WebSocket connection.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		executeWeb(webAddrFlag, webPortFlag)
+		if webHistoryFlag < 0 {
+			fmt.Fprintf(os.Stderr, "Error: --history must be 0 or more, got %d\n", webHistoryFlag)
+			os.Exit(1)
+		}
+		executeWeb(webAddrFlag, webPortFlag, webHistoryFlag, webFirmwareDirFlag)
 	},
 }
 
 func init() {
 	webCmd.Flags().StringVarP(&webAddrFlag, "address", "a", "localhost", "Address to bind the web server")
 	webCmd.Flags().StringVarP(&webPortFlag, "web-port", "w", "8080", "Port for the web server")
+	webCmd.Flags().IntVar(&webHistoryFlag, "history", 10000, "Number of readings kept in memory per device (0 disables history)")
+	webCmd.Flags().StringVar(&webFirmwareDirFlag, "firmware-dir", "firmware", "Directory with bundled firmware images offered in the UI")
 	rootCmd.AddCommand(webCmd)
 }
 
@@ -58,6 +67,7 @@ type WSResponse struct {
 	Command string      `json:"command"`
 	Success bool        `json:"success"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"` // Kind of device error, as in APIError
 	Data    interface{} `json:"data,omitempty"`
 }
 
@@ -76,28 +86,48 @@ type PollRequest struct {
 	Interval int    `json:"interval"` // interval in milliseconds
 }
 
+// HistoryRequest represents the data for a history command
+type HistoryRequest struct {
+	Port  string `json:"port"`
+	From  int64  `json:"from,omitempty"`  // start of the range in Unix milliseconds (0 = oldest)
+	To    int64  `json:"to,omitempty"`    // end of the range in Unix milliseconds (0 = newest)
+	Limit int    `json:"limit,omitempty"` // maximum number of newest samples to return (0 = all)
+}
+
+// HistoryResponse contains the samples returned by a history command or
+// sent as backfill when polling starts
+type HistoryResponse struct {
+	Port    string          `json:"port"`
+	Samples []HistorySample `json:"samples"`
+}
+
 // Client represents a WebSocket client connection
 type Client struct {
-	conn          *websocket.Conn
-	device        *tc66c.TC66C
-	pollTicker    *time.Ticker
-	pollStop      chan bool
-	mu            sync.Mutex
-	isPolling     bool
+	conn        *websocket.Conn
+	broker      *DeviceBroker
+	firmwareDir string
+	sub         *Subscription
+	mu          sync.Mutex
 }
 
-func executeWeb(addr, port string) {
+func executeWeb(addr, port string, historySize int, firmwareDir string) {
 	// Serve static files from embedded webui directory
 	staticFS, err := fs.Sub(webuiFS, "webui")
 	if err != nil {
 		log.Fatalf("Failed to access webui directory: %v", err)
 	}
 
+	broker := NewDeviceBroker(historySize)
+
 	http.Handle("/", http.FileServer(http.FS(staticFS)))
-	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(broker, firmwareDir, w, r)
+	})
+	registerAPI(http.DefaultServeMux, broker)
 
 	listenAddr := fmt.Sprintf("%s:%s", addr, port)
 	fmt.Printf("Starting web server on http://%s\n", listenAddr)
+	fmt.Printf("REST API available at http://%s/api (OpenAPI: /api/openapi.json)\n", listenAddr)
 	fmt.Printf("Press Ctrl+C to stop the server\n")
 
 	if err := http.ListenAndServe(listenAddr, nil); err != nil {
@@ -105,7 +135,7 @@ func executeWeb(addr, port string) {
 	}
 }
 
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+func handleWebSocket(broker *DeviceBroker, firmwareDir string, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
@@ -113,8 +143,9 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &Client{
-		conn:     conn,
-		pollStop: make(chan bool),
+		conn:        conn,
+		broker:      broker,
+		firmwareDir: firmwareDir,
 	}
 
 	defer func() {
@@ -146,8 +177,18 @@ func (c *Client) handleMessage(msg WSMessage) {
 		c.handleListSerial()
 	case "poll":
 		c.handlePoll(msg.Data)
+	case "history":
+		c.handleHistory(msg.Data)
 	case "stop":
 		c.handleStop()
+	case "device-mode":
+		c.handleDeviceMode(msg.Data)
+	case "list-firmware":
+		c.handleListFirmware()
+	case "firmware-update":
+		c.handleFirmwareUpdate(msg.Data)
+	case "screen-next", "screen-prev", "screen-rotate":
+		c.handleScreen(msg.Command)
 	case "close":
 		c.handleClose()
 	default:
@@ -160,16 +201,30 @@ func (c *Client) handleMessage(msg WSMessage) {
 }
 
 func (c *Client) handleListSerial() {
-	ports, err := enumerator.GetDetailedPortsList()
+	portInfos, err := listSerialPorts()
 	if err != nil {
 		c.sendResponse(WSResponse{
 			Command: "list-serial",
 			Success: false,
-			Error:   fmt.Sprintf("failed to list serial ports: %v", err),
+			Error:   err.Error(),
 		})
 		return
 	}
 
+	c.sendResponse(WSResponse{
+		Command: "list-serial",
+		Success: true,
+		Data:    portInfos,
+	})
+}
+
+// listSerialPorts returns the serial ports reported by the OS
+func listSerialPorts() ([]SerialPortInfo, error) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list serial ports: %w", err)
+	}
+
 	portInfos := make([]SerialPortInfo, 0, len(ports))
 
 	for _, port := range ports {
@@ -184,11 +239,7 @@ func (c *Client) handleListSerial() {
 		portInfos = append(portInfos, portInfo)
 	}
 
-	c.sendResponse(WSResponse{
-		Command: "list-serial",
-		Success: true,
-		Data:    portInfos,
-	})
+	return portInfos, nil
 }
 
 func (c *Client) handlePoll(data json.RawMessage) {
@@ -203,38 +254,36 @@ func (c *Client) handlePoll(data json.RawMessage) {
 	}
 
 	// Validate interval
-	if req.Interval < 100 {
-		req.Interval = 100 // minimum 100ms
-	}
+	// The broker slows polling down further to what the meter keeps up with
+	req.Interval = max(req.Interval, int(minRequestInterval.Milliseconds()))
 
 	// Stop existing polling if any
 	c.stopPolling()
 
-	// Connect to device
-	device, err := tc66c.NewTC66C(req.Port)
+	port, err := resolvePortName(req.Port, c.broker.IsOpen)
 	if err != nil {
 		c.sendResponse(WSResponse{
 			Command: "poll",
 			Success: false,
-			Error:   fmt.Sprintf("failed to connect to device: %v", err),
+			Error:   err.Error(),
 		})
 		return
 	}
+	req.Port = port
 
-	// Validate device is in firmware mode
-	if device.Mode != tc66c.ModeFirmware {
-		device.Close()
+	// Subscribe to the shared device, opening it if nobody else is using it
+	sub, backfill, err := c.broker.Subscribe(req.Port, time.Duration(req.Interval)*time.Millisecond)
+	if err != nil {
 		c.sendResponse(WSResponse{
 			Command: "poll",
 			Success: false,
-			Error:   fmt.Sprintf("device must be in firmware mode (current mode: %s)", device.Mode),
+			Error:   err.Error(),
 		})
 		return
 	}
 
 	c.mu.Lock()
-	c.device = device
-	c.isPolling = true
+	c.sub = sub
 	c.mu.Unlock()
 
 	// Send success response
@@ -244,44 +293,132 @@ func (c *Client) handlePoll(data json.RawMessage) {
 		Data:    map[string]interface{}{"port": req.Port, "interval": req.Interval},
 	})
 
-	// Start polling in a goroutine
-	go c.pollDevice(time.Duration(req.Interval) * time.Millisecond)
+	// Backfill the readings collected before this client subscribed
+	c.sendResponse(WSResponse{
+		Command: "history",
+		Success: true,
+		Data:    HistoryResponse{Port: req.Port, Samples: backfill},
+	})
+
+	sub.Start(func(sample *HistorySample, err error) {
+		c.sendReading(sub.Device(), sample, err)
+	})
 }
 
-func (c *Client) pollDevice(interval time.Duration) {
-	c.pollTicker = time.NewTicker(interval)
-	defer c.pollTicker.Stop()
+// PollData is a reading sent to web clients along with the reading
+// counters and measured speed of the device
+type PollData struct {
+	*HistorySample
+	Stats         tc66c.Stats `json:"stats"`
+	MinIntervalMs int64       `json:"min_interval_ms"` // Fastest interval the device is polled at
+}
 
-	for {
-		select {
-		case <-c.pollStop:
-			return
-		case <-c.pollTicker.C:
-			c.mu.Lock()
-			if c.device == nil {
-				c.mu.Unlock()
-				return
-			}
+// sendReading forwards a reading (or polling error) from the shared device
+func (c *Client) sendReading(sd *SharedDevice, sample *HistorySample, err error) {
+	data := PollData{
+		HistorySample: sample,
+		Stats:         sd.Stats(),
+		MinIntervalMs: sd.MinInterval().Milliseconds(),
+	}
+
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "poll-data",
+			Success: false,
+			Error:   fmt.Sprintf("failed to get reading: %v", err),
+			Code:    newAPIError(err).Code,
+			Data:    data,
+		})
+		return
+	}
 
-			reading, err := c.device.GetReading()
-			c.mu.Unlock()
+	c.sendResponse(WSResponse{
+		Command: "poll-data",
+		Success: true,
+		Data:    data,
+	})
+}
 
-			if err != nil {
-				c.sendResponse(WSResponse{
-					Command: "poll-data",
-					Success: false,
-					Error:   fmt.Sprintf("failed to get reading: %v", err),
-				})
-				continue
-			}
+func (c *Client) handleHistory(data json.RawMessage) {
+	var req HistoryRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.sendResponse(WSResponse{
+			Command: "history",
+			Success: false,
+			Error:   fmt.Sprintf("invalid history request: %v", err),
+		})
+		return
+	}
+
+	port, err := resolvePortName(req.Port, c.broker.IsOpen)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "history",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Port = port
+
+	var from, to time.Time
+	if req.From > 0 {
+		from = time.UnixMilli(req.From)
+	}
+	if req.To > 0 {
+		to = time.UnixMilli(req.To)
+	}
+
+	samples := []HistorySample{}
+	if history := c.broker.History(req.Port); history != nil {
+		samples = history.Range(from, to, req.Limit)
+	}
+
+	c.sendResponse(WSResponse{
+		Command: "history",
+		Success: true,
+		Data:    HistoryResponse{Port: req.Port, Samples: samples},
+	})
+}
+
+func (c *Client) handleScreen(command string) {
+	c.mu.Lock()
+	sub := c.sub
+	c.mu.Unlock()
+
+	if sub == nil {
+		c.sendResponse(WSResponse{
+			Command: command,
+			Success: false,
+			Error:   "no device connected, start polling first",
+		})
+		return
+	}
 
-			c.sendResponse(WSResponse{
-				Command: "poll-data",
-				Success: true,
-				Data:    reading,
-			})
+	err := sub.Device().Do(func(device tc66c.Meter) error {
+		switch command {
+		case "screen-next":
+			return device.NextPage()
+		case "screen-prev":
+			return device.PreviousPage()
+		default:
+			return device.RotateScreen()
 		}
+	})
+
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: command,
+			Success: false,
+			Error:   fmt.Sprintf("failed to send screen command: %v", err),
+		})
+		return
 	}
+
+	c.sendResponse(WSResponse{
+		Command: command,
+		Success: true,
+	})
 }
 
 func (c *Client) handleStop() {
@@ -305,21 +442,12 @@ func (c *Client) handleClose() {
 
 func (c *Client) stopPolling() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.isPolling {
-		c.pollStop <- true
-		c.isPolling = false
-	}
-
-	if c.device != nil {
-		c.device.Close()
-		c.device = nil
-	}
+	sub := c.sub
+	c.sub = nil
+	c.mu.Unlock()
 
-	if c.pollTicker != nil {
-		c.pollTicker.Stop()
-		c.pollTicker = nil
+	if sub != nil {
+		sub.Unsubscribe()
 	}
 }
 
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebHistoryResolvesLabel(t *testing.T) {
+	const port = "/dev/ttyFAKE0"
+
+	t.Setenv("TC66C_INVENTORY", filepath.Join(t.TempDir(), "inventory.json"))
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.Observe(port, &brokerTestReading).Label = "bench"
+	if err := inv.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Poll the meter until its history has a reading
+	broker, _ := fakeBroker(10)
+	sub, _, err := broker.Subscribe(port, defaultMinInterval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	events := make(chan error, 10)
+	sub.Start(func(sample *HistorySample, err error) { events <- err })
+	waitEvent(t, events, "a reading", func(err error) bool { return err == nil })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleWebSocket(broker, "", w, r)
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(WSMessage{Command: "history", Data: json.RawMessage(`{"port": "label:bench"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		Success bool            `json:"success"`
+		Error   string          `json:"error"`
+		Data    HistoryResponse `json:"data"`
+	}
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Success || resp.Data.Port != port || len(resp.Data.Samples) == 0 {
+		t.Errorf("history of label:bench = %+v, want the readings of %s", resp, port)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is prepended to flag names to form their environment variable,
+// e.g. TC66C_PORT or TC66C_WEB_PORT
+const envPrefix = "TC66C_"
+
+// Profile is a named set of settings. Settings are command flag names
+// (port, interval, json, web-port, ...) and apply to every command that has
+// that flag
+type Profile struct {
+	Serial   uint32            `yaml:"serial,omitempty"`
+	Labels   map[string]uint32 `yaml:"labels,omitempty"`
+	Settings map[string]any    `yaml:",inline"`
+}
+
+// Config is the toolkit configuration file
+type Config struct {
+	path     string
+	Profile  string              `yaml:"profile,omitempty"`
+	Defaults Profile             `yaml:"defaults,omitempty"`
+	Labels   map[string]uint32   `yaml:"labels,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+}
+
+var (
+	configFileFlag string
+	profileFlag    string
+
+	// activeConfig is the configuration loaded for the running command
+	activeConfig = &Config{}
+
+	// commandLineFlags holds the flags given on the command line, recorded
+	// the first time the config is applied so a reload never overrides them
+	commandLineFlags map[string]bool
+)
+
+// configPath returns the location of the configuration file
+func configPath() string {
+	if path := os.Getenv(envPrefix + "CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "tc66c-toolkit", "config.yaml")
+}
+
+// loadConfig reads the configuration file. A missing file is only an error
+// if it was asked for explicitly
+func loadConfig(path string, explicit bool) (*Config, error) {
+	config := &Config{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return config, nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Select returns the named profile, or nil for an empty name
+func (c *Config) Select(name string) (*Profile, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok || profile == nil {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown profile %q, %s defines no profiles", name, c.path)
+		}
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(names, ", "))
+	}
+
+	return profile, nil
+}
+
+// LabelSerial returns the serial number a label is mapped to in the
+// configuration, with the active profile's labels taking precedence
+func (c *Config) LabelSerial(label string) (uint32, bool) {
+	for _, labels := range []map[string]uint32{c.activeLabels(), c.Labels} {
+		for name, serialNumber := range labels {
+			if strings.EqualFold(name, label) {
+				return serialNumber, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// activeLabels returns the labels of the selected profile
+func (c *Config) activeLabels() map[string]uint32 {
+	if profile, err := c.Select(c.Profile); err == nil && profile != nil {
+		return profile.Labels
+	}
+	return nil
+}
+
+// settings returns the profile settings, with the serial selection turned
+// into a port
+func (p *Profile) settings() map[string]any {
+	if p == nil {
+		return nil
+	}
+
+	settings := make(map[string]any, len(p.Settings)+1)
+	for name, value := range p.Settings {
+		settings[name] = value
+	}
+	if _, ok := settings["port"]; !ok && p.Serial != 0 {
+		settings["port"] = fmt.Sprintf("%s%d", serialPortPrefix, p.Serial)
+	}
+
+	return settings
+}
+
+// settingValue formats a configuration value as a flag argument
+func settingValue(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// settingEnv returns the environment variable that overrides a flag
+func settingEnv(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// knownSettings returns the names of all flags of every command
+func knownSettings(cmd *cobra.Command, names map[string]bool) map[string]bool {
+	if names == nil {
+		names = map[string]bool{}
+	}
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) { names[f.Name] = true })
+	cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) { names[f.Name] = true })
+	for _, sub := range cmd.Commands() {
+		knownSettings(sub, names)
+	}
+
+	return names
+}
+
+// checkSettings reports settings that are not the name of any flag, which
+// are most likely typos
+func checkSettings(root *cobra.Command, where string, settings map[string]any) error {
+	known := knownSettings(root, nil)
+	for name := range settings {
+		if !known[name] {
+			return fmt.Errorf("unknown setting %q in %s", name, where)
+		}
+	}
+	return nil
+}
+
+// applyConfig loads the configuration file and fills in every flag of cmd
+// not given on the command line. The precedence is: command line,
+// environment, selected profile, config defaults, built-in default. It can
+// be called again to reload the configuration
+func applyConfig(cmd *cobra.Command) error {
+	if commandLineFlags == nil {
+		commandLineFlags = map[string]bool{}
+		cmd.Flags().Visit(func(f *pflag.Flag) { commandLineFlags[f.Name] = true })
+	}
+
+	path := configPath()
+	explicit := os.Getenv(envPrefix+"CONFIG") != ""
+	if commandLineFlags["config"] {
+		path, explicit = configFileFlag, true
+	}
+
+	config, err := loadConfig(path, explicit)
+	if err != nil {
+		return err
+	}
+
+	if name := os.Getenv(envPrefix + "PROFILE"); name != "" {
+		config.Profile = name
+	}
+	if commandLineFlags["profile"] {
+		config.Profile = profileFlag
+	}
+
+	profile, err := config.Select(config.Profile)
+	if err != nil {
+		return err
+	}
+
+	defaults := config.Defaults.settings()
+	settings := profile.settings()
+	if err := checkSettings(cmd.Root(), "config defaults", defaults); err != nil {
+		return err
+	}
+	if err := checkSettings(cmd.Root(), fmt.Sprintf("profile %q", config.Profile), settings); err != nil {
+		return err
+	}
+
+	activeConfig = config
+
+	var setErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if setErr != nil || commandLineFlags[f.Name] || f.Name == "config" || f.Name == "profile" || f.Name == "help" {
+			return
+		}
+
+		value, ok := os.LookupEnv(settingEnv(f.Name))
+		source := settingEnv(f.Name)
+		if !ok {
+			var raw any
+			if raw, ok = settings[f.Name]; ok {
+				source = fmt.Sprintf("profile %q", config.Profile)
+			} else if raw, ok = defaults[f.Name]; ok {
+				source = "config defaults"
+			}
+			value = settingValue(raw)
+		}
+		if !ok {
+			// Restore the built-in default if a reload removed the setting
+			if f.Changed && f.Value.String() != f.DefValue {
+				value, source = f.DefValue, "built-in default"
+			} else {
+				return
+			}
+		}
+
+		if err := setFlag(cmd.Flags(), f, value); err != nil {
+			setErr = fmt.Errorf("invalid value %q for %s from %s: %w", value, f.Name, source, err)
+		}
+	})
+
+	return setErr
+}
+
+// setFlag sets a flag to a setting value. List flags are replaced rather
+// than appended to, so reloading the config does not repeat their items
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, value string) error {
+	list, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return flags.Set(f.Name, value)
+	}
+
+	// The default of a list flag is shown as [a,b]
+	if value == f.DefValue {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	}
+
+	items := []string{}
+	if value != "" {
+		var err error
+		if items, err = csv.NewReader(strings.NewReader(value)).Read(); err != nil {
+			return err
+		}
+	}
+	if err := list.Replace(items); err != nil {
+		return err
+	}
+	f.Changed = true
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// testConfig has defaults, a profile overriding them and a profile that
+// selects a serial number and sets a list, for the precedence tests
+const testConfig = `
+defaults:
+  interval: 1s
+  json: true
+profiles:
+  bench:
+    interval: 2s
+    port: /dev/ttyBENCH
+  charger:
+    serial: 12345
+    alarm: ["voltage>5.5", "current<0.1"]
+`
+
+// configValues holds the settings of the command built by configCommand
+type configValues struct {
+	interval time.Duration
+	port     string
+	json     bool
+	alarm    []string
+}
+
+// configCommand returns a root command with the config flags and a few
+// settings, and clears the state kept by applyConfig between runs
+func configCommand(t *testing.T) (*cobra.Command, *configValues) {
+	t.Helper()
+
+	for _, name := range []string{"CONFIG", "PROFILE", "INTERVAL", "PORT", "JSON", "ALARM"} {
+		t.Setenv(envPrefix+name, "")
+		os.Unsetenv(envPrefix + name)
+	}
+	commandLineFlags = nil
+	t.Cleanup(func() {
+		commandLineFlags = nil
+		activeConfig = &Config{}
+	})
+
+	values := &configValues{}
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringVar(&configFileFlag, "config", "", "")
+	cmd.Flags().StringVar(&profileFlag, "profile", "", "")
+	cmd.Flags().DurationVar(&values.interval, "interval", 500*time.Millisecond, "")
+	cmd.Flags().StringVar(&values.port, "port", "", "")
+	cmd.Flags().BoolVar(&values.json, "json", false, "")
+	cmd.Flags().StringSliceVar(&values.alarm, "alarm", nil, "")
+	return cmd, values
+}
+
+// writeConfig writes a config file in a temporary directory
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		env      map[string]string
+		args     []string
+		interval time.Duration
+		port     string
+		json     bool
+		alarm    string
+	}{
+		{name: "built-in", config: "{}", interval: 500 * time.Millisecond},
+		{name: "defaults", config: testConfig, interval: time.Second, json: true},
+		{name: "profile over defaults", config: testConfig, args: []string{"--profile", "bench"}, interval: 2 * time.Second, port: "/dev/ttyBENCH", json: true},
+		{name: "default profile", config: "profile: bench\n" + testConfig, interval: 2 * time.Second, port: "/dev/ttyBENCH", json: true},
+		{name: "env profile", config: testConfig, env: map[string]string{"PROFILE": "bench"}, interval: 2 * time.Second, port: "/dev/ttyBENCH", json: true},
+		{name: "env over profile", config: testConfig, env: map[string]string{"INTERVAL": "3s", "JSON": "false"}, args: []string{"--profile", "bench"}, interval: 3 * time.Second, port: "/dev/ttyBENCH"},
+		{name: "command line over env", config: testConfig, env: map[string]string{"INTERVAL": "3s"}, args: []string{"--profile", "bench", "--interval", "4s"}, interval: 4 * time.Second, port: "/dev/ttyBENCH", json: true},
+		{name: "command line profile over env", config: testConfig, env: map[string]string{"PROFILE": "charger"}, args: []string{"--profile", "bench"}, interval: 2 * time.Second, port: "/dev/ttyBENCH", json: true},
+		{name: "serial and list", config: testConfig, args: []string{"--profile", "charger"}, interval: time.Second, port: "serial:12345", json: true, alarm: "voltage>5.5,current<0.1"},
+		{name: "command line list", config: testConfig, args: []string{"--profile", "charger", "--alarm", "power>10"}, interval: time.Second, port: "serial:12345", json: true, alarm: "power>10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, values := configCommand(t)
+			for name, value := range tt.env {
+				t.Setenv(envPrefix+name, value)
+			}
+			args := append([]string{"--config", writeConfig(t, tt.config)}, tt.args...)
+			if err := cmd.ParseFlags(args); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := applyConfig(cmd); err != nil {
+				t.Fatalf("applyConfig: %v", err)
+			}
+			if values.interval != tt.interval || values.port != tt.port || values.json != tt.json || strings.Join(values.alarm, ",") != tt.alarm {
+				t.Errorf("interval %v, port %q, json %t, alarm %q, want %v, %q, %t, %q",
+					values.interval, values.port, values.json, values.alarm, tt.interval, tt.port, tt.json, tt.alarm)
+			}
+		})
+	}
+}
+
+func TestApplyConfigReload(t *testing.T) {
+	cmd, values := configCommand(t)
+	path := writeConfig(t, testConfig)
+	if err := cmd.ParseFlags([]string{"--config", path, "--port", "/dev/ttyCLI"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err != nil {
+		t.Fatalf("applyConfig: %v", err)
+	}
+
+	// A setting removed from the file falls back to the built-in default,
+	// and the command line still wins
+	if err := os.WriteFile(path, []byte("profile: bench\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err == nil {
+		t.Fatal("reload selected a profile the file does not define")
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if values.interval != 500*time.Millisecond || values.json || values.port != "/dev/ttyCLI" {
+		t.Errorf("interval %v, json %t, port %q after reload, want the built-in defaults and the command line port", values.interval, values.json, values.port)
+	}
+
+	// Lists are replaced on every reload rather than appended to, and a
+	// list removed from the file is emptied
+	if err := os.WriteFile(path, []byte("defaults:\n  alarm: [\"voltage>5.5\", \"current<0.1\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := applyConfig(cmd); err != nil {
+			t.Fatalf("reload: %v", err)
+		}
+		if got := strings.Join(values.alarm, "|"); got != "voltage>5.5|current<0.1" {
+			t.Errorf("alarm %q after reload, want the two rules of the file", values.alarm)
+		}
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(cmd); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(values.alarm) != 0 {
+		t.Errorf("alarm %q after the list was removed, want none", values.alarm)
+	}
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown profile", config: testConfig, args: []string{"--profile", "nope"}, wantErr: `unknown profile "nope" (available: bench, charger)`},
+		{name: "unknown env profile", config: testConfig, env: map[string]string{"PROFILE": "nope"}, wantErr: `unknown profile "nope"`},
+		{name: "no profiles", config: "{}", args: []string{"--profile", "bench"}, wantErr: "defines no profiles"},
+		{name: "bad default", config: "defaults:\n  interval: soon\n", wantErr: `invalid value "soon" for interval from config defaults`},
+		{name: "bad profile value", config: "profiles:\n  p:\n    json: maybe\n", args: []string{"--profile", "p"}, wantErr: `invalid value "maybe" for json from profile "p"`},
+		{name: "bad env value", config: "{}", env: map[string]string{"INTERVAL": "soon"}, wantErr: `invalid value "soon" for interval from TC66C_INTERVAL`},
+		{name: "unknown default", config: "defaults:\n  intervall: 1s\n", wantErr: `unknown setting "intervall" in config defaults`},
+		{name: "unknown profile setting", config: "profiles:\n  p:\n    prot: x\n", args: []string{"--profile", "p"}, wantErr: `unknown setting "prot" in profile "p"`},
+		{name: "invalid YAML", config: "profiles: [", wantErr: "failed to parse config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, _ := configCommand(t)
+			for name, value := range tt.env {
+				t.Setenv(envPrefix+name, value)
+			}
+			args := append([]string{"--config", writeConfig(t, tt.config)}, tt.args...)
+			if err := cmd.ParseFlags(args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfig(cmd)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("applyConfig error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("missing explicit file", func(t *testing.T) {
+		cmd, _ := configCommand(t)
+		if err := cmd.ParseFlags([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyConfig(cmd); err == nil || !strings.Contains(err.Error(), "failed to read config") {
+			t.Errorf("applyConfig error = %v, want a read failure", err)
+		}
+	})
+
+	t.Run("missing default file", func(t *testing.T) {
+		cmd, values := configCommand(t)
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		t.Setenv("HOME", t.TempDir())
+		if err := applyConfig(cmd); err != nil {
+			t.Errorf("applyConfig without a config file: %v", err)
+		}
+		if values.interval != 500*time.Millisecond {
+			t.Errorf("interval = %v, want the built-in default", values.interval)
+		}
+	})
+}
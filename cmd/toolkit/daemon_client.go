@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+var (
+	daemonSocketFlag string
+	noDaemonFlag     bool
+)
+
+// meterConn is a meter reached either directly over its serial port or
+// through a running daemon
+type meterConn interface {
+	GetReading() (*tc66c.Reading, error)
+	GetRecordings() ([]*tc66c.RecordingEntry, error)
+	PortName() string
+	Close() error
+}
+
+// DaemonClient talks to the meter on one port through the daemon control
+// socket, which serves the same REST API as the web server
+type DaemonClient struct {
+	client *http.Client
+	port   string
+}
+
+// daemonSocketPath returns the location of the daemon control socket
+func daemonSocketPath() string {
+	if daemonSocketFlag != "" {
+		return daemonSocketFlag
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "tc66c-toolkit.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tc66c-toolkit-%d.sock", os.Getuid()))
+}
+
+// dialDaemon returns a client for the meter on port if a daemon is
+// listening on the control socket, or nil otherwise
+func dialDaemon(port string) *DaemonClient {
+	if noDaemonFlag {
+		return nil
+	}
+	return dialDaemonSocket(port)
+}
+
+// dialDaemonSocket is dialDaemon regardless of --no-daemon
+func dialDaemonSocket(port string) *DaemonClient {
+	path := daemonSocketPath()
+	conn, err := net.DialTimeout("unix", path, 200*time.Millisecond)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+
+	dialer := &net.Dialer{Timeout: time.Second}
+	return &DaemonClient{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+		port: port,
+	}
+}
+
+// connectMeter returns the meter on port, going through the daemon if one
+// is running so the port can be shared
+func connectMeter(port string) meterConn {
+	if client := useDaemon(port); client != nil {
+		return client
+	}
+	return connectMeterDirect(port, nil)
+}
+
+// useDaemon returns a client for the meter on port if a daemon is running,
+// telling the user it is used
+func useDaemon(port string) *DaemonClient {
+	client := dialDaemon(port)
+	if client != nil {
+		fmt.Fprintf(os.Stderr, "Using daemon on %s for %s\n", daemonSocketPath(), port)
+	}
+	return client
+}
+
+// requirePortFree exits with an error if a running daemon holds the meter
+// on port, which commands opening the port directly would fight over
+func requirePortFree(port string) {
+	client := dialDaemonSocket(port)
+	if client == nil {
+		return
+	}
+	defer client.Close()
+
+	if client.Holds() {
+		fmt.Fprintf(os.Stderr, "Error: the daemon on %s is using %s, stop the daemon first\n", daemonSocketPath(), port)
+		os.Exit(1)
+	}
+}
+
+// url returns the daemon API URL of an endpoint of the client's device
+func (d *DaemonClient) url(endpoint string) string {
+	return "http://daemon/api/devices/" + url.PathEscape(d.port) + endpoint
+}
+
+// get requests an endpoint of the client's device and decodes the response
+func (d *DaemonClient) get(endpoint string, v interface{}) error {
+	resp, err := d.client.Get(d.url(endpoint))
+	if err != nil {
+		return fmt.Errorf("daemon request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return daemonError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid daemon response: %w", err)
+	}
+	return nil
+}
+
+// daemonError turns an API error response into an error
+func daemonError(resp *http.Response) error {
+	var apiErr APIError
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+		return fmt.Errorf("daemon returned %s", resp.Status)
+	}
+	return &remoteError{message: apiErr.Error, kind: deviceError(apiErr.Code)}
+}
+
+// remoteError is a device error reported by the daemon. It unwraps to the
+// library error it was classified as, so errors.Is works across the socket
+type remoteError struct {
+	message string
+	kind    error
+}
+
+func (e *remoteError) Error() string {
+	return "daemon: " + e.message
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.kind
+}
+
+// GetReading returns the current reading of the meter
+func (d *DaemonClient) GetReading() (*tc66c.Reading, error) {
+	var reading tc66c.Reading
+	if err := d.get("/reading", &reading); err != nil {
+		return nil, err
+	}
+	return &reading, nil
+}
+
+// GetRecordings returns the recordings stored on the meter
+func (d *DaemonClient) GetRecordings() ([]*tc66c.RecordingEntry, error) {
+	var recordings []*tc66c.RecordingEntry
+	if err := d.get("/recordings", &recordings); err != nil {
+		return nil, err
+	}
+	return recordings, nil
+}
+
+// Stats returns the daemon's reading counters for the meter
+func (d *DaemonClient) Stats() (tc66c.Stats, error) {
+	var stats tc66c.Stats
+	err := d.get("/stats", &stats)
+	return stats, err
+}
+
+// Holds reports whether the daemon has the meter on the client's port open
+func (d *DaemonClient) Holds() bool {
+	_, err := d.Stats()
+	return err == nil
+}
+
+// NextPage switches the meter screen to the next page
+func (d *DaemonClient) NextPage() error {
+	return d.screen("next")
+}
+
+// PreviousPage switches the meter screen to the previous page
+func (d *DaemonClient) PreviousPage() error {
+	return d.screen("prev")
+}
+
+// RotateScreen rotates the meter screen
+func (d *DaemonClient) RotateScreen() error {
+	return d.screen("rotate")
+}
+
+// screen sends a screen action to the meter
+func (d *DaemonClient) screen(action string) error {
+	body, err := json.Marshal(ScreenRequest{Action: action})
+	if err != nil {
+		return err
+	}
+
+	resp, err := d.client.Post(d.url("/screen"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("daemon request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return daemonError(resp)
+	}
+	return nil
+}
+
+// PortName returns the port as given to the daemon
+func (d *DaemonClient) PortName() string {
+	return d.port
+}
+
+// Close releases idle connections to the daemon
+func (d *DaemonClient) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+// Stream delivers readings from the daemon's shared poller at the given
+// interval until the stream ends
+func (d *DaemonClient) Stream(interval time.Duration, deliver func(*HistorySample, error)) error {
+	resp, err := d.client.Get(d.url(fmt.Sprintf("/stream?interval=%d", interval.Milliseconds())))
+	if err != nil {
+		return fmt.Errorf("daemon request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return daemonError(resp)
+	}
+
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			switch event {
+			case "reading":
+				var sample HistorySample
+				if err := json.Unmarshal([]byte(data), &sample); err != nil {
+					deliver(nil, fmt.Errorf("invalid daemon event: %w", err))
+				} else {
+					deliver(&sample, nil)
+				}
+			case "error":
+				var apiErr APIError
+				json.Unmarshal([]byte(data), &apiErr)
+				deliver(nil, &remoteError{message: apiErr.Error, kind: deviceError(apiErr.Code)})
+			}
+			event, data = "", ""
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("daemon stream failed: %w", err)
+	}
+	return fmt.Errorf("daemon closed the stream")
+}
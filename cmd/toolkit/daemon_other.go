@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// monotonicMicroseconds is only needed for systemd, which runs on Unix
+func monotonicMicroseconds() int64 {
+	return 0
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/skgsergio/tc66-toolkit/lib/tc66cpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxStreamErrors is the number of consecutive polling errors after which
+// StreamReadings gives up on the meter
+const maxStreamErrors = 3
+
+// meterServer implements the gRPC Meter service on top of the shared
+// device broker, so gRPC clients can watch the same meters
+type meterServer struct {
+	tc66cpb.UnimplementedMeterServer
+	broker *DeviceBroker
+}
+
+func (s *meterServer) ListDevices(ctx context.Context, req *tc66cpb.ListDevicesRequest) (*tc66cpb.ListDevicesResponse, error) {
+	ports, err := listSerialPorts()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &tc66cpb.ListDevicesResponse{}
+	for _, port := range ports {
+		resp.Devices = append(resp.Devices, &tc66cpb.Device{
+			Id:              filepath.Base(port.Name),
+			Name:            port.Name,
+			IsUsb:           port.IsUSB,
+			Vid:             port.VID,
+			Pid:             port.PID,
+			UsbSerialNumber: port.SerialNumber,
+			Active:          s.broker.IsOpen(port.Name),
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *meterServer) GetReading(ctx context.Context, req *tc66cpb.GetReadingRequest) (*tc66cpb.Reading, error) {
+	var reading *tc66c.Reading
+
+	err := s.withDevice(req.GetDevice(), func(device tc66c.Meter) error {
+		var err error
+		reading, err = device.GetReading()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return readingToProto(reading), nil
+}
+
+func (s *meterServer) StreamReadings(req *tc66cpb.StreamReadingsRequest, stream tc66cpb.Meter_StreamReadingsServer) error {
+	if req.GetDevice() == "" {
+		return status.Error(codes.InvalidArgument, "device is required")
+	}
+	if req.GetFields() != nil && !req.GetFields().IsValid(&tc66cpb.Reading{}) {
+		return status.Errorf(codes.InvalidArgument, "invalid field mask: %v", req.GetFields().GetPaths())
+	}
+
+	interval := 500
+	if req.GetIntervalMs() > 0 {
+		interval = int(req.GetIntervalMs())
+	}
+	// The broker slows polling down further to what the meter keeps up with
+	interval = max(interval, int(minRequestInterval.Milliseconds()))
+
+	sub, _, err := s.broker.Subscribe(resolvePort(s.broker, req.GetDevice()), time.Duration(interval)*time.Millisecond)
+	if err != nil {
+		return deviceStatus(err)
+	}
+	defer sub.Unsubscribe()
+
+	ctx := stream.Context()
+	events := make(chan subscriptionEvent, 1)
+	sub.Start(func(sample *HistorySample, err error) {
+		select {
+		case events <- subscriptionEvent{sample: sample, err: err}:
+		case <-ctx.Done():
+		}
+	})
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-events:
+			if event.err != nil {
+				// The meter will not answer in this mode until it is replugged
+				failures++
+				if failures >= maxStreamErrors || errors.Is(event.err, tc66c.ErrWrongMode) {
+					return deviceStatus(event.err)
+				}
+				continue
+			}
+			failures = 0
+
+			err := stream.Send(&tc66cpb.ReadingSample{
+				Timestamp: timestamppb.New(event.sample.Timestamp),
+				Reading:   maskReading(readingToProto(event.sample.Reading), req.GetFields()),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *meterServer) GetRecordings(ctx context.Context, req *tc66cpb.GetRecordingsRequest) (*tc66cpb.GetRecordingsResponse, error) {
+	var recordings []*tc66c.RecordingEntry
+
+	err := s.withDevice(req.GetDevice(), func(device tc66c.Meter) error {
+		var err error
+		recordings, err = device.GetRecordings()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &tc66cpb.GetRecordingsResponse{}
+	for _, entry := range recordings {
+		resp.Entries = append(resp.Entries, &tc66cpb.RecordingEntry{
+			Voltage: entry.Voltage,
+			Current: entry.Current,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *meterServer) ScreenControl(ctx context.Context, req *tc66cpb.ScreenControlRequest) (*tc66cpb.ScreenControlResponse, error) {
+	var action func(device tc66c.Meter) error
+	switch req.GetAction() {
+	case tc66cpb.ScreenControlRequest_ACTION_NEXT:
+		action = tc66c.Meter.NextPage
+	case tc66cpb.ScreenControlRequest_ACTION_PREV:
+		action = tc66c.Meter.PreviousPage
+	case tc66cpb.ScreenControlRequest_ACTION_ROTATE:
+		action = tc66c.Meter.RotateScreen
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown screen action: %s", req.GetAction())
+	}
+
+	if err := s.withDevice(req.GetDevice(), action); err != nil {
+		return nil, err
+	}
+
+	return &tc66cpb.ScreenControlResponse{}, nil
+}
+
+// UpdateFirmware flashes the image once the meter is found in bootloader
+// mode. The update is not interrupted if the client goes away, since a
+// partially written image leaves the meter unbootable
+func (s *meterServer) UpdateFirmware(req *tc66cpb.UpdateFirmwareRequest, stream tc66cpb.Meter_UpdateFirmwareServer) error {
+	if req.GetDevice() == "" {
+		return status.Error(codes.InvalidArgument, "device is required")
+	}
+
+	info := tc66c.InspectFirmware(req.GetImage())
+	if err := info.Validate(req.GetForce()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "firmware validation failed: %v", err)
+	}
+
+	port, err := resolvePortName(req.GetDevice(), s.broker.IsOpen)
+	if err != nil {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	port = resolvePort(s.broker, port)
+
+	// Refuse to flash a port somebody is polling, and keep everyone else
+	// off it until the update is done
+	if err := s.broker.Reserve(port, "firmware update in progress"); err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer s.broker.Unreserve(port)
+
+	device, err := openDevice(port, nil)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to connect to device: %v", err)
+	}
+	defer device.Close()
+
+	if device.Mode != tc66c.ModeBootloader {
+		return status.Errorf(codes.FailedPrecondition, "device must be in bootloader mode to update firmware (current mode: %s)\n%s", device.Mode, bootloaderInstructions)
+	}
+
+	log.Printf("Starting firmware update on %s (%d bytes)", port, len(req.GetImage()))
+
+	err = device.UpdateFirmware(req.GetImage(), func(progress tc66c.FirmwareUpdateProgress) {
+		// Keep flashing even if the client is gone
+		stream.Send(&tc66cpb.FirmwareUpdateProgress{
+			BytesSent:   uint32(progress.BytesSent),
+			TotalBytes:  uint32(progress.TotalBytes),
+			ChunksSent:  uint32(progress.ChunksSent),
+			TotalChunks: uint32(progress.TotalChunks),
+		})
+	})
+	if err != nil {
+		log.Printf("Firmware update on %s failed: %v", port, err)
+		return status.Errorf(codes.Aborted, "firmware update failed: %v\n%s", err, firmwareRecoveryGuidance)
+	}
+
+	log.Printf("Firmware update on %s completed", port)
+
+	return nil
+}
+
+// withDevice runs fn with the addressed device,
+// translating failures into gRPC status errors
+func (s *meterServer) withDevice(id string, fn func(device tc66c.Meter) error) error {
+	if id == "" {
+		return status.Error(codes.InvalidArgument, "device is required")
+	}
+
+	sd, err := s.broker.Acquire(resolvePort(s.broker, id))
+	if err != nil {
+		return deviceStatus(err)
+	}
+	defer sd.Release()
+
+	if err := sd.Do(fn); err != nil {
+		return deviceStatus(err)
+	}
+	return nil
+}
+
+// deviceStatus translates an error talking to a meter into a gRPC status
+func deviceStatus(err error) error {
+	code := codes.Unavailable
+	switch {
+	case errors.Is(err, tc66c.ErrDisconnected):
+	case errors.Is(err, tc66c.ErrWrongMode):
+		code = codes.FailedPrecondition
+	case errors.Is(err, tc66c.ErrTimeout):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, tc66c.ErrBadPacket):
+		code = codes.DataLoss
+	case errors.Is(err, tc66c.ErrUnsupported):
+		code = codes.Unimplemented
+	}
+	return status.Error(code, err.Error())
+}
+
+// readingToProto converts a reading to its protobuf message
+func readingToProto(r *tc66c.Reading) *tc66cpb.Reading {
+	return &tc66cpb.Reading{
+		Product:         r.Product,
+		Version:         r.Version,
+		SerialNumber:    r.SerialNumber,
+		NumRuns:         r.NumRuns,
+		Voltage:         r.Voltage,
+		Current:         r.Current,
+		Power:           r.Power,
+		Resistance:      r.Resistance,
+		Group0Mah:       r.Group0MAh,
+		Group0Mwh:       r.Group0MWh,
+		Group1Mah:       r.Group1MAh,
+		Group1Mwh:       r.Group1MWh,
+		TemperatureSign: r.TemperatureSign,
+		Temperature:     r.Temperature,
+		DplusVoltage:    r.DPlusVoltage,
+		DminusVoltage:   r.DMinusVoltage,
+		Um:              umDataToProto(r.UM),
+		LayoutFallback:  r.LayoutFallback,
+	}
+}
+
+// umDataToProto converts the UM-series fields of a reading, nil for a TC66C
+func umDataToProto(um *tc66c.UMData) *tc66cpb.UMData {
+	if um == nil {
+		return nil
+	}
+
+	groups := make([]*tc66cpb.DataGroup, len(um.Groups))
+	for i, group := range um.Groups {
+		groups[i] = &tc66cpb.DataGroup{Mah: group.MAh, Mwh: group.MWh}
+	}
+
+	return &tc66cpb.UMData{
+		ActiveGroup:  int32(um.ActiveGroup),
+		Groups:       groups,
+		ChargingMode: um.ChargingMode,
+		LoadTimer: &tc66cpb.LoadTimer{
+			Threshold: um.LoadTimer.Threshold,
+			Mah:       um.LoadTimer.MAh,
+			Mwh:       um.LoadTimer.MWh,
+			Seconds:   um.LoadTimer.Seconds,
+			Running:   um.LoadTimer.Running,
+		},
+		TemperatureF:  um.TemperatureF,
+		ScreenTimeout: int32(um.ScreenTimeout),
+		Backlight:     int32(um.Backlight),
+		Screen:        int32(um.Screen),
+	}
+}
+
+// maskReading returns a copy of reading with only the fields in mask set.
+// An empty mask keeps every field
+func maskReading(reading *tc66cpb.Reading, mask *fieldmaskpb.FieldMask) *tc66cpb.Reading {
+	if len(mask.GetPaths()) == 0 {
+		return reading
+	}
+
+	masked := &tc66cpb.Reading{}
+	src, dst := reading.ProtoReflect(), masked.ProtoReflect()
+	fields := src.Descriptor().Fields()
+	for _, path := range mask.GetPaths() {
+		if field := fields.ByName(protoreflect.Name(path)); field != nil {
+			dst.Set(field, src.Get(field))
+		}
+	}
+
+	return masked
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/skgsergio/tc66-toolkit/lib/tc66cpb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReadingToProtoUM(t *testing.T) {
+	reading := &tc66c.Reading{
+		Product: "UM25C",
+		Voltage: 5.02,
+		UM: &tc66c.UMData{
+			ActiveGroup:   1,
+			Groups:        []tc66c.DataGroup{{MAh: 10, MWh: 50}, {MAh: 20, MWh: 100}},
+			ChargingMode:  "QC2.0",
+			LoadTimer:     tc66c.LoadTimer{Threshold: 0.1, MAh: 5, MWh: 25, Seconds: 60, Running: true},
+			TemperatureF:  80.6,
+			ScreenTimeout: 2,
+			Backlight:     5,
+			Screen:        3,
+		},
+	}
+
+	want := &tc66cpb.UMData{
+		ActiveGroup:   1,
+		Groups:        []*tc66cpb.DataGroup{{Mah: 10, Mwh: 50}, {Mah: 20, Mwh: 100}},
+		ChargingMode:  "QC2.0",
+		LoadTimer:     &tc66cpb.LoadTimer{Threshold: 0.1, Mah: 5, Mwh: 25, Seconds: 60, Running: true},
+		TemperatureF:  80.6,
+		ScreenTimeout: 2,
+		Backlight:     5,
+		Screen:        3,
+	}
+	got := readingToProto(reading)
+	if got.Product != "UM25C" || got.Voltage != 5.02 || !proto.Equal(got.Um, want) {
+		t.Errorf("readingToProto = %v, want UM data %v", got, want)
+	}
+
+	if got := readingToProto(&tc66c.Reading{Product: "TC66"}); got.Um != nil {
+		t.Errorf("TC66C reading has UM data %v", got.Um)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+const (
+	// labelPortPrefix selects a port by inventory label, e.g. label:bench-3-left
+	labelPortPrefix = "label:"
+	// serialPortPrefix selects a port by meter serial number, e.g. serial:12345
+	serialPortPrefix = "serial:"
+)
+
+// InventoryRecord is everything remembered about one meter
+type InventoryRecord struct {
+	SerialNumber    uint32    `json:"serial_number"`
+	Product         string    `json:"product"`
+	FirmwareVersion string    `json:"firmware_version"`
+	NumRuns         uint32    `json:"num_runs"`
+	LastPort        string    `json:"last_port"`
+	USBSerial       string    `json:"usb_serial,omitempty"`
+	LastSeen        time.Time `json:"last_seen"`
+	Label           string    `json:"label,omitempty"`
+}
+
+// Outdated reports whether the meter runs firmware older than the newest
+// version known to the toolkit
+func (r *InventoryRecord) Outdated() bool {
+	latest := tc66c.LatestKnownVersion(r.Product)
+	return latest != "" && tc66c.CompareVersions(r.FirmwareVersion, latest) < 0
+}
+
+// Inventory is the local record of every meter seen
+type Inventory struct {
+	path    string
+	Devices []*InventoryRecord `json:"devices"`
+}
+
+// inventoryPath returns the location of the inventory file
+func inventoryPath() string {
+	if path := os.Getenv("TC66C_INVENTORY"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "tc66c-toolkit", "inventory.json")
+}
+
+// loadInventory reads the inventory file, returning an empty inventory if
+// it does not exist yet
+func loadInventory() (*Inventory, error) {
+	inv := &Inventory{path: inventoryPath()}
+
+	data, err := os.ReadFile(inv.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return inv, nil
+		}
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+
+	if err := json.Unmarshal(data, inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", inv.path, err)
+	}
+
+	return inv, nil
+}
+
+// updateInventory loads the inventory, lets update change it and saves
+// it, holding a lock on the inventory throughout so commands updating it
+// at the same time do not lose each other's changes
+func updateInventory(update func(inv *Inventory) error) error {
+	path := inventoryPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to lock inventory: %w", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock inventory: %w", err)
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	if err := update(inv); err != nil {
+		return err
+	}
+	return inv.Save()
+}
+
+// Save writes the inventory file atomically, through a temporary file in
+// the same directory. Use updateInventory to change the stored inventory
+func (inv *Inventory) Save() error {
+	sort.Slice(inv.Devices, func(i, j int) bool {
+		return inv.Devices[i].SerialNumber < inv.Devices[j].SerialNumber
+	})
+
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(inv.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(inv.path), filepath.Base(inv.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), inv.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the record for a device serial number
+func (inv *Inventory) Find(serialNumber uint32) *InventoryRecord {
+	for _, record := range inv.Devices {
+		if record.SerialNumber == serialNumber {
+			return record
+		}
+	}
+	return nil
+}
+
+// FindLabel returns the record with the given label (case-insensitive)
+func (inv *Inventory) FindLabel(label string) *InventoryRecord {
+	for _, record := range inv.Devices {
+		if record.Label != "" && strings.EqualFold(record.Label, label) {
+			return record
+		}
+	}
+	return nil
+}
+
+// Observe records a reading taken from the meter on port
+func (inv *Inventory) Observe(port string, reading *tc66c.Reading) *InventoryRecord {
+	record := inv.Find(reading.SerialNumber)
+	if record == nil {
+		record = &InventoryRecord{SerialNumber: reading.SerialNumber}
+		inv.Devices = append(inv.Devices, record)
+	}
+
+	record.Product = reading.Product
+	record.FirmwareVersion = reading.Version
+	record.NumRuns = reading.NumRuns
+	record.LastPort = port
+	record.LastSeen = time.Now()
+
+	if usb, err := tc66c.LookupUSB(port); err == nil {
+		record.USBSerial = usb.SerialNumber
+	}
+
+	return record
+}
+
+// recordSighting adds a reading to the inventory. Failures only produce a
+// warning since the inventory is a side effect of the command being run
+func recordSighting(port string, reading *tc66c.Reading) {
+	// UM-series meters have no serial number to track them by
+	if reading.SerialNumber == 0 {
+		return
+	}
+
+	err := updateInventory(func(inv *Inventory) error {
+		inv.Observe(port, reading)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update inventory: %v\n", err)
+	}
+}
+
+// errBootloaderMode is returned by identifyPort for meters in bootloader mode
+var errBootloaderMode = errors.New("meter is in bootloader mode")
+
+// identifyPort opens port and returns a reading from the meter on it
+func identifyPort(port string) (*tc66c.Reading, error) {
+	device, err := openDevice(port, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Close()
+
+	if device.Mode == tc66c.ModeBootloader {
+		return nil, errBootloaderMode
+	}
+
+	return device.GetReading()
+}
+
+// tc66cPorts returns the USB serial ports with the VID:PID of a TC66C, the
+// only ones worth sending a query to when looking for a meter
+func tc66cPorts(ports []SerialPortInfo) []SerialPortInfo {
+	var matching []SerialPortInfo
+	for _, port := range ports {
+		if port.IsUSB && tc66c.IsTC66CUSB(port.VID, port.PID) {
+			matching = append(matching, port)
+		}
+	}
+	return matching
+}
+
+// resolvePortName turns a port argument into a serial port name. Plain
+// names are returned as-is; label:<name> is looked up in the inventory and
+// the config file labels, serial:<number> selects a meter directly, and the
+// meter is searched for on the other TC66C USB ports if it has moved. inUse
+// (can be nil) reports ports the caller already holds open, which are
+// trusted instead of probed
+func resolvePortName(port string, inUse func(string) bool) (string, error) {
+	if !strings.HasPrefix(port, labelPortPrefix) && !strings.HasPrefix(port, serialPortPrefix) {
+		return port, nil
+	}
+
+	// Meters are told apart by the serial number in their readings, which
+	// only the TC66C reports
+	if err := requireTC66C(); err != nil {
+		return "", fmt.Errorf("cannot select %s by serial number or label: %w", port, err)
+	}
+
+	inv, err := loadInventory()
+	if err != nil {
+		return "", err
+	}
+
+	var serialNumber uint32
+	var description string
+	switch {
+	case strings.HasPrefix(port, labelPortPrefix):
+		label := strings.TrimPrefix(port, labelPortPrefix)
+		description = fmt.Sprintf("meter labelled %q", label)
+
+		if record := inv.FindLabel(label); record != nil {
+			serialNumber = record.SerialNumber
+		} else if configured, ok := activeConfig.LabelSerial(label); ok {
+			serialNumber = configured
+		} else {
+			return "", fmt.Errorf("no meter labelled %q in the inventory or config", label)
+		}
+
+	default:
+		value := strings.TrimPrefix(port, serialPortPrefix)
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid serial number %q", value)
+		}
+		serialNumber = uint32(parsed)
+		description = "meter"
+	}
+
+	lastPort := ""
+	if record := inv.Find(serialNumber); record != nil {
+		lastPort = record.LastPort
+	}
+
+	if lastPort != "" {
+		if inUse != nil && inUse(lastPort) {
+			return lastPort, nil
+		}
+
+		// Check the meter is still where it was last seen. A meter in
+		// bootloader mode cannot report its serial number, so trust the port
+		reading, err := identifyPort(lastPort)
+		if err == nil && reading.SerialNumber == serialNumber {
+			return lastPort, nil
+		}
+		if errors.Is(err, errBootloaderMode) {
+			return lastPort, nil
+		}
+	}
+
+	// Otherwise look for it on the other ports that can be a TC66C
+	ports, err := listSerialPorts()
+	if err != nil {
+		return "", err
+	}
+	for _, candidate := range tc66cPorts(ports) {
+		if candidate.Name == lastPort || (inUse != nil && inUse(candidate.Name)) {
+			continue
+		}
+		reading, err := identifyPort(candidate.Name)
+		if err != nil || reading.SerialNumber != serialNumber {
+			continue
+		}
+
+		recordSighting(candidate.Name, reading)
+		return candidate.Name, nil
+	}
+
+	if lastPort == "" {
+		return "", fmt.Errorf("%s (serial %d) not found on any TC66C USB port (%s:%s)", description, serialNumber, tc66c.TC66CUSBVID, tc66c.TC66CUSBPID)
+	}
+	return "", fmt.Errorf("%s (serial %d) not found on any TC66C USB port, last seen on %s", description, serialNumber, lastPort)
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockFile does nothing where flock is not available. Saves are still
+// atomic, but commands updating the inventory at once may lose a change
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// useInventory points the inventory at a file in a temporary directory
+func useInventory(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tc66c-toolkit", "inventory.json")
+	t.Setenv("TC66C_INVENTORY", path)
+	return path
+}
+
+func TestInventoryObserve(t *testing.T) {
+	inv := &Inventory{}
+
+	before := time.Now()
+	record := inv.Observe("/dev/ttyACM0", &tc66c.Reading{Product: "TC66", Version: "1.14", SerialNumber: 12345, NumRuns: 7})
+	if len(inv.Devices) != 1 || inv.Devices[0] != record {
+		t.Fatalf("first sighting gave %d records", len(inv.Devices))
+	}
+	if record.SerialNumber != 12345 || record.Product != "TC66" || record.FirmwareVersion != "1.14" ||
+		record.NumRuns != 7 || record.LastPort != "/dev/ttyACM0" || record.LastSeen.Before(before) {
+		t.Errorf("record = %+v", record)
+	}
+
+	// A later sighting updates the record of the same meter and keeps its label
+	record.Label = "bench"
+	again := inv.Observe("/dev/ttyACM1", &tc66c.Reading{Product: "TC66", Version: "1.18", SerialNumber: 12345, NumRuns: 9})
+	if again != record || len(inv.Devices) != 1 {
+		t.Fatalf("second sighting of the same meter gave %d records", len(inv.Devices))
+	}
+	if record.FirmwareVersion != "1.18" || record.NumRuns != 9 || record.LastPort != "/dev/ttyACM1" || record.Label != "bench" {
+		t.Errorf("updated record = %+v", record)
+	}
+
+	inv.Observe("/dev/ttyACM0", &tc66c.Reading{Product: "TC66", Version: "1.18", SerialNumber: 2})
+	if len(inv.Devices) != 2 || inv.Find(2) == nil || inv.FindLabel("BENCH") != record {
+		t.Errorf("inventory after a second meter = %+v", inv.Devices)
+	}
+}
+
+func TestInventorySave(t *testing.T) {
+	path := useInventory(t)
+
+	inv, err := loadInventory()
+	if err != nil || len(inv.Devices) != 0 {
+		t.Fatalf("loadInventory without a file = %+v, %v", inv, err)
+	}
+	inv.Observe("/dev/ttyACM1", &tc66c.Reading{Product: "TC66", Version: "1.18", SerialNumber: 20})
+	inv.Observe("/dev/ttyACM0", &tc66c.Reading{Product: "TC66", Version: "1.14", SerialNumber: 10}).Label = "bench"
+	if err := inv.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Devices) != 2 || loaded.Devices[0].SerialNumber != 10 || loaded.Devices[0].Label != "bench" ||
+		loaded.Devices[1].LastPort != "/dev/ttyACM1" {
+		t.Errorf("loaded inventory = %+v, want both meters sorted by serial number", loaded.Devices)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("inventory mode = %v, want 0644", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("inventory directory holds %d files, want no temporary file left", len(entries))
+	}
+}
+
+func TestUpdateInventoryConcurrent(t *testing.T) {
+	useInventory(t)
+
+	// Every sighting is kept even when they are recorded at the same time
+	const meters = 20
+	var wg sync.WaitGroup
+	for i := range meters {
+		wg.Go(func() {
+			recordSighting(fmt.Sprintf("/dev/ttyACM%d", i), &tc66c.Reading{Product: "TC66", SerialNumber: uint32(i + 1)})
+		})
+	}
+	wg.Wait()
+
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Devices) != meters {
+		t.Errorf("inventory has %d meters, want %d", len(inv.Devices), meters)
+	}
+
+	err = updateInventory(func(inv *Inventory) error { return fmt.Errorf("nothing to change") })
+	if err == nil || err.Error() != "nothing to change" {
+		t.Errorf("updateInventory error = %v, want the update's error", err)
+	}
+}
+
+func TestResolvePortName(t *testing.T) {
+	useInventory(t)
+	t.Cleanup(func() { activeConfig = &Config{} })
+	activeConfig = &Config{Labels: map[string]uint32{"charger": 12345}}
+
+	err := updateInventory(func(inv *Inventory) error {
+		inv.Observe("/dev/ttyACM3", &tc66c.Reading{Product: "TC66", SerialNumber: 12345}).Label = "bench"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The port the meter was last seen on is trusted while the caller holds it
+	inUse := func(port string) bool { return port == "/dev/ttyACM3" }
+
+	tests := []struct {
+		port    string
+		want    string
+		wantErr string
+	}{
+		{port: "/dev/ttyUSB0", want: "/dev/ttyUSB0"},
+		{port: "label:bench", want: "/dev/ttyACM3"},
+		{port: "label:BENCH", want: "/dev/ttyACM3"},
+		{port: "label:charger", want: "/dev/ttyACM3"},
+		{port: "serial:12345", want: "/dev/ttyACM3"},
+		{port: "label:nope", wantErr: `no meter labelled "nope" in the inventory or config`},
+		{port: "serial:abc", wantErr: `invalid serial number "abc"`},
+	}
+	for _, tt := range tests {
+		got, err := resolvePortName(tt.port, inUse)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("resolvePortName(%q) error = %v, want it to contain %q", tt.port, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolvePortName(%q) = %q, %v, want %q", tt.port, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// Polling interval limits
+const (
+	// minRequestInterval is the shortest polling interval clients may ask
+	// for. The meter itself usually limits the rate further, see
+	// fastestInterval
+	minRequestInterval = 10 * time.Millisecond
+
+	// defaultMinInterval limits polling until enough readings have been
+	// timed to measure the meter
+	defaultMinInterval = 100 * time.Millisecond
+
+	// latencyWindowSize is the number of recent readings a shared device
+	// times to work out how fast it can be polled
+	latencyWindowSize = 100
+)
+
+// latencyWindow keeps the durations of the most recent readings
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+// Add records the duration of a reading, replacing the oldest one once
+// the window is full
+func (w *latencyWindow) Add(d time.Duration) {
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// MinInterval returns the fastest reliable polling interval measured so
+// far, or defaultMinInterval until there are enough readings
+func (w *latencyWindow) MinInterval() time.Duration {
+	if len(w.samples) < 10 {
+		return defaultMinInterval
+	}
+	return fastestInterval(sortedDurations(w.samples))
+}
+
+// sortedDurations returns a sorted copy of durations
+func sortedDurations(durations []time.Duration) []time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sorted
+}
+
+// percentile returns the p-th percentile (0-100) of sorted durations using
+// the nearest rank method
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// fastestInterval derives the shortest polling interval that leaves the
+// meter idle between readings: the 95th percentile reading latency plus a
+// quarter, rounded up to 5ms
+func fastestInterval(sorted []time.Duration) time.Duration {
+	interval := percentile(sorted, 95) * 5 / 4
+	interval = (interval + 5*time.Millisecond - 1).Truncate(5 * time.Millisecond)
+	return max(interval, minRequestInterval)
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"sync"
 
 	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
 	"github.com/spf13/cobra"
+	"go.bug.st/serial"
 )
 
 var (
 	// Global flags
-	portFlag string
+	portFlag    string
+	retriesFlag int
+	resyncFlag  bool
+	traceFlag   string
+	meterFlag   string
 )
 
 var rootCmd = &cobra.Command{
@@ -18,7 +25,19 @@ var rootCmd = &cobra.Command{
 	Short: "TC66C Toolkit - USB power meter interface",
 	Long: `TC66C Toolkit provides a command-line interface for interacting with
 TC66C USB power meters. You can read measurements, poll data continuously,
-retrieve recordings, and update firmware.`,
+retrieve recordings, and update firmware.
+
+RDTech UM24C, UM25C and UM34C meters are supported too, over USB or
+Bluetooth serial ports: pass --meter um (or the exact model).
+
+Defaults and named profiles are read from a YAML config file, and every
+flag can also be set with a TC66C_<FLAG> environment variable.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := applyConfig(cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
 }
 
 func init() {
@@ -26,7 +45,15 @@ func init() {
 	rootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
 
 	// Global flags (available to all commands)
-	rootCmd.PersistentFlags().StringVarP(&portFlag, "port", "p", "/dev/ttyACM0", "Serial port device path")
+	rootCmd.PersistentFlags().StringVarP(&portFlag, "port", "p", "/dev/ttyACM0", "Serial port device path, or label:<name> for a meter labelled in the fleet inventory")
+	rootCmd.PersistentFlags().StringVar(&configFileFlag, "config", "", "Config file (default: user config directory, or TC66C_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Named profile from the config file to use")
+	rootCmd.PersistentFlags().StringVar(&daemonSocketFlag, "daemon-socket", "", "Daemon control socket (default: $XDG_RUNTIME_DIR/tc66c-toolkit.sock)")
+	rootCmd.PersistentFlags().BoolVar(&noDaemonFlag, "no-daemon", false, "Open the serial port directly even if a daemon is running")
+	rootCmd.PersistentFlags().IntVar(&retriesFlag, "retries", tc66c.DefaultRetryPolicy().Attempts-1, "Times to retry a lost or corrupted reading")
+	rootCmd.PersistentFlags().BoolVar(&resyncFlag, "resync", tc66c.DefaultRetryPolicy().Resync, "Recover readings shifted by stray bytes before retrying")
+	rootCmd.PersistentFlags().StringVar(&traceFlag, "trace", "", "Log every byte sent to and received from the meter, with timestamps, to a file (- for stderr)")
+	rootCmd.PersistentFlags().StringVar(&meterFlag, "meter", "tc66c", "Meter model: tc66c, um24c, um25c, um34c, or um for any UM-series meter")
 }
 
 func main() {
@@ -35,14 +62,174 @@ func main() {
 	}
 }
 
-// connectDevice connects to the TC66C device on the specified port
+// connectDevice connects to the TC66C device on the specified port, for
+// commands only a TC66C has
 func connectDevice(port string) *tc66c.TC66C {
-	fmt.Fprintf(os.Stderr, "Connecting to TC66C on %s...\n", port)
-	device, err := tc66c.NewTC66C(port)
+	return connectDeviceWithTranscript(port, nil)
+}
+
+// connectDeviceWithTranscript connects to the TC66C device on the specified
+// port, recording all serial traffic to transcript if it is not nil
+func connectDeviceWithTranscript(port string, transcript io.Writer) *tc66c.TC66C {
+	if err := requireTC66C(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return connectMeterDirect(port, transcript).(*tc66c.TC66C)
+}
+
+// connectMeterDirect connects to the meter of the --meter model on the
+// specified port, recording all serial traffic to transcript if it is not
+// nil
+func connectMeterDirect(port string, transcript io.Writer) tc66c.Meter {
+	port, err := resolvePortName(port, nil)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Connected successfully! Device mode: %s\n", device.Mode)
-	return device
+	requirePortFree(port)
+
+	fmt.Fprintf(os.Stderr, "Connecting to %s on %s...\n", meterName(), port)
+	meter, err := openMeter(port, transcript)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if device, ok := meter.(*tc66c.TC66C); ok {
+		fmt.Fprintf(os.Stderr, "Connected successfully! Device mode: %s\n", device.Mode)
+	} else {
+		fmt.Fprintf(os.Stderr, "Connected successfully! Model: %s\n", meter.Capabilities().Model)
+	}
+	return meter
+}
+
+// meterName returns the --meter model for messages, "UM meter" for any
+// UM-series meter
+func meterName() string {
+	model, err := tc66c.ParseModel(meterFlag)
+	switch {
+	case err != nil:
+		return "meter"
+	case model == tc66c.ModelUM:
+		return "UM meter"
+	}
+	return model
+}
+
+// layoutWarning describes readings of a firmware version without a known
+// packet layout, which are decoded with tc66c.FallbackLayout
+func layoutWarning(product, version string) string {
+	return fmt.Sprintf("Warning: no known packet layout for %s firmware %s, readings are decoded as %s and may be wrong", product, version, tc66c.FallbackLayout.Name())
+}
+
+// requireTC66C fails unless --meter is a TC66C, for commands and features
+// only a TC66C has
+func requireTC66C() error {
+	model, err := tc66c.ParseModel(meterFlag)
+	if err != nil {
+		return err
+	}
+	if model != tc66c.ModelTC66C {
+		return fmt.Errorf("this needs a TC66C, not a %s: %w", meterName(), tc66c.ErrUnsupported)
+	}
+	return nil
+}
+
+// openDevice opens the TC66C on the specified port, recording all serial
+// traffic to transcript if it is not nil, and to the --trace file if set
+func openDevice(port string, transcript io.Writer) (*tc66c.TC66C, error) {
+	if err := requireTC66C(); err != nil {
+		return nil, err
+	}
+
+	meter, err := openMeter(port, transcript)
+	if err != nil {
+		return nil, err
+	}
+	return meter.(*tc66c.TC66C), nil
+}
+
+// openMeter opens the meter of the --meter model on the specified port,
+// recording all serial traffic to transcript if it is not nil, and to the
+// --trace file if set
+func openMeter(port string, transcript io.Writer) (tc66c.Meter, error) {
+	trace, err := openTrace()
+	if err != nil {
+		return nil, err
+	}
+
+	serialPort, err := tc66c.OpenMeterPort(port, meterFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapped serial.Port = serialPort
+	if transcript != nil {
+		wrapped = tc66c.NewTranscriptPort(wrapped, transcript)
+	}
+	if trace != nil {
+		wrapped = tc66c.NewTracePort(wrapped, port, trace)
+	}
+
+	meter, err := tc66c.NewMeterFromNamedPort(port, wrapped, meterFlag)
+	if err != nil {
+		serialPort.Close()
+		return nil, err
+	}
+
+	setRetryPolicy(meter, retryPolicy())
+	return meter, nil
+}
+
+// setRetryPolicy sets how meter recovers from lost or corrupted readings
+func setRetryPolicy(meter tc66c.Meter, policy tc66c.RetryPolicy) {
+	switch meter := meter.(type) {
+	case *tc66c.TC66C:
+		meter.Retry = policy
+	case *tc66c.UMMeter:
+		meter.Retry = policy
+	}
+}
+
+// requireFirmware fails if meter is a TC66C in bootloader mode, which
+// cannot take readings
+func requireFirmware(meter tc66c.Meter) error {
+	if device, ok := meter.(*tc66c.TC66C); ok && device.Mode != tc66c.ModeFirmware {
+		return &tc66c.ModeError{Want: tc66c.ModeFirmware, Got: device.Mode}
+	}
+	return nil
+}
+
+var (
+	traceOnce sync.Once
+	traceFile io.Writer
+	traceErr  error
+)
+
+// openTrace opens the --trace file the first time a device is opened and
+// returns it, or nil if tracing is off. Every device shares it
+func openTrace() (io.Writer, error) {
+	traceOnce.Do(func() {
+		switch traceFlag {
+		case "":
+		case "-":
+			traceFile = os.Stderr
+		default:
+			file, err := os.OpenFile(traceFlag, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				traceErr = fmt.Errorf("failed to open trace file: %w", err)
+				return
+			}
+			traceFile = file
+		}
+	})
+	return traceFile, traceErr
+}
+
+// retryPolicy returns the reading retry policy set by the global flags
+func retryPolicy() tc66c.RetryPolicy {
+	policy := tc66c.DefaultRetryPolicy()
+	policy.Attempts = max(retriesFlag, 0) + 1
+	policy.Resync = resyncFlag
+	return policy
 }
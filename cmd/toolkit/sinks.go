@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// Sink stores every reading of a polling session
+type Sink interface {
+	Write(port string, at time.Time, reading *tc66c.Reading) error
+	Close() error
+}
+
+// sinkFormats lists the formats of --sink
+var sinkFormats = []string{"jsonl", "csv"}
+
+// openSink opens a sink given as <format>:<path>. Files are appended to,
+// so a restarted session keeps the readings of the previous one
+func openSink(spec string) (Sink, error) {
+	format, path, found := strings.Cut(spec, ":")
+	if !found || path == "" {
+		return nil, fmt.Errorf("invalid sink %q: expected <format>:<path> with format one of %s", spec, strings.Join(sinkFormats, ", "))
+	}
+
+	switch format {
+	case "jsonl", "csv":
+	default:
+		return nil, fmt.Errorf("invalid sink %q: unknown format %q (available: %s)", spec, format, strings.Join(sinkFormats, ", "))
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sink: %w", err)
+	}
+
+	if format == "jsonl" {
+		return &jsonlSink{file: file, encoder: json.NewEncoder(file)}, nil
+	}
+
+	sink := &csvSink{file: file, writer: csv.NewWriter(file)}
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		if err := sink.writer.Write(csvSinkHeader); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write sink header: %w", err)
+		}
+	}
+	return sink, nil
+}
+
+// openSinks opens every sink, closing the ones already open on failure
+func openSinks(specs []string) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(specs))
+	for _, spec := range specs {
+		sink, err := openSink(spec)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// closeSinks closes every sink
+func closeSinks(sinks []Sink) {
+	for _, sink := range sinks {
+		sink.Close()
+	}
+}
+
+// jsonlSink writes one JSON object per reading
+type jsonlSink struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// jsonlRecord is a line of a jsonl sink
+type jsonlRecord struct {
+	Time    time.Time      `json:"time"`
+	Port    string         `json:"port"`
+	Reading *tc66c.Reading `json:"reading"`
+}
+
+func (s *jsonlSink) Write(port string, at time.Time, reading *tc66c.Reading) error {
+	return s.encoder.Encode(jsonlRecord{Time: at, Port: port, Reading: reading})
+}
+
+func (s *jsonlSink) Close() error {
+	return s.file.Close()
+}
+
+// csvSinkHeader is the first row of a new csv sink
+var csvSinkHeader = []string{
+	"time", "port", "serial_number", "voltage", "current", "power", "resistance",
+	"temperature", "dplus_voltage", "dminus_voltage", "group0_mah", "group0_mwh",
+	"group1_mah", "group1_mwh",
+}
+
+// csvSink writes one row per reading
+type csvSink struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+func (s *csvSink) Write(port string, at time.Time, reading *tc66c.Reading) error {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	u := func(v uint32) string { return strconv.FormatUint(uint64(v), 10) }
+
+	s.writer.Write([]string{
+		at.UTC().Format(time.RFC3339Nano), port, u(reading.SerialNumber),
+		f(reading.Voltage), f(reading.Current), f(reading.Power), f(reading.Resistance),
+		f(reading.Temperature), f(reading.DPlusVoltage), f(reading.DMinusVoltage),
+		u(reading.Group0MAh), u(reading.Group0MWh), u(reading.Group1MAh), u(reading.Group1MWh),
+	})
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	return s.file.Close()
+}
+
+// readingOutputs sends the readings of every meter of a session to its
+// sinks and checks them against its alarm rules, keeping the alarm state
+// of each port apart
+type readingOutputs struct {
+	mu     sync.Mutex
+	sinks  []Sink
+	rules  []string
+	alarms map[string]*AlarmMonitor
+	logf   func(format string, args ...any)
+}
+
+// newReadingOutputs opens the sinks and parses the alarm rules. Alarms and
+// sink errors are reported with logf
+func newReadingOutputs(sinkSpecs, alarmRules []string, logf func(format string, args ...any)) (*readingOutputs, error) {
+	if _, err := NewAlarmMonitor(alarmRules); err != nil {
+		return nil, err
+	}
+	sinks, err := openSinks(sinkSpecs)
+	if err != nil {
+		return nil, err
+	}
+	return &readingOutputs{sinks: sinks, rules: alarmRules, alarms: map[string]*AlarmMonitor{}, logf: logf}, nil
+}
+
+// Handle stores a reading of the meter on port and reports the alarms it
+// raises or clears
+func (o *readingOutputs) Handle(port string, at time.Time, reading *tc66c.Reading) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, sink := range o.sinks {
+		if err := sink.Write(port, at, reading); err != nil {
+			o.logf("Error writing to sink: %v", err)
+		}
+	}
+
+	if len(o.rules) == 0 {
+		return
+	}
+	monitor := o.alarms[port]
+	if monitor == nil {
+		monitor, _ = NewAlarmMonitor(o.rules)
+		o.alarms[port] = monitor
+	}
+	for _, event := range monitor.Evaluate(reading) {
+		o.logf("%s on %s", event, port)
+	}
+}
+
+// Close closes the sinks
+func (o *readingOutputs) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	closeSinks(o.sinks)
+	o.sinks = nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// APIDevice describes a serial port as returned by the REST API
+type APIDevice struct {
+	ID string `json:"id"`
+	SerialPortInfo
+	Active bool `json:"active"` // true if the web server currently holds the port open
+}
+
+// APIError is the body of every REST API error response
+type APIError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // Kind of device error, see deviceErrorKinds
+}
+
+// deviceErrorKinds maps the library's device errors to API error codes and
+// HTTP statuses. Disconnects come first as they may wrap any other error
+var deviceErrorKinds = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{tc66c.ErrDisconnected, "disconnected", http.StatusServiceUnavailable},
+	{tc66c.ErrWrongMode, "wrong_mode", http.StatusConflict},
+	{tc66c.ErrTimeout, "timeout", http.StatusGatewayTimeout},
+	{tc66c.ErrBadPacket, "bad_packet", http.StatusBadGateway},
+	{tc66c.ErrUnsupported, "unsupported", http.StatusNotImplemented},
+}
+
+// newAPIError builds the APIError for err, classifying device errors
+func newAPIError(err error) APIError {
+	apiErr := APIError{Error: err.Error()}
+	for _, kind := range deviceErrorKinds {
+		if errors.Is(err, kind.err) {
+			apiErr.Code = kind.code
+			break
+		}
+	}
+	return apiErr
+}
+
+// deviceErrorStatus returns the HTTP status for an error talking to a device
+func deviceErrorStatus(err error) int {
+	for _, kind := range deviceErrorKinds {
+		if errors.Is(err, kind.err) {
+			return kind.status
+		}
+	}
+	return http.StatusBadGateway
+}
+
+// deviceError returns the library error an API error code stands for, or
+// nil if the code is empty or unknown
+func deviceError(code string) error {
+	for _, kind := range deviceErrorKinds {
+		if kind.code == code {
+			return kind.err
+		}
+	}
+	return nil
+}
+
+// ScreenRequest is the body of a screen control request
+type ScreenRequest struct {
+	Action string `json:"action"` // next, prev or rotate
+}
+
+// registerAPI registers the REST API handlers on mux
+func registerAPI(mux *http.ServeMux, broker *DeviceBroker) {
+	mux.HandleFunc("GET /api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	mux.HandleFunc("GET /api/devices", func(w http.ResponseWriter, r *http.Request) {
+		handleAPIDevices(broker, w, r)
+	})
+	mux.HandleFunc("GET /api/devices/{id}/reading", func(w http.ResponseWriter, r *http.Request) {
+		handleAPIReading(broker, w, r)
+	})
+	mux.HandleFunc("GET /api/devices/{id}/recordings", func(w http.ResponseWriter, r *http.Request) {
+		handleAPIRecordings(broker, w, r)
+	})
+	mux.HandleFunc("POST /api/devices/{id}/screen", func(w http.ResponseWriter, r *http.Request) {
+		handleAPIScreen(broker, w, r)
+	})
+	mux.HandleFunc("GET /api/devices/{id}/stream", func(w http.ResponseWriter, r *http.Request) {
+		handleAPIStream(broker, w, r)
+	})
+	mux.HandleFunc("GET /api/devices/{id}/stats", func(w http.ResponseWriter, r *http.Request) {
+		handleAPIStats(broker, w, r)
+	})
+}
+
+func handleAPIDevices(broker *DeviceBroker, w http.ResponseWriter, r *http.Request) {
+	ports, err := listSerialPorts()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	devices := make([]APIDevice, 0, len(ports))
+	for _, port := range ports {
+		devices = append(devices, APIDevice{
+			ID:             filepath.Base(port.Name),
+			SerialPortInfo: port,
+			Active:         broker.IsOpen(port.Name),
+		})
+	}
+
+	writeAPIJSON(w, http.StatusOK, devices)
+}
+
+func handleAPIReading(broker *DeviceBroker, w http.ResponseWriter, r *http.Request) {
+	var reading *tc66c.Reading
+
+	err := withAPIDevice(broker, r, func(device tc66c.Meter) error {
+		var err error
+		reading, err = device.GetReading()
+		return err
+	})
+	if err != nil {
+		writeAPIError(w, deviceErrorStatus(err), err)
+		return
+	}
+
+	writeAPIJSON(w, http.StatusOK, reading)
+}
+
+func handleAPIRecordings(broker *DeviceBroker, w http.ResponseWriter, r *http.Request) {
+	var recordings []*tc66c.RecordingEntry
+
+	err := withAPIDevice(broker, r, func(device tc66c.Meter) error {
+		var err error
+		recordings, err = device.GetRecordings()
+		return err
+	})
+	if err != nil {
+		writeAPIError(w, deviceErrorStatus(err), err)
+		return
+	}
+
+	writeAPIJSON(w, http.StatusOK, recordings)
+}
+
+// handleAPIStats returns the reading counters of a device the server holds
+// open. It never touches the device, so it does not open the port either
+func handleAPIStats(broker *DeviceBroker, w http.ResponseWriter, r *http.Request) {
+	port := resolvePort(broker, r.PathValue("id"))
+
+	stats, ok := broker.Stats(port)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, fmt.Errorf("port %s is not open", port))
+		return
+	}
+
+	writeAPIJSON(w, http.StatusOK, stats)
+}
+
+func handleAPIScreen(broker *DeviceBroker, w http.ResponseWriter, r *http.Request) {
+	var req ScreenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid screen request: %w", err))
+		return
+	}
+
+	if req.Action != "next" && req.Action != "prev" && req.Action != "rotate" {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("unknown screen action: %q", req.Action))
+		return
+	}
+
+	err := withAPIDevice(broker, r, func(device tc66c.Meter) error {
+		switch req.Action {
+		case "next":
+			return device.NextPage()
+		case "prev":
+			return device.PreviousPage()
+		default:
+			return device.RotateScreen()
+		}
+	})
+	if err != nil {
+		writeAPIError(w, deviceErrorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIStream streams readings as Server-Sent Events until the client
+// disconnects. The optional interval query parameter is in milliseconds
+func handleAPIStream(broker *DeviceBroker, w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	interval := 500
+	if value := r.URL.Query().Get("interval"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid interval: %w", err))
+			return
+		}
+		interval = parsed
+	}
+	// The broker slows polling down further to what the meter keeps up with
+	interval = max(interval, int(minRequestInterval.Milliseconds()))
+
+	sub, _, err := broker.Subscribe(resolvePort(broker, r.PathValue("id")), time.Duration(interval)*time.Millisecond)
+	if err != nil {
+		writeAPIError(w, deviceErrorStatus(err), err)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := make(chan string, 1)
+	sub.Start(func(sample *HistorySample, err error) {
+		var event string
+		if err != nil {
+			data, _ := json.Marshal(newAPIError(err))
+			event = fmt.Sprintf("event: error\ndata: %s\n\n", data)
+		} else {
+			data, _ := json.Marshal(sample)
+			event = fmt.Sprintf("event: reading\ndata: %s\n\n", data)
+		}
+
+		select {
+		case events <- event:
+		case <-r.Context().Done():
+		}
+	})
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			if _, err := fmt.Fprint(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// withAPIDevice runs fn with the device addressed by the request, sharing
+// the connection with any web clients polling it
+func withAPIDevice(broker *DeviceBroker, r *http.Request, fn func(device tc66c.Meter) error) error {
+	sd, err := broker.Acquire(resolvePort(broker, r.PathValue("id")))
+	if err != nil {
+		return err
+	}
+	defer sd.Release()
+
+	return sd.Do(fn)
+}
+
+// resolvePort maps a device ID from the API to a serial port name. IDs are
+// the full port name (URL-escaped), its base name (e.g. ttyACM0) or an
+// inventory label (label:bench-3-left)
+func resolvePort(broker *DeviceBroker, id string) string {
+	if port, err := resolvePortName(id, broker.IsOpen); err == nil {
+		id = port
+	}
+
+	ports, err := listSerialPorts()
+	if err != nil {
+		return id
+	}
+
+	for _, port := range ports {
+		if port.Name == id || filepath.Base(port.Name) == id {
+			return port.Name
+		}
+	}
+
+	return id
+}
+
+// writeAPIJSON writes v as a JSON response with the given status code
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write API response: %v", err)
+	}
+}
+
+// writeAPIError writes an APIError response with the given status code
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, newAPIError(err))
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// DeviceBroker owns one meter connection per serial port and shares it
+// between every web client watching that port
+type DeviceBroker struct {
+	mu          sync.Mutex
+	devices     map[string]*SharedDevice
+	histories   map[string]*History
+	historySize int
+
+	// reserved holds ports taken for exclusive use (e.g. firmware updates)
+	reserved map[string]string
+
+	// portLocks serialize opening, closing and reserving each port, so the
+	// serial I/O of one port never holds up the others
+	portLocks map[string]*sync.Mutex
+
+	// open connects to the meter on a port
+	open func(port string) (tc66c.Meter, error)
+}
+
+// SharedDevice is a reference counted device connection that is polled once
+// and whose readings are fanned out to all of its subscribers
+type SharedDevice struct {
+	port    string
+	broker  *DeviceBroker
+	history *History
+
+	// mu guards device, which is nil while the meter is disconnected until
+	// a command manages to reopen the port. The device queues the commands
+	// of concurrent callers itself
+	mu     sync.Mutex
+	device tc66c.Meter
+
+	subsMu      sync.Mutex
+	subscribers map[*Subscription]struct{}
+	stats       tc66c.Stats // Reading counters as of the last poll
+	latency     latencyWindow
+	refs        int
+	wake        chan struct{}
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// Subscription receives readings from a SharedDevice at its own interval
+type Subscription struct {
+	device   *SharedDevice
+	interval time.Duration
+	lastSent time.Time
+	queue    chan subscriptionEvent
+	done     chan struct{}
+}
+
+// subscriptionEvent is a reading or polling error delivered to a subscriber
+type subscriptionEvent struct {
+	sample *HistorySample
+	err    error
+}
+
+// NewDeviceBroker creates an empty device broker that keeps up to
+// historySize readings per port
+func NewDeviceBroker(historySize int) *DeviceBroker {
+	return &DeviceBroker{
+		devices:     make(map[string]*SharedDevice),
+		histories:   make(map[string]*History),
+		historySize: historySize,
+		reserved:    make(map[string]string),
+		portLocks:   make(map[string]*sync.Mutex),
+		open: func(port string) (tc66c.Meter, error) {
+			return openMeter(port, nil)
+		},
+	}
+}
+
+// portLock returns the lock that serializes opening and closing port
+func (b *DeviceBroker) portLock(port string) *sync.Mutex {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lock, ok := b.portLocks[port]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.portLocks[port] = lock
+	}
+	return lock
+}
+
+// Reserve takes exclusive use of a port, failing if it is being polled or
+// is already reserved. reason is reported to anyone trying to use the port
+func (b *DeviceBroker) Reserve(port, reason string) error {
+	lock := b.portLock(port)
+	lock.Lock()
+	defer lock.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.devices[port]; ok {
+		return fmt.Errorf("port %s is in use by another client", port)
+	}
+	if current, ok := b.reserved[port]; ok {
+		return fmt.Errorf("port %s is busy: %s", port, current)
+	}
+
+	b.reserved[port] = reason
+	return nil
+}
+
+// Unreserve releases a port taken with Reserve
+func (b *DeviceBroker) Unreserve(port string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.reserved, port)
+}
+
+// History returns the reading history of the given port, or nil if the
+// port has never been polled. Histories outlive the device connection so
+// clients that reconnect still see previous readings
+func (b *DeviceBroker) History(port string) *History {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.histories[port]
+}
+
+// IsOpen reports whether the broker currently holds a connection to port
+func (b *DeviceBroker) IsOpen(port string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.devices[port]
+	return ok
+}
+
+// Acquire returns the shared device for the given port, opening the
+// connection if this is the first reference to it. Only callers of the
+// same port wait for the port to open
+func (b *DeviceBroker) Acquire(port string) (*SharedDevice, error) {
+	lock := b.portLock(port)
+	lock.Lock()
+	defer lock.Unlock()
+
+	b.mu.Lock()
+	reason, reserved := b.reserved[port]
+	sd, open := b.devices[port]
+	b.mu.Unlock()
+
+	if reserved {
+		return nil, fmt.Errorf("port %s is busy: %s", port, reason)
+	}
+
+	if open {
+		sd.subsMu.Lock()
+		sd.refs++
+		sd.subsMu.Unlock()
+		return sd, nil
+	}
+
+	device, err := b.open(port)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to device: %w", err)
+	}
+
+	if err := requireFirmware(device); err != nil {
+		device.Close()
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history, ok := b.histories[port]
+	if !ok {
+		history = NewHistory(b.historySize)
+		b.histories[port] = history
+	}
+
+	sd = &SharedDevice{
+		port:        port,
+		broker:      b,
+		history:     history,
+		device:      device,
+		subscribers: make(map[*Subscription]struct{}),
+		refs:        1,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	b.devices[port] = sd
+
+	go sd.pollLoop()
+
+	log.Printf("Opened shared device on %s", port)
+
+	return sd, nil
+}
+
+// Release drops a reference to the shared device and closes the connection
+// once nobody is using it anymore
+func (sd *SharedDevice) Release() {
+	b := sd.broker
+
+	// Hold the port lock while closing so a new Acquire for the same port
+	// cannot race with the old connection still holding the serial line
+	lock := b.portLock(sd.port)
+	lock.Lock()
+	defer lock.Unlock()
+
+	sd.subsMu.Lock()
+	sd.refs--
+	last := sd.refs <= 0
+	sd.subsMu.Unlock()
+
+	if !last {
+		return
+	}
+
+	b.mu.Lock()
+	delete(b.devices, sd.port)
+	b.mu.Unlock()
+
+	close(sd.stop)
+	<-sd.done
+
+	sd.mu.Lock()
+	if sd.device != nil {
+		sd.device.Close()
+	}
+	sd.mu.Unlock()
+
+	log.Printf("Closed shared device on %s", sd.port)
+}
+
+// Port returns the serial port of the shared device
+func (sd *SharedDevice) Port() string {
+	return sd.port
+}
+
+// Stats returns the reading counters of the current connection as of the
+// last reading, without waiting for the device
+func (sd *SharedDevice) Stats() tc66c.Stats {
+	sd.subsMu.Lock()
+	defer sd.subsMu.Unlock()
+	return sd.stats
+}
+
+// Stats returns the reading counters of the device open on port
+func (b *DeviceBroker) Stats(port string) (tc66c.Stats, bool) {
+	b.mu.Lock()
+	sd, ok := b.devices[port]
+	b.mu.Unlock()
+
+	if !ok {
+		return tc66c.Stats{}, false
+	}
+	return sd.Stats(), true
+}
+
+// Do runs fn with the underlying device. Calls may overlap: the device
+// sends their commands one at a time, screen commands first
+func (sd *SharedDevice) Do(fn func(device tc66c.Meter) error) error {
+	device, err := sd.connect()
+	if err != nil {
+		return err
+	}
+	return sd.checkDisconnect(device, fn(device))
+}
+
+// connect returns the device, reopening the port if an earlier command
+// found it disconnected
+func (sd *SharedDevice) connect() (tc66c.Meter, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.device != nil {
+		return sd.device, nil
+	}
+
+	device, err := sd.broker.open(sd.port)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", tc66c.ErrDisconnected, err)
+	}
+	if err := requireFirmware(device); err != nil {
+		device.Close()
+		return nil, err
+	}
+
+	sd.device = device
+	log.Printf("Reconnected to device on %s", sd.port)
+	return device, nil
+}
+
+// checkDisconnect closes the device if err says it was disconnected, so the
+// next command reopens the port. Commands that failed because another one
+// already closed the device are left alone
+func (sd *SharedDevice) checkDisconnect(device tc66c.Meter, err error) error {
+	if !errors.Is(err, tc66c.ErrDisconnected) || errors.Is(err, tc66c.ErrClosed) {
+		return err
+	}
+
+	sd.mu.Lock()
+	if sd.device == device {
+		log.Printf("Lost device on %s: %v", sd.port, err)
+		sd.device = nil
+	}
+	sd.mu.Unlock()
+
+	device.Close()
+	return err
+}
+
+// Subscribe acquires the device on the given port and registers a
+// subscriber that receives readings no faster than the given interval.
+// It also returns the stored history up to the moment the subscriber was
+// registered, so the caller can backfill before calling Start
+func (b *DeviceBroker) Subscribe(port string, interval time.Duration) (*Subscription, []HistorySample, error) {
+	sd, err := b.Acquire(port)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sub := &Subscription{
+		device:   sd,
+		interval: interval,
+		queue:    make(chan subscriptionEvent, 1),
+		done:     make(chan struct{}),
+	}
+
+	// Snapshot the history under the subscribers lock so no reading is
+	// both part of the backfill and delivered live, or missing from both
+	sd.subsMu.Lock()
+	backfill := sd.history.All()
+	sd.subscribers[sub] = struct{}{}
+	sd.subsMu.Unlock()
+
+	// Let the poller pick up the new interval right away
+	select {
+	case sd.wake <- struct{}{}:
+	default:
+	}
+
+	return sub, backfill, nil
+}
+
+// Start begins delivering readings. deliver is called from a dedicated
+// goroutine for each reading or polling error
+func (s *Subscription) Start(deliver func(*HistorySample, error)) {
+	go s.run(deliver)
+}
+
+// Device returns the shared device the subscription is attached to
+func (s *Subscription) Device() *SharedDevice {
+	return s.device
+}
+
+// Interval returns the delivery interval of the subscription
+func (s *Subscription) Interval() time.Duration {
+	return s.interval
+}
+
+// Unsubscribe stops deliveries and releases the device reference
+func (s *Subscription) Unsubscribe() {
+	sd := s.device
+
+	sd.subsMu.Lock()
+	delete(sd.subscribers, s)
+	sd.subsMu.Unlock()
+
+	close(s.done)
+	sd.Release()
+}
+
+// run delivers queued events until the subscription is cancelled
+func (s *Subscription) run(deliver func(*HistorySample, error)) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case ev := <-s.queue:
+			deliver(ev.sample, ev.err)
+		}
+	}
+}
+
+// push queues an event for the subscriber, replacing any event a slow
+// subscriber has not consumed yet
+func (s *Subscription) push(ev subscriptionEvent) {
+	for {
+		select {
+		case s.queue <- ev:
+			return
+		default:
+		}
+		select {
+		case <-s.queue:
+		default:
+		}
+	}
+}
+
+// pollInterval returns the fastest interval requested by any subscriber,
+// but no faster than the meter was measured to keep up with
+func (sd *SharedDevice) pollInterval() time.Duration {
+	sd.subsMu.Lock()
+	defer sd.subsMu.Unlock()
+
+	var interval time.Duration
+	for sub := range sd.subscribers {
+		if interval == 0 || sub.interval < interval {
+			interval = sub.interval
+		}
+	}
+	if interval == 0 {
+		interval = time.Second
+	}
+	return max(interval, sd.latency.MinInterval())
+}
+
+// MinInterval returns the fastest interval the device is polled at, as
+// measured from its recent readings
+func (sd *SharedDevice) MinInterval() time.Duration {
+	sd.subsMu.Lock()
+	defer sd.subsMu.Unlock()
+	return sd.latency.MinInterval()
+}
+
+// GetReading takes a reading, reopening the port if the meter was
+// disconnected, and keeps the reading counters for Stats
+func (sd *SharedDevice) GetReading() (*tc66c.Reading, error) {
+	var reading *tc66c.Reading
+	err := sd.Do(func(device tc66c.Meter) error {
+		var err error
+		reading, err = device.GetReading()
+
+		sd.subsMu.Lock()
+		sd.stats = device.Stats()
+		sd.subsMu.Unlock()
+
+		return err
+	})
+	return reading, err
+}
+
+// pollLoop polls the device at the fastest subscriber interval and fans
+// the readings out. Errors never end it: GetReading reopens the port after
+// a disconnect
+func (sd *SharedDevice) pollLoop() {
+	defer close(sd.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-sd.stop
+		cancel()
+	}()
+
+	samples, errs := tc66c.Stream(ctx, sd, tc66c.StreamOptions{
+		IntervalFunc: sd.pollInterval,
+		Wake:         sd.wake,
+		Backpressure: tc66c.BackpressureBlock,
+		StopOnError:  func(error) bool { return false },
+	})
+
+	for samples != nil || errs != nil {
+		select {
+		case sample, ok := <-samples:
+			if !ok {
+				samples = nil
+				continue
+			}
+			sd.subsMu.Lock()
+			sd.latency.Add(sample.Latency)
+			sd.subsMu.Unlock()
+			sd.fanOut(sample.Time, sample.Reading, nil)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			sd.fanOut(time.Now(), nil, err)
+		}
+	}
+}
+
+// fanOut records a reading in the history and delivers it to every
+// subscriber whose interval has elapsed
+func (sd *SharedDevice) fanOut(now time.Time, reading *tc66c.Reading, err error) {
+	sd.subsMu.Lock()
+	defer sd.subsMu.Unlock()
+
+	var sample *HistorySample
+	if err == nil {
+		sample = &HistorySample{Timestamp: now, Reading: reading}
+		sd.history.Add(*sample)
+	}
+
+	for sub := range sd.subscribers {
+		if err != nil {
+			sub.push(subscriptionEvent{err: err})
+			continue
+		}
+
+		// Allow a little jitter so subscribers at the poll interval are not skipped
+		if !sub.lastSent.IsZero() && now.Sub(sub.lastSent) < sub.interval-sub.interval/10 {
+			continue
+		}
+		sub.lastSent = now
+		sub.push(subscriptionEvent{sample: sample})
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c/tc66ctest"
+)
+
+// brokerTestReading is what the emulated meters of fakeBroker measure
+var brokerTestReading = tc66c.Reading{Product: "TC66", Version: "1.18", SerialNumber: 4242, Voltage: 5.1, Current: 0.5}
+
+// fakeMeters opens an emulated meter on every port, keeping the ports of
+// each open so tests can unplug them
+type fakeMeters struct {
+	mu    sync.Mutex
+	opens map[string][]*tc66ctest.Port
+}
+
+func (f *fakeMeters) open(port string) (tc66c.Meter, error) {
+	fp := tc66ctest.NewPort(brokerTestReading)
+	f.mu.Lock()
+	f.opens[port] = append(f.opens[port], fp)
+	f.mu.Unlock()
+	return tc66c.NewTC66CFromNamedPort(port, fp)
+}
+
+// ports returns the emulated ports opened on port, oldest first
+func (f *fakeMeters) ports(port string) []*tc66ctest.Port {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*tc66ctest.Port(nil), f.opens[port]...)
+}
+
+// fakeBroker returns a broker that opens emulated meters
+func fakeBroker(historySize int) (*DeviceBroker, *fakeMeters) {
+	meters := &fakeMeters{opens: make(map[string][]*tc66ctest.Port)}
+	broker := NewDeviceBroker(historySize)
+	broker.open = meters.open
+	return broker, meters
+}
+
+// waitEvent waits for a delivered event that match accepts
+func waitEvent(t *testing.T, events <-chan error, what string, match func(error) bool) {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case err := <-events:
+			if match(err) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestDeviceBrokerRefcount(t *testing.T) {
+	const port = "/dev/ttyFAKE0"
+	broker, meters := fakeBroker(10)
+
+	first, err := broker.Acquire(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := broker.Acquire(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || len(meters.ports(port)) != 1 {
+		t.Fatalf("two acquires opened %d connections, want one shared", len(meters.ports(port)))
+	}
+	if err := broker.Reserve(port, "firmware update"); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("Reserve of an open port = %v, want an in-use error", err)
+	}
+
+	first.Release()
+	if !broker.IsOpen(port) || meters.ports(port)[0].Closed() {
+		t.Error("device closed while still referenced")
+	}
+	second.Release()
+	if broker.IsOpen(port) || !meters.ports(port)[0].Closed() {
+		t.Error("device left open after the last release")
+	}
+
+	if err := broker.Reserve(port, "firmware update"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.Acquire(port); err == nil || !strings.Contains(err.Error(), "busy: firmware update") {
+		t.Errorf("Acquire of a reserved port = %v, want a busy error", err)
+	}
+	broker.Unreserve(port)
+
+	third, err := broker.Acquire(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meters.ports(port)) != 2 {
+		t.Errorf("reacquiring opened %d connections in total, want 2", len(meters.ports(port)))
+	}
+	third.Release()
+}
+
+func TestDeviceBrokerOpensOutsideLock(t *testing.T) {
+	broker, meters := fakeBroker(10)
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	broker.open = func(port string) (tc66c.Meter, error) {
+		if port == "/dev/ttySLOW" {
+			close(entered)
+			<-unblock
+		}
+		return meters.open(port)
+	}
+
+	slow := make(chan error, 1)
+	go func() {
+		sd, err := broker.Acquire("/dev/ttySLOW")
+		if err == nil {
+			sd.Release()
+		}
+		slow <- err
+	}()
+	<-entered
+
+	// Other ports are not held up by a port that is slow to open
+	acquired := make(chan error, 1)
+	go func() {
+		sd, err := broker.Acquire("/dev/ttyFAKE0")
+		if err == nil {
+			sd.Release()
+		}
+		acquired <- err
+	}()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Acquire of another port waited for a slow open")
+	}
+
+	close(unblock)
+	if err := <-slow; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubscriptionIntervalFanOut(t *testing.T) {
+	const port = "/dev/ttyFAKE0"
+	broker, meters := fakeBroker(1000)
+
+	var fastCount, slowCount atomic.Int32
+	subscribe := func(interval time.Duration, count *atomic.Int32) *Subscription {
+		sub, _, err := broker.Subscribe(port, interval)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub.Start(func(sample *HistorySample, err error) {
+			if err == nil && sample.Reading.SerialNumber == brokerTestReading.SerialNumber {
+				count.Add(1)
+			}
+		})
+		return sub
+	}
+
+	// The device is polled no faster than defaultMinInterval until the
+	// meter has been timed
+	fast := subscribe(defaultMinInterval, &fastCount)
+	slow := subscribe(4*defaultMinInterval, &slowCount)
+	time.Sleep(12*defaultMinInterval + defaultMinInterval/2)
+
+	// A late subscriber is backfilled with the readings polled so far
+	late, backfill, err := broker.Subscribe(port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	late.Unsubscribe()
+	fast.Unsubscribe()
+	slow.Unsubscribe()
+
+	if len(meters.ports(port)) != 1 {
+		t.Errorf("subscribers opened %d connections, want one shared", len(meters.ports(port)))
+	}
+	if broker.IsOpen(port) {
+		t.Error("device left open after every subscriber left")
+	}
+
+	fastN, slowN := fastCount.Load(), slowCount.Load()
+	if slowN < 2 || slowN > 5 || fastN < 2*slowN {
+		t.Errorf("fast subscriber got %d readings and slow %d, want about 12 and 4", fastN, slowN)
+	}
+	if len(backfill) < int(fastN) {
+		t.Errorf("backfill has %d readings, want at least the %d the fast subscriber got", len(backfill), fastN)
+	}
+}
+
+func TestSharedDeviceReconnect(t *testing.T) {
+	const port = "/dev/ttyFAKE0"
+	broker, meters := fakeBroker(10)
+
+	sub, _, err := broker.Subscribe(port, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan error, 100)
+	sub.Start(func(sample *HistorySample, err error) {
+		select {
+		case events <- err:
+		default:
+		}
+	})
+
+	isReading := func(err error) bool { return err == nil }
+	waitEvent(t, events, "a reading", isReading)
+
+	meters.ports(port)[0].Unplug()
+	waitEvent(t, events, "the disconnect", func(err error) bool { return errors.Is(err, tc66c.ErrDisconnected) })
+	waitEvent(t, events, "a reading after reconnecting", isReading)
+
+	ports := meters.ports(port)
+	if len(ports) != 2 || !ports[0].Closed() {
+		t.Fatalf("opened %d connections, want the unplugged one closed and reopened", len(ports))
+	}
+
+	sub.Unsubscribe()
+	if !ports[1].Closed() {
+		t.Error("reopened connection left open after unsubscribing")
+	}
+}
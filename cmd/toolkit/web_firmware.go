@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// DeviceModeRequest represents the data for a device-mode command
+type DeviceModeRequest struct {
+	Port string `json:"port"`
+}
+
+// FirmwareImage describes a firmware image bundled with the toolkit
+type FirmwareImage struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// FirmwareUpdateRequest represents the data for a firmware-update command.
+// Either Image (a bundled image name) or Data (the uploaded file, base64
+// encoded in JSON) must be set
+type FirmwareUpdateRequest struct {
+	Port  string `json:"port"`
+	Image string `json:"image,omitempty"`
+	Data  []byte `json:"data,omitempty"`
+	Force bool   `json:"force,omitempty"` // flash images that fail validation
+}
+
+// FirmwareUpdateResult is sent when a firmware update finishes
+type FirmwareUpdateResult struct {
+	Port     string `json:"port"`
+	Guidance string `json:"guidance,omitempty"` // recovery or next-step instructions
+}
+
+func (c *Client) handleDeviceMode(data json.RawMessage) {
+	var req DeviceModeRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.sendResponse(WSResponse{
+			Command: "device-mode",
+			Success: false,
+			Error:   fmt.Sprintf("invalid device-mode request: %v", err),
+		})
+		return
+	}
+
+	port, err := resolvePortName(req.Port, c.broker.IsOpen)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "device-mode",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Port = port
+
+	// A port the broker is polling is necessarily in firmware mode
+	if c.broker.IsOpen(req.Port) {
+		c.sendResponse(WSResponse{
+			Command: "device-mode",
+			Success: true,
+			Data:    map[string]interface{}{"port": req.Port, "mode": tc66c.ModeFirmware.String()},
+		})
+		return
+	}
+
+	if err := c.broker.Reserve(req.Port, "checking device mode"); err != nil {
+		c.sendResponse(WSResponse{
+			Command: "device-mode",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer c.broker.Unreserve(req.Port)
+
+	device, err := openDevice(req.Port, nil)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "device-mode",
+			Success: false,
+			Error:   fmt.Sprintf("failed to connect to device: %v", err),
+		})
+		return
+	}
+	mode := device.Mode
+	device.Close()
+
+	c.sendResponse(WSResponse{
+		Command: "device-mode",
+		Success: true,
+		Data:    map[string]interface{}{"port": req.Port, "mode": mode.String()},
+	})
+}
+
+func (c *Client) handleListFirmware() {
+	images, err := listFirmwareImages(c.firmwareDir)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "list-firmware",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.sendResponse(WSResponse{
+		Command: "list-firmware",
+		Success: true,
+		Data:    images,
+	})
+}
+
+// listFirmwareImages returns the .bin files in the bundled firmware directory
+func listFirmwareImages(dir string) ([]FirmwareImage, error) {
+	images := []FirmwareImage{}
+	if dir == "" {
+		return images, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return images, nil
+		}
+		return nil, fmt.Errorf("failed to list firmware directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".bin") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		images = append(images, FirmwareImage{Name: entry.Name(), Size: info.Size()})
+	}
+
+	return images, nil
+}
+
+func (c *Client) handleFirmwareUpdate(data json.RawMessage) {
+	var req FirmwareUpdateRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   fmt.Sprintf("invalid firmware-update request: %v", err),
+		})
+		return
+	}
+
+	firmwareData, err := c.loadFirmwareImage(req)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	port, err := resolvePortName(req.Port, c.broker.IsOpen)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Port = port
+
+	info := tc66c.InspectFirmware(firmwareData)
+	if err := info.Validate(req.Force); err != nil {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   fmt.Sprintf("firmware validation failed: %v", err),
+			Data:    FirmwareUpdateResult{Port: req.Port, Guidance: "Tick \"Allow unknown images\" to flash it anyway."},
+		})
+		return
+	}
+
+	// Refuse to flash a port somebody is polling, and keep everyone else
+	// off it until the update is done
+	if err := c.broker.Reserve(req.Port, "firmware update in progress"); err != nil {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Run the update in the background so the WebSocket keeps being read
+	go c.runFirmwareUpdate(req.Port, firmwareData)
+}
+
+// loadFirmwareImage returns the image selected or uploaded in req
+func (c *Client) loadFirmwareImage(req FirmwareUpdateRequest) ([]byte, error) {
+	if len(req.Data) > 0 {
+		return req.Data, nil
+	}
+
+	if req.Image == "" {
+		return nil, fmt.Errorf("no firmware image selected")
+	}
+
+	// Only allow plain file names from the bundled directory
+	if c.firmwareDir == "" || filepath.Base(req.Image) != req.Image {
+		return nil, fmt.Errorf("unknown firmware image: %s", req.Image)
+	}
+
+	firmwareData, err := os.ReadFile(filepath.Join(c.firmwareDir, req.Image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read firmware image: %w", err)
+	}
+
+	return firmwareData, nil
+}
+
+// runFirmwareUpdate flashes firmwareData to the device on port, streaming
+// progress to the client. The port must already be reserved
+func (c *Client) runFirmwareUpdate(port string, firmwareData []byte) {
+	defer c.broker.Unreserve(port)
+
+	device, err := openDevice(port, nil)
+	if err != nil {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   fmt.Sprintf("failed to connect to device: %v", err),
+		})
+		return
+	}
+	defer device.Close()
+
+	if device.Mode != tc66c.ModeBootloader {
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   fmt.Sprintf("device must be in bootloader mode to update firmware (current mode: %s)", device.Mode),
+			Data:    FirmwareUpdateResult{Port: port, Guidance: bootloaderInstructions},
+		})
+		return
+	}
+
+	log.Printf("Starting firmware update on %s (%d bytes)", port, len(firmwareData))
+
+	err = device.UpdateFirmware(firmwareData, func(progress tc66c.FirmwareUpdateProgress) {
+		c.sendResponse(WSResponse{
+			Command: "firmware-progress",
+			Success: true,
+			Data:    progress,
+		})
+	})
+	if err != nil {
+		log.Printf("Firmware update on %s failed: %v", port, err)
+		c.sendResponse(WSResponse{
+			Command: "firmware-update",
+			Success: false,
+			Error:   fmt.Sprintf("firmware update failed: %v", err),
+			Data:    FirmwareUpdateResult{Port: port, Guidance: firmwareRecoveryGuidance},
+		})
+		return
+	}
+
+	log.Printf("Firmware update on %s completed", port)
+
+	c.sendResponse(WSResponse{
+		Command: "firmware-update",
+		Success: true,
+		Data: FirmwareUpdateResult{
+			Port:     port,
+			Guidance: "You can now unplug and replug the device to boot into the new firmware.",
+		},
+	})
+}
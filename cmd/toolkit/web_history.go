@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// HistorySample is a reading together with the time the server received it
+type HistorySample struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Reading   *tc66c.Reading `json:"reading"`
+}
+
+// History is a fixed-size in-memory ring buffer of readings for one device
+type History struct {
+	mu      sync.Mutex
+	samples []HistorySample
+	start   int
+	count   int
+}
+
+// NewHistory creates a history buffer that keeps up to size samples.
+// A size of zero disables the history
+func NewHistory(size int) *History {
+	return &History{
+		samples: make([]HistorySample, size),
+	}
+}
+
+// Add appends a sample, evicting the oldest one when the buffer is full
+func (h *History) Add(sample HistorySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	size := len(h.samples)
+	if size == 0 {
+		return
+	}
+
+	if h.count < size {
+		h.samples[(h.start+h.count)%size] = sample
+		h.count++
+		return
+	}
+
+	h.samples[h.start] = sample
+	h.start = (h.start + 1) % size
+}
+
+// Len returns the number of samples currently stored
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// All returns every stored sample, oldest first
+func (h *History) All() []HistorySample {
+	return h.Range(time.Time{}, time.Time{}, 0)
+}
+
+// Range returns the samples with from <= timestamp <= to, oldest first.
+// A zero from or to leaves that end of the range open. If limit is
+// positive only the newest limit matching samples are returned
+func (h *History) Range(from, to time.Time, limit int) []HistorySample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	size := len(h.samples)
+	result := make([]HistorySample, 0, h.count)
+
+	for i := 0; i < h.count; i++ {
+		sample := h.samples[(h.start+i)%size]
+		if !from.IsZero() && sample.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && sample.Timestamp.After(to) {
+			continue
+		}
+		result = append(result, sample)
+	}
+
+	if limit > 0 && len(result) > limit {
+		result = result[len(result)-limit:]
+	}
+
+	return result
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+)
+
+// historyStart is the timestamp of the first sample of fillHistory
+var historyStart = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// fillHistory adds n samples a second apart, numbered by their NumRuns
+func fillHistory(h *History, n int) {
+	for i := range n {
+		h.Add(HistorySample{
+			Timestamp: historyStart.Add(time.Duration(i) * time.Second),
+			Reading:   &tc66c.Reading{NumRuns: uint32(i)},
+		})
+	}
+}
+
+// sampleRuns returns the NumRuns of every sample
+func sampleRuns(samples []HistorySample) []uint32 {
+	runs := make([]uint32, len(samples))
+	for i, sample := range samples {
+		runs[i] = sample.Reading.NumRuns
+	}
+	return runs
+}
+
+func TestHistoryRingBuffer(t *testing.T) {
+	h := NewHistory(3)
+	if h.Len() != 0 || len(h.All()) != 0 {
+		t.Fatalf("new history has %d samples", h.Len())
+	}
+
+	fillHistory(h, 2)
+	if got := sampleRuns(h.All()); h.Len() != 2 || !slices.Equal(got, []uint32{0, 1}) {
+		t.Errorf("partly filled history = %v, want [0 1]", got)
+	}
+
+	// The oldest samples are evicted, and All stays oldest first
+	fillHistory(h, 5)
+	if got := sampleRuns(h.All()); h.Len() != 3 || !slices.Equal(got, []uint32{2, 3, 4}) {
+		t.Errorf("wrapped history = %v, want [2 3 4]", got)
+	}
+
+	disabled := NewHistory(0)
+	fillHistory(disabled, 2)
+	if disabled.Len() != 0 || len(disabled.All()) != 0 {
+		t.Errorf("history of size 0 kept %d samples", disabled.Len())
+	}
+}
+
+func TestHistoryRange(t *testing.T) {
+	h := NewHistory(10)
+	fillHistory(h, 15) // keeps 5..14
+	at := func(i int) time.Time { return historyStart.Add(time.Duration(i) * time.Second) }
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		limit    int
+		want     []uint32
+	}{
+		{name: "open", want: []uint32{5, 6, 7, 8, 9, 10, 11, 12, 13, 14}},
+		{name: "from", from: at(12), want: []uint32{12, 13, 14}},
+		{name: "to", to: at(7), want: []uint32{5, 6, 7}},
+		{name: "inclusive bounds", from: at(8), to: at(10), want: []uint32{8, 9, 10}},
+		{name: "between samples", from: at(8).Add(time.Millisecond), to: at(10).Add(-time.Millisecond), want: []uint32{9}},
+		{name: "evicted", to: at(4)},
+		{name: "limit keeps newest", limit: 2, want: []uint32{13, 14}},
+		{name: "limit within range", from: at(6), to: at(11), limit: 3, want: []uint32{9, 10, 11}},
+		{name: "limit above count", from: at(13), limit: 5, want: []uint32{13, 14}},
+		{name: "zero limit", from: at(13), want: []uint32{13, 14}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sampleRuns(h.Range(tt.from, tt.to, tt.limit))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Range = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package tc66c
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Packet capture sources
+const (
+	CaptureSourceMeter     = "captured"  // Read from a real meter
+	CaptureSourceSynthetic = "synthetic" // Built by hand or by a test
+)
+
+// PacketCapture is a raw getva packet together with its decoded reading.
+// It is the format of the decoder's golden corpus in testdata/golden
+type PacketCapture struct {
+	Description string    `json:"description,omitempty"`
+	Source      string    `json:"source"` // CaptureSourceMeter or CaptureSourceSynthetic
+	CapturedAt  time.Time `json:"captured_at,omitzero"`
+	Packet      string    `json:"packet"` // Hex encoded encrypted packet
+	Reading     *Reading  `json:"reading"`
+}
+
+// NewPacketCapture records a packet read from a meter and its reading
+func NewPacketCapture(packet []byte, reading *Reading) *PacketCapture {
+	return &PacketCapture{
+		Description: fmt.Sprintf("%s v%s serial %d", reading.Product, reading.Version, reading.SerialNumber),
+		Source:      CaptureSourceMeter,
+		CapturedAt:  time.Now().UTC(),
+		Packet:      hex.EncodeToString(packet),
+		Reading:     reading,
+	}
+}
+
+// PacketBytes returns the decoded encrypted packet
+func (c *PacketCapture) PacketBytes() ([]byte, error) {
+	return hex.DecodeString(c.Packet)
+}
+
+// Decode decrypts and parses the captured packet
+func (c *PacketCapture) Decode() (*Reading, error) {
+	packet, err := c.PacketBytes()
+	if err != nil {
+		return nil, fmt.Errorf("invalid packet hex: %w", err)
+	}
+
+	return decodePacket(packet)
+}
@@ -0,0 +1,333 @@
+package tc66c
+
+import (
+	"cmp"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFirmwareBaseURL is where the vendor publishes firmware manifests.
+// The manifest for a product lives at <base>/<product>/<product>.json
+const DefaultFirmwareBaseURL = "http://www.ruidengkeji.com/rdupdate/firmware"
+
+// FirmwareManifestEntry describes one firmware image listed in a manifest
+type FirmwareManifestEntry struct {
+	Version string `json:"version"`          // Firmware version (e.g., "1.18")
+	File    string `json:"file"`             // Image location, relative to the manifest or absolute
+	Size    int    `json:"size,omitempty"`   // Image size in bytes (0 if not listed)
+	MD5     string `json:"md5,omitempty"`    // Hex encoded MD5 of the image (if listed)
+	SHA256  string `json:"sha256,omitempty"` // Hex encoded SHA-256 of the image (if listed)
+	Date    string `json:"date,omitempty"`   // Release date as written in the manifest
+	Notes   string `json:"notes,omitempty"`  // Release notes
+}
+
+// FirmwareManifest is a parsed vendor firmware manifest
+type FirmwareManifest struct {
+	Product string                  `json:"product"`
+	Entries []FirmwareManifestEntry `json:"entries"` // Sorted oldest to newest version
+}
+
+// Field names accepted for each manifest entry field. The vendor format is
+// not documented, so the parser is lenient about naming
+var (
+	manifestVersionKeys = []string{"version", "ver", "fw_version", "firmware_version"}
+	manifestFileKeys    = []string{"file", "filename", "file_name", "url", "path", "bin", "name"}
+	manifestSizeKeys    = []string{"size", "filesize", "file_size", "length"}
+	manifestMD5Keys     = []string{"md5", "md5sum"}
+	manifestSHA256Keys  = []string{"sha256", "sha256sum"}
+	manifestDateKeys    = []string{"date", "release_date", "time"}
+	manifestNotesKeys   = []string{"notes", "note", "desc", "description", "changelog", "log"}
+	manifestListKeys    = []string{"firmware", "firmwares", "list", "versions", "data", "items"}
+)
+
+// ParseFirmwareManifest parses a manifest for the given product. It accepts
+// a list of entries, an object wrapping such a list, or a single entry
+func ParseFirmwareManifest(product string, data []byte) (*FirmwareManifest, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid manifest JSON: %w", err)
+	}
+
+	var items []interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		if list, ok := findManifestList(v); ok {
+			items = list
+		} else {
+			items = []interface{}{v}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected manifest format")
+	}
+
+	manifest := &FirmwareManifest{Product: product}
+	for i, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("manifest entry %d is not an object", i)
+		}
+
+		entry := FirmwareManifestEntry{
+			Version: strings.TrimPrefix(strings.TrimPrefix(manifestString(obj, manifestVersionKeys), "v"), "V"),
+			File:    manifestString(obj, manifestFileKeys),
+			MD5:     strings.ToLower(manifestString(obj, manifestMD5Keys)),
+			SHA256:  strings.ToLower(manifestString(obj, manifestSHA256Keys)),
+			Date:    manifestString(obj, manifestDateKeys),
+			Notes:   manifestString(obj, manifestNotesKeys),
+		}
+		if size := manifestString(obj, manifestSizeKeys); size != "" {
+			n, err := strconv.Atoi(size)
+			if err != nil {
+				return nil, fmt.Errorf("manifest entry %d has invalid size %q", i, size)
+			}
+			entry.Size = n
+		}
+
+		if entry.Version == "" || entry.File == "" {
+			return nil, fmt.Errorf("manifest entry %d is missing a version or file", i)
+		}
+		if err := CheckFirmwareVersion(entry.Version); err != nil {
+			return nil, fmt.Errorf("manifest entry %d: %w", i, err)
+		}
+
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	if len(manifest.Entries) == 0 {
+		return nil, fmt.Errorf("manifest lists no firmware")
+	}
+
+	sort.SliceStable(manifest.Entries, func(i, j int) bool {
+		return CompareVersions(manifest.Entries[i].Version, manifest.Entries[j].Version) < 0
+	})
+
+	return manifest, nil
+}
+
+// findManifestList returns the first list of entries wrapped in obj
+func findManifestList(obj map[string]interface{}) ([]interface{}, bool) {
+	for _, key := range manifestListKeys {
+		for k, v := range obj {
+			if list, ok := v.([]interface{}); ok && strings.EqualFold(k, key) {
+				return list, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// manifestString returns the first of keys present in obj as a string
+func manifestString(obj map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		for k, v := range obj {
+			if !strings.EqualFold(k, key) {
+				continue
+			}
+			switch value := v.(type) {
+			case string:
+				return strings.TrimSpace(value)
+			case float64:
+				return strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		}
+	}
+	return ""
+}
+
+// Find returns the entry for the given version
+func (m *FirmwareManifest) Find(version string) (*FirmwareManifestEntry, error) {
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	for i := range m.Entries {
+		if CompareVersions(m.Entries[i].Version, version) == 0 {
+			return &m.Entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("version %s not found in %s manifest", version, m.Product)
+}
+
+// Latest returns the entry with the highest version
+func (m *FirmwareManifest) Latest() *FirmwareManifestEntry {
+	if len(m.Entries) == 0 {
+		return nil
+	}
+	return &m.Entries[len(m.Entries)-1]
+}
+
+// Verify checks data against the size and hashes listed for the entry, and
+// against the known-good table when the version is in it
+func (e *FirmwareManifestEntry) Verify(product string, data []byte) error {
+	if e.Size != 0 && len(data) != e.Size {
+		return fmt.Errorf("size mismatch: manifest lists %d bytes, got %d", e.Size, len(data))
+	}
+
+	if e.MD5 != "" {
+		sum := md5.Sum(data)
+		if got := hex.EncodeToString(sum[:]); got != e.MD5 {
+			return fmt.Errorf("MD5 mismatch: manifest lists %s, got %s", e.MD5, got)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	got := hex.EncodeToString(sum[:])
+	if e.SHA256 != "" && got != e.SHA256 {
+		return fmt.Errorf("SHA-256 mismatch: manifest lists %s, got %s", e.SHA256, got)
+	}
+
+	for _, known := range KnownFirmwareImages {
+		if strings.EqualFold(known.Product, product) && CompareVersions(known.Version, e.Version) == 0 && !strings.EqualFold(known.SHA256, got) {
+			return fmt.Errorf("SHA-256 mismatch: known-good %s v%s is %s, got %s", known.Product, known.Version, known.SHA256, got)
+		}
+	}
+
+	return nil
+}
+
+// CheckFirmwareVersion rejects versions that are unsafe to use in a file
+// name, such as those containing path separators or ".."
+func CheckFirmwareVersion(version string) error {
+	if version == "" || strings.ContainsAny(version, `/\`) || strings.Contains(version, "..") {
+		return fmt.Errorf("invalid firmware version %q", version)
+	}
+	return nil
+}
+
+// CompareVersions compares dotted version strings, returning -1, 0 or 1.
+// Numeric parts compare numerically, so "1.9" equals "1.09", and missing
+// parts count as 0. Other parts compare as text and sort after numbers, so
+// "1.x" is newer than "1.0" but never equal to it
+func CompareVersions(a, b string) int {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		sa, sb := "0", "0"
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+
+		na, errA := strconv.Atoi(sa)
+		nb, errB := strconv.Atoi(sb)
+		switch {
+		case errA == nil && errB == nil:
+			if c := cmp.Compare(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(sa, sb); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+// FirmwareCatalog fetches manifests and images from a vendor-compatible
+// location. BaseURL may be an http(s) URL, a file:// URL or a local
+// directory, so an offline mirror can stand in for the vendor site
+type FirmwareCatalog struct {
+	BaseURL string
+	Product string
+	Client  *http.Client
+}
+
+// NewFirmwareCatalog creates a catalog for product rooted at baseURL
+// (DefaultFirmwareBaseURL if empty)
+func NewFirmwareCatalog(baseURL, product string) *FirmwareCatalog {
+	if baseURL == "" {
+		baseURL = DefaultFirmwareBaseURL
+	}
+	return &FirmwareCatalog{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Product: product,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ManifestURL returns the location of the product manifest
+func (c *FirmwareCatalog) ManifestURL() string {
+	return c.BaseURL + "/" + c.Product + "/" + c.Product + ".json"
+}
+
+// Manifest fetches and parses the product manifest
+func (c *FirmwareCatalog) Manifest() (*FirmwareManifest, error) {
+	data, err := c.fetch(c.ManifestURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest: %w", err)
+	}
+	return ParseFirmwareManifest(c.Product, data)
+}
+
+// Download fetches the image for entry and verifies it
+func (c *FirmwareCatalog) Download(entry *FirmwareManifestEntry) ([]byte, error) {
+	data, err := c.fetch(c.imageURL(entry))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download firmware %s: %w", entry.Version, err)
+	}
+
+	if err := entry.Verify(c.Product, data); err != nil {
+		return nil, fmt.Errorf("downloaded firmware %s failed verification: %w", entry.Version, err)
+	}
+
+	return data, nil
+}
+
+// imageURL resolves the entry file against the manifest location
+func (c *FirmwareCatalog) imageURL(entry *FirmwareManifestEntry) string {
+	if strings.Contains(entry.File, "://") {
+		return entry.File
+	}
+	if strings.HasPrefix(entry.File, "/") && !isLocalLocation(c.BaseURL) {
+		if u, err := url.Parse(c.BaseURL); err == nil {
+			u.Path = entry.File
+			return u.String()
+		}
+	}
+	return c.BaseURL + "/" + c.Product + "/" + path.Base(entry.File)
+}
+
+// isLocalLocation reports whether location refers to the local filesystem
+func isLocalLocation(location string) bool {
+	return strings.HasPrefix(location, "file://") || !strings.Contains(location, "://")
+}
+
+// fetch reads location over HTTP or from the local filesystem
+func (c *FirmwareCatalog) fetch(location string) ([]byte, error) {
+	if isLocalLocation(location) {
+		return os.ReadFile(strings.TrimPrefix(location, "file://"))
+	}
+
+	resp, err := c.Client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, resp.Status)
+	}
+
+	// Guard against serving something that is clearly not a manifest or image
+	return io.ReadAll(io.LimitReader(resp.Body, FirmwareMaxSize*4))
+}
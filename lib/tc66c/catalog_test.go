@@ -0,0 +1,269 @@
+package tc66c
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// readManifestFixture reads a manifest from testdata/manifest
+func readManifestFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "manifest", name))
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return data
+}
+
+func TestParseFirmwareManifest(t *testing.T) {
+	manifest, err := ParseFirmwareManifest("TC66", readManifestFixture(t, "list.json"))
+	if err != nil {
+		t.Fatalf("ParseFirmwareManifest: %v", err)
+	}
+
+	var versions []string
+	for _, entry := range manifest.Entries {
+		versions = append(versions, entry.Version)
+	}
+	if !slices.Equal(versions, []string{"1.09", "1.12", "1.18"}) {
+		t.Errorf("versions = %v, want 1.09, 1.12, 1.18 sorted", versions)
+	}
+
+	latest := manifest.Latest()
+	want := FirmwareManifestEntry{
+		Version: "1.18",
+		File:    "TC66_v1.18.bin",
+		Size:    51840,
+		MD5:     "129716483c608ab568f93aca995c95d6",
+		SHA256:  "ef796b29557c91e0a469740ba1934ca0a838916c76ab55eb6d1253da32830940",
+		Notes:   "Latest release",
+	}
+	if *latest != want {
+		t.Errorf("Latest = %+v, want %+v", *latest, want)
+	}
+	if entry, err := manifest.Find("v1.9"); err != nil || entry.Size != 51200 || entry.Date != "2000-01-01" {
+		t.Errorf("Find(v1.9) = %+v, %v", entry, err)
+	}
+	if _, err := manifest.Find("2.00"); err == nil {
+		t.Error("Find(2.00) found a version the manifest does not list")
+	}
+}
+
+func TestParseFirmwareManifestWrapped(t *testing.T) {
+	manifest, err := ParseFirmwareManifest("TC66", readManifestFixture(t, "wrapped.json"))
+	if err != nil {
+		t.Fatalf("ParseFirmwareManifest: %v", err)
+	}
+
+	want := []FirmwareManifestEntry{{
+		Version: "1.18",
+		File:    "TC66_v1.18.bin",
+		Size:    51840,
+		MD5:     "129716483c608ab568f93aca995c95d6",
+		Notes:   "Latest release",
+	}}
+	if !slices.Equal(manifest.Entries, want) {
+		t.Errorf("Entries = %+v, want %+v", manifest.Entries, want)
+	}
+}
+
+func TestParseFirmwareManifestErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"not JSON", `<html>`, "invalid manifest JSON"},
+		{"not a list or object", `"TC66"`, "unexpected manifest format"},
+		{"entry not an object", `[1]`, "not an object"},
+		{"no version", `[{"file": "a.bin"}]`, "missing a version or file"},
+		{"no file", `[{"version": "1.18"}]`, "missing a version or file"},
+		{"bad size", `[{"version": "1.18", "file": "a.bin", "size": "big"}]`, "invalid size"},
+		{"path in version", `[{"version": "../../../.bashrc", "file": "a.bin"}]`, "invalid firmware version"},
+		{"separator in version", `[{"version": "1.18/x", "file": "a.bin"}]`, "invalid firmware version"},
+		{"empty", `[]`, "lists no firmware"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFirmwareManifest("TC66", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFirmwareManifestEntryVerify(t *testing.T) {
+	image := bundledImage(t)
+	manifest, err := ParseFirmwareManifest("TC66", readManifestFixture(t, "list.json"))
+	if err != nil {
+		t.Fatalf("ParseFirmwareManifest: %v", err)
+	}
+	good := *manifest.Latest()
+
+	tampered := slices.Clone(image)
+	tampered[100] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		entry   FirmwareManifestEntry
+		data    []byte
+		wantErr string
+	}{
+		{name: "match", entry: good, data: image},
+		{name: "size", entry: good, data: image[:len(image)-64], wantErr: "size mismatch"},
+		{name: "MD5", entry: good, data: tampered, wantErr: "MD5 mismatch"},
+		{name: "SHA-256", entry: FirmwareManifestEntry{Version: "1.18", SHA256: good.SHA256}, data: tampered, wantErr: "SHA-256 mismatch: manifest"},
+		{name: "known-good table", entry: FirmwareManifestEntry{Version: "1.18"}, data: tampered, wantErr: "SHA-256 mismatch: known-good"},
+		{name: "unlisted version without hashes", entry: FirmwareManifestEntry{Version: "1.12"}, data: tampered},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Verify("TC66", tt.data)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Verify: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Verify = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeMirror lays out a local mirror of the vendor site in a temporary
+// directory and returns its path
+func writeMirror(t *testing.T, manifest string, image []byte) string {
+	t.Helper()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "TC66")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "TC66.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "TC66_v1.18.bin"), image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestFirmwareCatalogMirrors(t *testing.T) {
+	image := bundledImage(t)
+	root := writeMirror(t, string(readManifestFixture(t, "list.json")), image)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(root)))
+	t.Cleanup(server.Close)
+
+	for name, baseURL := range map[string]string{
+		"directory": root,
+		"file URL":  "file://" + root,
+		"HTTP":      server.URL + "/",
+	} {
+		t.Run(name, func(t *testing.T) {
+			catalog := NewFirmwareCatalog(baseURL, "TC66")
+			manifest, err := catalog.Manifest()
+			if err != nil {
+				t.Fatalf("Manifest: %v", err)
+			}
+
+			data, err := catalog.Download(manifest.Latest())
+			if err != nil {
+				t.Fatalf("Download: %v", err)
+			}
+			if !slices.Equal(data, image) {
+				t.Error("downloaded image differs from the mirrored one")
+			}
+
+			missing, _ := manifest.Find("1.09")
+			if _, err := catalog.Download(missing); err == nil {
+				t.Error("Download of an image missing from the mirror succeeded")
+			}
+		})
+	}
+}
+
+func TestFirmwareCatalogRejectsTamperedDownload(t *testing.T) {
+	image := bundledImage(t)
+	image[0] ^= 0xFF
+	root := writeMirror(t, string(readManifestFixture(t, "list.json")), image)
+
+	catalog := NewFirmwareCatalog(root, "TC66")
+	manifest, err := catalog.Manifest()
+	if err != nil {
+		t.Fatalf("Manifest: %v", err)
+	}
+	if _, err := catalog.Download(manifest.Latest()); err == nil || !strings.Contains(err.Error(), "failed verification") {
+		t.Errorf("Download error = %v, want a verification failure", err)
+	}
+}
+
+func TestFirmwareCatalogImageURL(t *testing.T) {
+	catalog := NewFirmwareCatalog("http://mirror.example/firmware/", "TC66")
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"TC66_v1.18.bin", "http://mirror.example/firmware/TC66/TC66_v1.18.bin"},
+		{"sub/dir/TC66_v1.18.bin", "http://mirror.example/firmware/TC66/TC66_v1.18.bin"},
+		{"/rdupdate/firmware/TC66/TC66_v1.12.bin", "http://mirror.example/rdupdate/firmware/TC66/TC66_v1.12.bin"},
+		{"https://cdn.example/TC66_v1.18.bin", "https://cdn.example/TC66_v1.18.bin"},
+	}
+	for _, tt := range tests {
+		if got := catalog.imageURL(&FirmwareManifestEntry{File: tt.file}); got != tt.want {
+			t.Errorf("imageURL(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	local := NewFirmwareCatalog("/srv/mirror", "TC66")
+	if got := local.imageURL(&FirmwareManifestEntry{File: "/rdupdate/firmware/TC66/TC66_v1.12.bin"}); got != "/srv/mirror/TC66/TC66_v1.12.bin" {
+		t.Errorf("local mirror imageURL = %q, want the file inside the mirror", got)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.18", "1.18", 0},
+		{"1.9", "1.09", 0},
+		{"1.18", "1.18.0", 0},
+		{"1.09", "1.18", -1},
+		{"1.2", "1.10", -1},
+		{"2.0", "1.99", 1},
+		{"1.x", "1.0", 1},
+		{"1.0", "1.x", -1},
+		{"1.x", "1.x", 0},
+		{"1.a", "1.b", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFirmwareVersion(t *testing.T) {
+	for _, version := range []string{"1.18", "1.09", "1.18b"} {
+		if err := CheckFirmwareVersion(version); err != nil {
+			t.Errorf("CheckFirmwareVersion(%q): %v", version, err)
+		}
+	}
+	for _, version := range []string{"", "..", "1..18", "../1.18", "1.18/..", `1.18\x`} {
+		if err := CheckFirmwareVersion(version); err == nil {
+			t.Errorf("CheckFirmwareVersion(%q) accepted an unsafe version", version)
+		}
+	}
+}
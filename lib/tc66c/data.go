@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
-// Reading represents a single reading from the TC66C device
+// Reading represents a single reading from a meter. TC66C and UM-series
+// meters share most fields, see UM for those of UM-series meters only
 type Reading struct {
 	// pac1 block
 	Product         string  `json:"product"`          // Product name (e.g., "TC66")
@@ -28,115 +30,94 @@ type Reading struct {
 	Temperature     float64 `json:"temperature"`      // Temperature in °C
 	DPlusVoltage    float64 `json:"dplus_voltage"`    // D+ line voltage in V
 	DMinusVoltage   float64 `json:"dminus_voltage"`   // D- line voltage in V
-}
-
-// ParseReading parses a decrypted 192-byte packet into a Reading struct
-func ParseReading(data []byte) (*Reading, error) {
-	if len(data) != PacketSize {
-		return nil, fmt.Errorf("invalid data size: expected %d, got %d", PacketSize, len(data))
-	}
-
-	reading := &Reading{}
-
-	// Parse pac1 block (bytes 0-63)
-	pac1 := data[0:64]
-
-	// Verify pac1 prefix
-	if string(pac1[0:4]) != Block1Prefix {
-		return nil, fmt.Errorf("invalid pac1 prefix: expected %s, got %s", Block1Prefix, string(pac1[0:4]))
-	}
-
-	// Extract product name (bytes 4-7)
-	reading.Product = strings.TrimRight(string(pac1[4:8]), "\x00")
-
-	// Extract version (bytes 8-11)
-	reading.Version = strings.TrimRight(string(pac1[8:12]), "\x00")
 
-	// Extract serial number (bytes 12-15)
-	reading.SerialNumber = binary.LittleEndian.Uint32(pac1[12:16])
+	// Decoded with FallbackLayout, as no layout is known for the firmware
+	LayoutFallback bool `json:"layout_fallback,omitempty"`
 
-	// Extract number of runs (bytes 44-47)
-	reading.NumRuns = binary.LittleEndian.Uint32(pac1[44:48])
-
-	// Extract voltage (bytes 48-51), scale: 1e-4 V
-	reading.Voltage = float64(binary.LittleEndian.Uint32(pac1[48:52])) * 1e-4
+	// UM-series meters only
+	UM *UMData `json:"um,omitempty"`
+}
 
-	// Extract current (bytes 52-55), scale: 1e-5 A
-	reading.Current = float64(binary.LittleEndian.Uint32(pac1[52:56])) * 1e-5
+// ParseReading parses a decrypted 192-byte packet into a Reading struct,
+// using the layout of its product and firmware version from KnownLayouts.
+// Packets of unknown versions are decoded with FallbackLayout and have
+// LayoutFallback set
+func ParseReading(data []byte) (*Reading, error) {
+	return parseReading(data, false)
+}
 
-	// Extract power (bytes 56-59), scale: 1e-4 W
-	reading.Power = float64(binary.LittleEndian.Uint32(pac1[56:60])) * 1e-4
+// ParseReadingStrict is ParseReading for known layouts only. Packets of
+// unknown products or firmware versions fail with a LayoutError
+func ParseReadingStrict(data []byte) (*Reading, error) {
+	return parseReading(data, true)
+}
 
-	// Verify pac1 checksum (bytes 60-63)
-	pac1Checksum := binary.LittleEndian.Uint16(pac1[60:62])
-	if !VerifyChecksum(pac1[0:60], pac1Checksum) {
-		return nil, fmt.Errorf("pac1 checksum verification failed")
+// ParseReadingLayout parses a decrypted 192-byte packet with the given
+// layout, whatever product and version it comes from
+func ParseReadingLayout(data []byte, layout *Layout) (*Reading, error) {
+	reading, err := parsePacketHeader(data)
+	if err != nil {
+		return nil, err
 	}
+	layout.decode(data, reading)
+	return reading, nil
+}
 
-	// Parse pac2 block (bytes 64-127)
-	pac2 := data[64:128]
-
-	// Verify pac2 prefix
-	if string(pac2[0:4]) != Block2Prefix {
-		return nil, fmt.Errorf("invalid pac2 prefix: expected %s, got %s", Block2Prefix, string(pac2[0:4]))
+// parseReading verifies the packet and decodes it with the layout its
+// header selects
+func parseReading(data []byte, strict bool) (*Reading, error) {
+	reading, err := parsePacketHeader(data)
+	if err != nil {
+		return nil, err
 	}
 
-	// Extract resistance (bytes 4-7), scale: 1e-2 Ω
-	reading.Resistance = float64(binary.LittleEndian.Uint32(pac2[4:8])) * 1e-2
-
-	// Extract Group 0 mAh (bytes 8-11)
-	reading.Group0MAh = binary.LittleEndian.Uint32(pac2[8:12])
-
-	// Extract Group 0 mWh (bytes 12-15)
-	reading.Group0MWh = binary.LittleEndian.Uint32(pac2[12:16])
-
-	// Extract Group 1 mAh (bytes 16-19)
-	reading.Group1MAh = binary.LittleEndian.Uint32(pac2[16:20])
-
-	// Extract Group 1 mWh (bytes 20-23)
-	reading.Group1MWh = binary.LittleEndian.Uint32(pac2[20:24])
-
-	// Extract temperature sign (bytes 24-27)
-	reading.TemperatureSign = binary.LittleEndian.Uint32(pac2[24:28])
-
-	// Extract temperature (bytes 28-31), no scaling mentioned in docs
-	tempRaw := binary.LittleEndian.Uint32(pac2[28:32])
-	reading.Temperature = float64(tempRaw)
-	if reading.TemperatureSign != 0 {
-		reading.Temperature = -reading.Temperature
+	layout, err := LookupLayout(reading.Product, reading.Version)
+	if err != nil {
+		if strict {
+			return nil, err
+		}
+		layout = &FallbackLayout
+		reading.LayoutFallback = true
 	}
+	layout.decode(data, reading)
+	return reading, nil
+}
 
-	// Extract D+ voltage (bytes 32-35), scale: 1e-2 V
-	reading.DPlusVoltage = float64(binary.LittleEndian.Uint32(pac2[32:36])) * 1e-2
-
-	// Extract D- voltage (bytes 36-39), scale: 1e-2 V
-	reading.DMinusVoltage = float64(binary.LittleEndian.Uint32(pac2[36:40])) * 1e-2
-
-	// Verify pac2 checksum (bytes 60-63)
-	pac2Checksum := binary.LittleEndian.Uint16(pac2[60:62])
-	if !VerifyChecksum(pac2[0:60], pac2Checksum) {
-		return nil, fmt.Errorf("pac2 checksum verification failed")
+// parsePacketHeader verifies the size, prefixes and checksums of a
+// decrypted packet and returns a Reading with its product and version
+func parsePacketHeader(data []byte) (*Reading, error) {
+	if len(data) != PacketSize {
+		return nil, fmt.Errorf("%w: invalid data size: expected %d, got %d", ErrBadPacket, PacketSize, len(data))
 	}
 
-	// Parse pac3 block (bytes 128-191)
-	pac3 := data[128:192]
+	for block, prefix := range []string{Block1Prefix, Block2Prefix, Block3Prefix} {
+		pac := data[block*BlockSize : (block+1)*BlockSize]
 
-	// Verify pac3 prefix
-	if string(pac3[0:4]) != Block3Prefix {
-		return nil, fmt.Errorf("invalid pac3 prefix: expected %s, got %s", Block3Prefix, string(pac3[0:4]))
-	}
+		// Verify prefix (bytes 0-3)
+		if string(pac[0:4]) != prefix {
+			return nil, &PrefixError{Block: prefix, Got: string(pac[0:4])}
+		}
 
-	// Verify pac3 checksum (bytes 60-63)
-	pac3Checksum := binary.LittleEndian.Uint16(pac3[60:62])
-	if !VerifyChecksum(pac3[0:60], pac3Checksum) {
-		return nil, fmt.Errorf("pac3 checksum verification failed")
+		// Verify checksum (bytes 60-63)
+		checksum := binary.LittleEndian.Uint16(pac[60:62])
+		if !VerifyChecksum(pac[0:60], checksum) {
+			return nil, &ChecksumError{Block: prefix}
+		}
 	}
 
-	return reading, nil
+	return &Reading{
+		// Product name (bytes 4-7) and version (bytes 8-11) of pac1
+		Product: strings.TrimRight(string(data[4:8]), "\x00"),
+		Version: strings.TrimRight(string(data[8:12]), "\x00"),
+	}, nil
 }
 
 // String returns a formatted string representation of the reading
 func (r *Reading) String() string {
+	if r.UM != nil {
+		return r.umString()
+	}
+
 	return fmt.Sprintf(`Product: %s
 Version: %s
 Serial: %d
@@ -158,6 +139,39 @@ D- Voltage: %.2f V`,
 		r.DPlusVoltage, r.DMinusVoltage)
 }
 
+// umString is String for UM-series readings, which have no version, serial
+// or run count but ten data groups and a load timer
+func (r *Reading) umString() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Product: %s\n", r.Product)
+	fmt.Fprintf(&sb, "Voltage: %.4f V\n", r.Voltage)
+	fmt.Fprintf(&sb, "Current: %.5f A\n", r.Current)
+	fmt.Fprintf(&sb, "Power: %.4f W\n", r.Power)
+	fmt.Fprintf(&sb, "Resistance: %.2f Ω\n", r.Resistance)
+	for i, group := range r.UM.Groups {
+		active := ""
+		if i == r.UM.ActiveGroup {
+			active = " (active)"
+		}
+		fmt.Fprintf(&sb, "Group %d: %d mAh / %d mWh%s\n", i, group.MAh, group.MWh, active)
+	}
+
+	timer := r.UM.LoadTimer
+	state := "stopped"
+	if timer.Running {
+		state = "running"
+	}
+	fmt.Fprintf(&sb, "Load timer: %d mAh / %d mWh over %v above %.2f A (%s)\n",
+		timer.MAh, timer.MWh, time.Duration(timer.Seconds)*time.Second, timer.Threshold, state)
+	fmt.Fprintf(&sb, "Charging mode: %s\n", r.UM.ChargingMode)
+	fmt.Fprintf(&sb, "Temperature: %.1f °C / %.0f °F\n", r.Temperature, r.UM.TemperatureF)
+	fmt.Fprintf(&sb, "D+ Voltage: %.2f V\n", r.DPlusVoltage)
+	fmt.Fprintf(&sb, "D- Voltage: %.2f V", r.DMinusVoltage)
+
+	return sb.String()
+}
+
 // ShortString returns a compact one-line representation of the reading
 func (r *Reading) ShortString() string {
 	return fmt.Sprintf("V: %.4fV | I: %.5fA | P: %.4fW | R: %.2fΩ | T: %.1f°C | D+: %.2fV | D-: %.2fV",
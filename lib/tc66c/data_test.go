@@ -0,0 +1,65 @@
+package tc66c
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseReading(t *testing.T) {
+	reading, err := ParseReading(buildPlainPacket(testReading))
+	if err != nil {
+		t.Fatalf("ParseReading: %v", err)
+	}
+	assertReading(t, reading, &testReading)
+}
+
+func TestParseReadingNegativeTemperature(t *testing.T) {
+	cold := testReading
+	cold.TemperatureSign = 1
+	cold.Temperature = 12
+
+	reading, err := ParseReading(buildPlainPacket(cold))
+	if err != nil {
+		t.Fatalf("ParseReading: %v", err)
+	}
+	if reading.Temperature != -12 {
+		t.Errorf("Temperature = %v, want -12", reading.Temperature)
+	}
+}
+
+func TestParseReadingErrors(t *testing.T) {
+	for block, prefix := range []string{Block1Prefix, Block2Prefix, Block3Prefix} {
+		t.Run(prefix+" checksum", func(t *testing.T) {
+			plain := buildPlainPacket(testReading)
+			plain[block*BlockSize+60] ^= 0xFF
+
+			_, err := ParseReading(plain)
+			want := prefix + " checksum verification failed"
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("error = %v, want it to contain %q", err, want)
+			}
+		})
+
+		t.Run(prefix+" prefix", func(t *testing.T) {
+			plain := buildPlainPacket(testReading)
+			copy(plain[block*BlockSize:], "pacX")
+			sealBlock(plain, block)
+
+			_, err := ParseReading(plain)
+			want := "invalid " + prefix + " prefix"
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("error = %v, want it to contain %q", err, want)
+			}
+
+			var prefixErr *PrefixError
+			if !errors.As(err, &prefixErr) || prefixErr.Block != prefix || prefixErr.Got != "pacX" {
+				t.Errorf("error = %v, want a PrefixError for %s", err, prefix)
+			}
+		})
+	}
+
+	if _, err := ParseReading(make([]byte, 10)); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("short packet: error = %v, want ErrBadPacket", err)
+	}
+}
@@ -8,7 +8,7 @@ import (
 // DecryptPacket decrypts the 192-byte encrypted packet using AES-ECB
 func DecryptPacket(encrypted []byte) ([]byte, error) {
 	if len(encrypted) != PacketSize {
-		return nil, fmt.Errorf("invalid packet size: expected %d, got %d", PacketSize, len(encrypted))
+		return nil, fmt.Errorf("%w: invalid packet size: expected %d, got %d", ErrBadPacket, PacketSize, len(encrypted))
 	}
 
 	// Create AES cipher with the static key
@@ -37,7 +37,7 @@ func DecryptPacket(encrypted []byte) ([]byte, error) {
 // ReorderBlocks detects block order and reorders them to pac1, pac2, pac3
 func ReorderBlocks(data []byte) ([]byte, error) {
 	if len(data) != PacketSize {
-		return nil, fmt.Errorf("invalid data size: expected %d, got %d", PacketSize, len(data))
+		return nil, fmt.Errorf("%w: invalid data size: expected %d, got %d", ErrBadPacket, PacketSize, len(data))
 	}
 
 	// Detect block positions
@@ -55,13 +55,13 @@ func ReorderBlocks(data []byte) ([]byte, error) {
 
 	// Verify all expected blocks are present
 	if _, ok := blocks[Block1Prefix]; !ok {
-		return nil, fmt.Errorf("missing %s block", Block1Prefix)
+		return nil, &PrefixError{Block: Block1Prefix}
 	}
 	if _, ok := blocks[Block2Prefix]; !ok {
-		return nil, fmt.Errorf("missing %s block", Block2Prefix)
+		return nil, &PrefixError{Block: Block2Prefix}
 	}
 	if _, ok := blocks[Block3Prefix]; !ok {
-		return nil, fmt.Errorf("missing %s block", Block3Prefix)
+		return nil, &PrefixError{Block: Block3Prefix}
 	}
 
 	// Check if already in correct order
@@ -0,0 +1,101 @@
+package tc66c
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReorderBlocks(t *testing.T) {
+	plain := buildPlainPacket(testReading)
+	blocks := [][]byte{
+		plain[0:BlockSize],
+		plain[BlockSize : 2*BlockSize],
+		plain[2*BlockSize:],
+	}
+
+	permutations := [][3]int{
+		{0, 1, 2}, {0, 2, 1},
+		{1, 0, 2}, {1, 2, 0},
+		{2, 0, 1}, {2, 1, 0},
+	}
+
+	for _, perm := range permutations {
+		var shuffled []byte
+		var names []string
+		for _, i := range perm {
+			shuffled = append(shuffled, blocks[i]...)
+			names = append(names, string(blocks[i][:4]))
+		}
+
+		t.Run(strings.Join(names, "-"), func(t *testing.T) {
+			reordered, err := ReorderBlocks(shuffled)
+			if err != nil {
+				t.Fatalf("ReorderBlocks: %v", err)
+			}
+			if !bytes.Equal(reordered, plain) {
+				t.Fatal("blocks not restored to pac1, pac2, pac3 order")
+			}
+		})
+	}
+}
+
+func TestReorderBlocksErrors(t *testing.T) {
+	plain := buildPlainPacket(testReading)
+
+	duplicated := append([]byte(nil), plain...)
+	copy(duplicated[2*BlockSize:], plain[BlockSize:2*BlockSize])
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{name: "short", data: plain[:100], wantErr: "invalid data size"},
+		{name: "long", data: append(append([]byte(nil), plain...), 0), wantErr: "invalid data size"},
+		{name: "missing pac3", data: duplicated, wantErr: "missing pac3 block"},
+		{name: "zeros", data: make([]byte, PacketSize), wantErr: "missing pac1 block"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReorderBlocks(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, ErrBadPacket) {
+				t.Errorf("error = %v, want it to be ErrBadPacket", err)
+			}
+		})
+	}
+}
+
+func TestDecryptPacket(t *testing.T) {
+	plain := buildPlainPacket(testReading)
+
+	// The meter may send the blocks in any order
+	shuffled := append(append(append([]byte(nil), plain[2*BlockSize:]...), plain[:BlockSize]...), plain[BlockSize:2*BlockSize]...)
+
+	decrypted, err := DecryptPacket(encryptPacket(t, shuffled))
+	if err != nil {
+		t.Fatalf("DecryptPacket: %v", err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Fatal("decrypted packet does not match")
+	}
+
+	if _, err := DecryptPacket(plain[:64]); err == nil {
+		t.Error("DecryptPacket accepted a short packet")
+	}
+}
+
+func TestCalculateCRC16Modbus(t *testing.T) {
+	// Check value from the CRC catalogue
+	if crc := CalculateCRC16Modbus([]byte("123456789")); crc != 0x4B37 {
+		t.Errorf("CRC = %#04x, want 0x4b37", crc)
+	}
+	if crc := CalculateCRC16Modbus(nil); crc != 0xFFFF {
+		t.Errorf("CRC of nothing = %#04x, want 0xffff", crc)
+	}
+}
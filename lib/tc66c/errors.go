@@ -0,0 +1,154 @@
+package tc66c
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the device methods. They are always wrapped, so use
+// errors.Is and errors.As rather than comparing errors directly
+var (
+	// ErrTimeout means the device stopped answering before a full response
+	// arrived. The device is usually still there, so the command can be retried
+	ErrTimeout = errors.New("timeout")
+
+	// ErrWrongMode means the command is not available in the current device
+	// mode. Retrying will not help until the device is replugged
+	ErrWrongMode = errors.New("wrong device mode")
+
+	// ErrDisconnected means the serial port failed or was closed, usually
+	// because the device was unplugged. The port has to be reopened
+	ErrDisconnected = errors.New("device disconnected")
+
+	// ErrBadPacket means a reading packet could not be decoded. Every
+	// ChecksumError and PrefixError is also an ErrBadPacket. The packet was
+	// most likely corrupted on the wire, so the reading can be retried
+	ErrBadPacket = errors.New("bad packet")
+
+	// ErrClosed is returned by commands sent after Close, including those
+	// still waiting for their turn when it was called. It is an
+	// ErrDisconnected
+	ErrClosed = fmt.Errorf("device closed: %w", ErrDisconnected)
+
+	// ErrUnsupported is returned by Meter methods the meter model does not
+	// have, such as recordings on a UM-series meter. Check Capabilities to
+	// avoid it
+	ErrUnsupported = errors.New("not supported by this meter")
+
+	// ErrUnknownLayout means the packet comes from a product or firmware
+	// version missing from KnownLayouts. Every LayoutError is an
+	// ErrUnknownLayout. ParseReading decodes such packets with FallbackLayout
+	// instead, only ParseReadingStrict and LookupLayout return it
+	ErrUnknownLayout = errors.New("unknown packet layout")
+)
+
+// ShortReadError is returned when the device sent fewer bytes than the
+// response needs before going quiet. It is an ErrTimeout
+type ShortReadError struct {
+	Got  int // Bytes received
+	Want int // Bytes expected
+}
+
+func (e *ShortReadError) Error() string {
+	return fmt.Sprintf("timeout reading response (got %d of %d bytes)", e.Got, e.Want)
+}
+
+func (e *ShortReadError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// ModeError is returned when a command needs a different device mode. It is
+// an ErrWrongMode
+type ModeError struct {
+	Want DeviceMode // Mode the command needs
+	Got  DeviceMode // Current device mode
+}
+
+func (e *ModeError) Error() string {
+	return fmt.Sprintf("device must be in %s mode (current mode: %s)", e.Want, e.Got)
+}
+
+func (e *ModeError) Is(target error) bool {
+	return target == ErrWrongMode
+}
+
+// ChecksumError is returned when a packet block fails its CRC check. It is
+// an ErrBadPacket
+type ChecksumError struct {
+	Block string // Block prefix, e.g. "pac2"
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum verification failed", e.Block)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrBadPacket
+}
+
+// PrefixError is returned when a packet block does not start with the
+// expected prefix. It is an ErrBadPacket
+type PrefixError struct {
+	Block string // Expected block prefix
+	Got   string // Prefix found, empty if the block is missing
+}
+
+func (e *PrefixError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("missing %s block", e.Block)
+	}
+	return fmt.Sprintf("invalid %s prefix: expected %s, got %s", e.Block, e.Block, e.Got)
+}
+
+func (e *PrefixError) Is(target error) bool {
+	return target == ErrBadPacket
+}
+
+// MarkerError is returned when a UM-series frame does not start or end
+// with a known marker. It is an ErrBadPacket
+type MarkerError struct {
+	End bool   // The end marker is wrong, rather than the start marker
+	Got uint16 // Marker found
+}
+
+func (e *MarkerError) Error() string {
+	if e.End {
+		return fmt.Sprintf("invalid UM frame end marker: expected %#04x, got %#04x", umEndMarker, e.Got)
+	}
+	return fmt.Sprintf("unknown UM frame start marker %#04x", e.Got)
+}
+
+func (e *MarkerError) Is(target error) bool {
+	return target == ErrBadPacket
+}
+
+// LayoutError is returned when there is no known layout for the product and
+// firmware version of a packet. It is an ErrUnknownLayout
+type LayoutError struct {
+	Product string // Product name from pac1
+	Version string // Firmware version from pac1
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("no known packet layout for %s firmware %s", e.Product, e.Version)
+}
+
+func (e *LayoutError) Is(target error) bool {
+	return target == ErrUnknownLayout
+}
+
+// ResponseError is returned when the device answers a command with
+// something other than the expected reply
+type ResponseError struct {
+	Got  string // Reply received
+	Want string // Reply expected
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("device replied with %q, expected %q", e.Got, e.Want)
+}
+
+// portError marks an error returned by the serial port as a disconnect
+func portError(op string, err error) error {
+	return fmt.Errorf("%s: %w: %w", op, ErrDisconnected, err)
+}
@@ -0,0 +1,216 @@
+package tc66c
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// fakeStep is one exchange of a fakePort script: the bytes the library must
+// write, and the reads the device answers with. Each reply element is
+// returned by one Read call (split further if the caller's buffer is
+// smaller); a nil element is a read timeout
+type fakeStep struct {
+	expect []byte
+	reply  [][]byte
+}
+
+// fakePort is a scripted serial.Port. Reads with nothing queued time out
+// immediately by returning 0 bytes
+type fakePort struct {
+	t       *testing.T
+	script  []fakeStep
+	pending [][]byte
+	closed  bool
+}
+
+func newFakePort(t *testing.T, script ...fakeStep) *fakePort {
+	t.Helper()
+	return &fakePort{t: t, script: script}
+}
+
+// cmdStep expects a text command and answers with reply
+func cmdStep(cmd string, reply ...[]byte) fakeStep {
+	return fakeStep{expect: []byte(cmd + "\r\n"), reply: reply}
+}
+
+// done fails the test if part of the script was not played
+func (fp *fakePort) done() {
+	fp.t.Helper()
+	if len(fp.script) > 0 {
+		fp.t.Errorf("%d scripted exchanges not played, next expects %q", len(fp.script), fp.script[0].expect)
+	}
+}
+
+func (fp *fakePort) Read(p []byte) (int, error) {
+	if fp.closed {
+		return 0, fmt.Errorf("fake port closed")
+	}
+	if len(fp.pending) == 0 {
+		return 0, nil
+	}
+
+	chunk := fp.pending[0]
+	if chunk == nil {
+		fp.pending = fp.pending[1:]
+		return 0, nil
+	}
+
+	n := copy(p, chunk)
+	if n < len(chunk) {
+		fp.pending[0] = chunk[n:]
+	} else {
+		fp.pending = fp.pending[1:]
+	}
+	return n, nil
+}
+
+func (fp *fakePort) Write(p []byte) (int, error) {
+	fp.t.Helper()
+	if fp.closed {
+		return 0, fmt.Errorf("fake port closed")
+	}
+	if len(fp.script) == 0 {
+		fp.t.Errorf("unexpected write %q after the end of the script", p)
+		return 0, fmt.Errorf("unexpected write")
+	}
+
+	step := fp.script[0]
+	if !bytes.Equal(p, step.expect) {
+		fp.t.Errorf("wrote %q, expected %q", p, step.expect)
+		return 0, fmt.Errorf("unexpected write")
+	}
+
+	fp.script = fp.script[1:]
+	fp.pending = append(fp.pending, step.reply...)
+	return len(p), nil
+}
+
+// ResetInputBuffer drops every queued reply that was not read yet
+func (fp *fakePort) ResetInputBuffer() error {
+	fp.pending = nil
+	return nil
+}
+
+func (fp *fakePort) Close() error {
+	fp.closed = true
+	return nil
+}
+
+func (fp *fakePort) SetMode(mode *serial.Mode) error      { return nil }
+func (fp *fakePort) Drain() error                         { return nil }
+func (fp *fakePort) ResetOutputBuffer() error             { return nil }
+func (fp *fakePort) SetDTR(dtr bool) error                { return nil }
+func (fp *fakePort) SetRTS(rts bool) error                { return nil }
+func (fp *fakePort) SetReadTimeout(t time.Duration) error { return nil }
+func (fp *fakePort) Break(time.Duration) error            { return nil }
+func (fp *fakePort) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{}, nil
+}
+
+// newTestDevice returns a TC66C on a fake port that first answers the mode
+// query with mode ("firm" or "boot") and then plays script
+func newTestDevice(t *testing.T, mode string, script ...fakeStep) (*TC66C, *fakePort) {
+	t.Helper()
+
+	fp := newFakePort(t, append([]fakeStep{cmdStep(CmdQuery, []byte(mode))}, script...)...)
+	tc, err := NewTC66CFromPort(fp)
+	if err != nil {
+		t.Fatalf("NewTC66CFromPort: %v", err)
+	}
+	t.Cleanup(func() { tc.Close() })
+	return tc, fp
+}
+
+// testReading is the reading encoded by buildPlainPacket
+var testReading = Reading{
+	Product:         "TC66",
+	Version:         "1.18",
+	SerialNumber:    123456,
+	NumRuns:         42,
+	Voltage:         5.1234,
+	Current:         1.23456,
+	Power:           6.3251,
+	Resistance:      4.15,
+	Group0MAh:       100,
+	Group0MWh:       500,
+	Group1MAh:       200,
+	Group1MWh:       1000,
+	TemperatureSign: 0,
+	Temperature:     27,
+	DPlusVoltage:    0.6,
+	DMinusVoltage:   0.59,
+}
+
+// buildPlainPacket encodes r as a decrypted 192-byte packet with valid
+// prefixes and checksums
+func buildPlainPacket(r Reading) []byte {
+	data := make([]byte, PacketSize)
+	put := func(offset int, v uint32) { binary.LittleEndian.PutUint32(data[offset:], v) }
+
+	copy(data[0:], Block1Prefix)
+	copy(data[4:8], r.Product)
+	copy(data[8:12], r.Version)
+	put(12, r.SerialNumber)
+	put(44, r.NumRuns)
+	put(48, uint32(r.Voltage*1e4+0.5))
+	put(52, uint32(r.Current*1e5+0.5))
+	put(56, uint32(r.Power*1e4+0.5))
+
+	pac2 := BlockSize
+	copy(data[pac2:], Block2Prefix)
+	put(pac2+4, uint32(r.Resistance*1e2+0.5))
+	put(pac2+8, r.Group0MAh)
+	put(pac2+12, r.Group0MWh)
+	put(pac2+16, r.Group1MAh)
+	put(pac2+20, r.Group1MWh)
+	put(pac2+24, r.TemperatureSign)
+	put(pac2+28, uint32(r.Temperature))
+	put(pac2+32, uint32(r.DPlusVoltage*1e2+0.5))
+	put(pac2+36, uint32(r.DMinusVoltage*1e2+0.5))
+
+	copy(data[2*BlockSize:], Block3Prefix)
+
+	for block := 0; block < NumBlocks; block++ {
+		sealBlock(data, block)
+	}
+	return data
+}
+
+// sealBlock recomputes the checksum of a block of a plain packet
+func sealBlock(data []byte, block int) {
+	offset := block * BlockSize
+	crc := CalculateCRC16Modbus(data[offset : offset+60])
+	binary.LittleEndian.PutUint32(data[offset+60:], uint32(crc))
+}
+
+// encryptPacket encrypts a plain packet as the meter does
+func encryptPacket(t testing.TB, plain []byte) []byte {
+	t.Helper()
+
+	block, err := aes.NewCipher(AESKey)
+	if err != nil {
+		t.Fatalf("aes.NewCipher: %v", err)
+	}
+
+	encrypted := make([]byte, len(plain))
+	for i := 0; i < len(plain); i += block.BlockSize() {
+		block.Encrypt(encrypted[i:i+block.BlockSize()], plain[i:i+block.BlockSize()])
+	}
+	return encrypted
+}
+
+// split cuts data into chunks of at most size bytes
+func split(data []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return append(chunks, data)
+}
@@ -0,0 +1,188 @@
+package tc66c
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Firmware image size limits. Vendor images are a few tens of KiB, anything
+// outside these bounds is almost certainly the wrong file
+const (
+	FirmwareMinSize = 1024       // Smallest image accepted
+	FirmwareMaxSize = 128 * 1024 // Largest image accepted
+)
+
+// KnownFirmware describes a firmware image known to be good
+type KnownFirmware struct {
+	Product string `json:"product"` // Product name (e.g., "TC66")
+	Version string `json:"version"` // Firmware version (e.g., "1.18")
+	Size    int    `json:"size"`    // Image size in bytes
+	SHA256  string `json:"sha256"`  // Hex encoded SHA-256 of the image
+}
+
+// KnownFirmwareImages lists the firmware images known to be good
+var KnownFirmwareImages = []KnownFirmware{
+	{
+		Product: "TC66",
+		Version: "1.18",
+		Size:    51840,
+		SHA256:  "ef796b29557c91e0a469740ba1934ca0a838916c76ab55eb6d1253da32830940",
+	},
+}
+
+// VectorTable holds the first two entries of a Cortex-M vector table
+type VectorTable struct {
+	InitialSP    uint32 `json:"initial_sp"`    // Initial stack pointer
+	ResetHandler uint32 `json:"reset_handler"` // Reset handler address (Thumb bit set)
+}
+
+// FirmwareInfo is the result of inspecting a firmware image
+type FirmwareInfo struct {
+	Size        int            `json:"size"`                   // Image size in bytes
+	Chunks      int            `json:"chunks"`                 // Number of chunks sent to the bootloader
+	Aligned     bool           `json:"aligned"`                // Size is a multiple of FirmwareChunkSize
+	SHA256      string         `json:"sha256"`                 // Hex encoded SHA-256 of the image
+	Known       *KnownFirmware `json:"known,omitempty"`        // Matching known-good image, if any
+	Version     string         `json:"version,omitempty"`      // Version string found in the image, if any
+	VectorTable *VectorTable   `json:"vector_table,omitempty"` // Plaintext vector table, if any
+}
+
+// FirmwareChunk is one chunk of an image as it will be sent to the bootloader
+type FirmwareChunk struct {
+	Index  int `json:"index"`  // 1-based chunk number
+	Offset int `json:"offset"` // Offset in the image
+	Size   int `json:"size"`   // Chunk size (only the last chunk may be short)
+}
+
+// PlanFirmwareChunks splits an image into the chunks UpdateFirmware sends
+func PlanFirmwareChunks(data []byte) []FirmwareChunk {
+	chunks := make([]FirmwareChunk, 0, (len(data)+FirmwareChunkSize-1)/FirmwareChunkSize)
+	for offset := 0; offset < len(data); offset += FirmwareChunkSize {
+		size := FirmwareChunkSize
+		if offset+size > len(data) {
+			size = len(data) - offset
+		}
+		chunks = append(chunks, FirmwareChunk{
+			Index:  len(chunks) + 1,
+			Offset: offset,
+			Size:   size,
+		})
+	}
+	return chunks
+}
+
+// versionPattern matches version strings such as "V1.18" or "ver 1.18"
+var versionPattern = regexp.MustCompile(`(?i)(?:ver(?:sion)?[ :]?|v)(\d{1,2}\.\d{2})`)
+
+// InspectFirmware gathers what can be learnt from a firmware image without
+// talking to the device
+func InspectFirmware(data []byte) *FirmwareInfo {
+	sum := sha256.Sum256(data)
+
+	info := &FirmwareInfo{
+		Size:    len(data),
+		Chunks:  (len(data) + FirmwareChunkSize - 1) / FirmwareChunkSize,
+		Aligned: len(data) > 0 && len(data)%FirmwareChunkSize == 0,
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+
+	for i := range KnownFirmwareImages {
+		known := &KnownFirmwareImages[i]
+		if known.Size == info.Size && strings.EqualFold(known.SHA256, info.SHA256) {
+			info.Known = known
+			break
+		}
+	}
+
+	if match := versionPattern.FindSubmatch(data); match != nil {
+		info.Version = string(match[1])
+	}
+
+	info.VectorTable = findVectorTable(data)
+
+	return info
+}
+
+// findVectorTable returns the vector table at the start of the image if it
+// looks like a plaintext Cortex-M image. Vendor images are encrypted, so
+// this usually returns nil
+func findVectorTable(data []byte) *VectorTable {
+	if len(data) < 8 {
+		return nil
+	}
+
+	sp := binary.LittleEndian.Uint32(data[0:4])
+	reset := binary.LittleEndian.Uint32(data[4:8])
+
+	// Stack pointer must point into SRAM and be word aligned
+	if sp < 0x20000000 || sp >= 0x20100000 || sp%4 != 0 {
+		return nil
+	}
+
+	// Reset handler must be a Thumb address in the code region
+	if reset&1 == 0 || reset >= 0x20000000 {
+		return nil
+	}
+
+	return &VectorTable{InitialSP: sp, ResetHandler: reset}
+}
+
+// Validate checks the image is safe to send to the bootloader. Empty images
+// are always rejected; images failing the other checks, or not matching a
+// known-good image, are rejected unless force is set
+func (fi *FirmwareInfo) Validate(force bool) error {
+	if fi.Size == 0 {
+		return fmt.Errorf("firmware image is empty")
+	}
+
+	if force {
+		return nil
+	}
+
+	if fi.Size < FirmwareMinSize || fi.Size > FirmwareMaxSize {
+		return fmt.Errorf("firmware image size %d is outside the expected range (%d-%d bytes)", fi.Size, FirmwareMinSize, FirmwareMaxSize)
+	}
+
+	if !fi.Aligned {
+		return fmt.Errorf("firmware image size %d is not a multiple of %d bytes", fi.Size, FirmwareChunkSize)
+	}
+
+	if fi.Known == nil {
+		return fmt.Errorf("firmware image is not a known-good image (SHA-256 %s)", fi.SHA256)
+	}
+
+	return nil
+}
+
+// String returns a formatted string representation of the firmware info
+func (fi *FirmwareInfo) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Size: %d bytes (%d chunks of %d bytes)\n", fi.Size, fi.Chunks, FirmwareChunkSize)
+	fmt.Fprintf(&sb, "Aligned: %t\n", fi.Aligned)
+	fmt.Fprintf(&sb, "SHA-256: %s\n", fi.SHA256)
+
+	if fi.Known != nil {
+		fmt.Fprintf(&sb, "Known image: %s v%s\n", fi.Known.Product, fi.Known.Version)
+	} else {
+		fmt.Fprintf(&sb, "Known image: no\n")
+	}
+
+	if fi.Version != "" {
+		fmt.Fprintf(&sb, "Embedded version: %s\n", fi.Version)
+	} else {
+		fmt.Fprintf(&sb, "Embedded version: not found\n")
+	}
+
+	if fi.VectorTable != nil {
+		fmt.Fprintf(&sb, "Vector table: SP=0x%08X Reset=0x%08X", fi.VectorTable.InitialSP, fi.VectorTable.ResetHandler)
+	} else {
+		fmt.Fprintf(&sb, "Vector table: not found (image is likely encrypted)")
+	}
+
+	return sb.String()
+}
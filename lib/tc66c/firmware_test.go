@@ -0,0 +1,150 @@
+package tc66c
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// bundledImage reads the TC66 1.18 image shipped in the firmware directory
+func bundledImage(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "firmware", "TC66_v1.18.bin"))
+	if err != nil {
+		t.Fatalf("reading bundled image: %v", err)
+	}
+	return data
+}
+
+func TestInspectBundledFirmware(t *testing.T) {
+	info := InspectFirmware(bundledImage(t))
+
+	if info.Size != 51840 || info.Chunks != 810 || !info.Aligned {
+		t.Errorf("size %d, %d chunks, aligned %t, want 51840, 810, true", info.Size, info.Chunks, info.Aligned)
+	}
+	if info.SHA256 != "ef796b29557c91e0a469740ba1934ca0a838916c76ab55eb6d1253da32830940" {
+		t.Errorf("SHA-256 = %s", info.SHA256)
+	}
+	if info.Known == nil || info.Known.Product != "TC66" || info.Known.Version != "1.18" {
+		t.Fatalf("Known = %+v, want TC66 1.18", info.Known)
+	}
+	if info.Known.Size != info.Size || info.Known.SHA256 != info.SHA256 {
+		t.Errorf("Known = %+v does not describe the image", info.Known)
+	}
+	if info.VectorTable != nil {
+		t.Errorf("VectorTable = %+v, the vendor image is encrypted", info.VectorTable)
+	}
+	if err := info.Validate(false); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestValidateFirmware(t *testing.T) {
+	image := bundledImage(t)
+
+	unknown := slices.Clone(image)
+	unknown[1000] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string // Without force
+		forced  bool   // Accepted with force
+	}{
+		{name: "bundled", data: image, forced: true},
+		{name: "empty", data: nil, wantErr: "empty"},
+		{name: "truncated", data: image[:len(image)-FirmwareChunkSize], wantErr: "not a known-good image", forced: true},
+		{name: "truncated unaligned", data: image[:len(image)-1], wantErr: "not a multiple of 64", forced: true},
+		{name: "too small", data: image[:FirmwareMinSize-FirmwareChunkSize], wantErr: "outside the expected range", forced: true},
+		{name: "oversized", data: make([]byte, FirmwareMaxSize+FirmwareChunkSize), wantErr: "outside the expected range", forced: true},
+		{name: "unknown hash", data: unknown, wantErr: "not a known-good image", forced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := InspectFirmware(tt.data)
+
+			err := info.Validate(false)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Validate(false): %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Validate(false) = %v, want it to contain %q", err, tt.wantErr)
+			}
+
+			if err := info.Validate(true); (err == nil) != tt.forced {
+				t.Errorf("Validate(true) = %v, want accepted %t", err, tt.forced)
+			}
+		})
+	}
+}
+
+func TestPlanFirmwareChunks(t *testing.T) {
+	chunks := PlanFirmwareChunks(make([]byte, 2*FirmwareChunkSize+5))
+	want := []FirmwareChunk{
+		{Index: 1, Offset: 0, Size: 64},
+		{Index: 2, Offset: 64, Size: 64},
+		{Index: 3, Offset: 128, Size: 5},
+	}
+	if !slices.Equal(chunks, want) {
+		t.Errorf("chunks = %+v, want %+v", chunks, want)
+	}
+
+	chunks = PlanFirmwareChunks(bundledImage(t))
+	if len(chunks) != 810 {
+		t.Fatalf("%d chunks, want 810", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.Size != FirmwareChunkSize {
+			t.Fatalf("chunk %+v is partial, the image is aligned", chunk)
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.Offset+last.Size != 51840 {
+		t.Errorf("last chunk %+v does not end the image", last)
+	}
+
+	if chunks := PlanFirmwareChunks(nil); len(chunks) != 0 {
+		t.Errorf("empty image planned as %+v", chunks)
+	}
+}
+
+func TestInspectFirmwareVectorTableAndVersion(t *testing.T) {
+	vectors := func(sp, reset uint32) []byte {
+		data := make([]byte, FirmwareMinSize)
+		binary.LittleEndian.PutUint32(data[0:], sp)
+		binary.LittleEndian.PutUint32(data[4:], reset)
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want *VectorTable
+	}{
+		{name: "plaintext", data: vectors(0x20005000, 0x08000101), want: &VectorTable{InitialSP: 0x20005000, ResetHandler: 0x08000101}},
+		{name: "stack outside SRAM", data: vectors(0x10005000, 0x08000101)},
+		{name: "unaligned stack", data: vectors(0x20005002, 0x08000101)},
+		{name: "ARM reset handler", data: vectors(0x20005000, 0x08000100)},
+		{name: "reset handler in SRAM", data: vectors(0x20005000, 0x20000101)},
+		{name: "too short", data: []byte{0, 0x50, 0, 0x20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := InspectFirmware(tt.data).VectorTable
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("VectorTable = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	data := make([]byte, FirmwareMinSize)
+	copy(data[200:], "TC66 V1.18 build")
+	if info := InspectFirmware(data); info.Version != "1.18" {
+		t.Errorf("Version = %q, want 1.18", info.Version)
+	}
+}
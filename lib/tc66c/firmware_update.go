@@ -0,0 +1,228 @@
+package tc66c
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// FirmwareUpdateOptions tunes how UpdateFirmwareWithOptions talks to the
+// bootloader
+type FirmwareUpdateOptions struct {
+	// HandshakeTimeout is how long to wait for the "uprdy" reply
+	HandshakeTimeout time.Duration
+
+	// ResponseTimeout is how long to wait for the "OK" after each chunk
+	ResponseTimeout time.Duration
+
+	// ChunkRetries is how many extra ResponseTimeout periods to wait for a
+	// chunk acknowledgement before giving up. Chunks are never sent twice:
+	// the bootloader writes them sequentially, so a resent chunk would be
+	// flashed at the next offset. A failed chunk requires restarting the
+	// whole update
+	ChunkRetries int
+
+	// MaxStrayBytes is how many unexpected bytes are skipped while looking
+	// for a reply before the stream is considered lost
+	MaxStrayBytes int
+
+	// Log receives a structured event for every step of the session (can be nil)
+	Log func(FirmwareUpdateEvent)
+}
+
+// DefaultFirmwareUpdateOptions returns the options used by UpdateFirmware
+func DefaultFirmwareUpdateOptions() FirmwareUpdateOptions {
+	return FirmwareUpdateOptions{
+		HandshakeTimeout: 2 * time.Second,
+		ResponseTimeout:  2 * time.Second,
+		ChunkRetries:     2,
+		MaxStrayBytes:    64,
+	}
+}
+
+// Firmware update event types
+const (
+	EventHandshake  = "handshake"   // "update" command sent
+	EventReady      = "ready"       // "uprdy" received
+	EventChunkSent  = "chunk-sent"  // Chunk written to the port
+	EventChunkOK    = "chunk-ok"    // Chunk acknowledged
+	EventStrayBytes = "stray-bytes" // Unexpected bytes skipped while resynchronising
+	EventRetry      = "retry"       // Waiting again for a reply
+	EventError      = "error"       // Session failed
+	EventDone       = "done"        // All chunks acknowledged
+)
+
+// FirmwareUpdateEvent is one entry of the firmware update session log
+type FirmwareUpdateEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Chunk   int       `json:"chunk,omitempty"`   // 1-based chunk number
+	Attempt int       `json:"attempt,omitempty"` // 1-based wait attempt for the reply
+	Data    []byte    `json:"data,omitempty"`    // Bytes involved (stray bytes, replies)
+	Message string    `json:"message,omitempty"`
+}
+
+// UpdateFirmwareWithOptions updates the device firmware like UpdateFirmware,
+// resynchronising on stray bytes and waiting for slow acknowledgements as
+// configured in opts. Zero timeouts fall back to the defaults. The whole
+// session holds the device, and opts.Log and progressCallback are called
+// from the device goroutine
+func (tc *TC66C) UpdateFirmwareWithOptions(firmwareData []byte, opts FirmwareUpdateOptions, progressCallback func(FirmwareUpdateProgress)) error {
+	return tc.sched.run(priorityBulk, func() error {
+		return tc.updateFirmware(firmwareData, opts, progressCallback)
+	})
+}
+
+// updateFirmware runs a firmware update session. Runs on the device goroutine
+func (tc *TC66C) updateFirmware(firmwareData []byte, opts FirmwareUpdateOptions, progressCallback func(FirmwareUpdateProgress)) error {
+	defaults := DefaultFirmwareUpdateOptions()
+	if opts.HandshakeTimeout <= 0 {
+		opts.HandshakeTimeout = defaults.HandshakeTimeout
+	}
+	if opts.ResponseTimeout <= 0 {
+		opts.ResponseTimeout = defaults.ResponseTimeout
+	}
+	if opts.ChunkRetries < 0 {
+		opts.ChunkRetries = 0
+	}
+	if opts.MaxStrayBytes < 0 {
+		opts.MaxStrayBytes = 0
+	}
+
+	logEvent := func(ev FirmwareUpdateEvent) {
+		if opts.Log != nil {
+			ev.Time = time.Now()
+			opts.Log(ev)
+		}
+	}
+
+	fail := func(chunk int, err error) error {
+		logEvent(FirmwareUpdateEvent{Type: EventError, Chunk: chunk, Message: err.Error()})
+		return err
+	}
+
+	// Safety check: device must be in bootloader mode
+	if tc.Mode != ModeBootloader {
+		return fail(0, fmt.Errorf("cannot update firmware: %w", &ModeError{Want: ModeBootloader, Got: tc.Mode}))
+	}
+
+	// Calculate file size and chunk count
+	fileSize := len(firmwareData)
+	if fileSize == 0 {
+		return fail(0, fmt.Errorf("firmware data is empty"))
+	}
+
+	chunkCount := (fileSize + FirmwareChunkSize - 1) / FirmwareChunkSize
+
+	// Enter firmware update mode
+	logEvent(FirmwareUpdateEvent{Type: EventHandshake, Message: CmdUpdate})
+	err := tc.sendCommand(CmdUpdate)
+	if err != nil {
+		return fail(0, fmt.Errorf("failed to send update command: %w", err))
+	}
+
+	// Wait for "uprdy", skipping anything the bootloader printed before it
+	err = tc.waitForReply(UpdateModeResponse, opts.HandshakeTimeout, 0, 0, opts, logEvent)
+	if err != nil {
+		return fail(0, fmt.Errorf("failed to enter update mode: %w", err))
+	}
+	logEvent(FirmwareUpdateEvent{Type: EventReady, Data: []byte(UpdateModeResponse)})
+
+	// Send firmware in chunks
+	bytesSent := 0
+	chunksSent := 0
+
+	for bytesSent < fileSize {
+		// Calculate chunk size (last chunk may be smaller)
+		chunkEnd := bytesSent + FirmwareChunkSize
+		if chunkEnd > fileSize {
+			chunkEnd = fileSize
+		}
+		chunk := firmwareData[bytesSent:chunkEnd]
+		chunkNum := chunksSent + 1
+
+		// Send chunk
+		_, err := tc.port.Write(chunk)
+		if err != nil {
+			return fail(chunkNum, portError(fmt.Sprintf("failed to write chunk %d", chunkNum), err))
+		}
+		logEvent(FirmwareUpdateEvent{Type: EventChunkSent, Chunk: chunkNum})
+
+		// Wait for "OK", resynchronising on stray bytes
+		err = tc.waitForReply(ChunkOKResponse, opts.ResponseTimeout, opts.ChunkRetries, chunkNum, opts, logEvent)
+		if err != nil {
+			return fail(chunkNum, fmt.Errorf("chunk %d was not acknowledged: %w. Device may not boot normally, try again", chunkNum, err))
+		}
+		logEvent(FirmwareUpdateEvent{Type: EventChunkOK, Chunk: chunkNum})
+
+		// Update progress
+		bytesSent += len(chunk)
+		chunksSent++
+
+		// Call progress callback if provided
+		if progressCallback != nil {
+			progressCallback(FirmwareUpdateProgress{
+				BytesSent:   bytesSent,
+				TotalBytes:  fileSize,
+				ChunksSent:  chunksSent,
+				TotalChunks: chunkCount,
+			})
+		}
+	}
+
+	logEvent(FirmwareUpdateEvent{Type: EventDone, Message: fmt.Sprintf("%d chunks written", chunksSent)})
+
+	return nil
+}
+
+// waitForReply reads until reply is seen, skipping up to opts.MaxStrayBytes
+// unexpected bytes before it. If nothing useful arrives within timeout it
+// waits again up to retries more times
+func (tc *TC66C) waitForReply(reply string, timeout time.Duration, retries, chunk int, opts FirmwareUpdateOptions, logEvent func(FirmwareUpdateEvent)) error {
+	want := []byte(reply)
+	received := make([]byte, 0, len(want))
+	buf := make([]byte, 64)
+
+	// Poll in short slices so the overall timeout is honoured
+	tc.setReadTimeout(50 * time.Millisecond)
+	defer tc.setReadTimeout(responseTimeout)
+
+	for attempt := 1; attempt <= retries+1; attempt++ {
+		if attempt > 1 {
+			logEvent(FirmwareUpdateEvent{Type: EventRetry, Chunk: chunk, Attempt: attempt, Data: received})
+		}
+
+		deadline := time.Now().Add(timeout)
+		for time.Now().Before(deadline) {
+			n, err := tc.port.Read(buf)
+			if err != nil {
+				return portError("failed to read response", err)
+			}
+			if n == 0 {
+				continue
+			}
+			received = append(received, buf[:n]...)
+
+			idx := bytes.Index(received, want)
+			if idx >= 0 {
+				if idx > 0 {
+					logEvent(FirmwareUpdateEvent{Type: EventStrayBytes, Chunk: chunk, Data: append([]byte(nil), received[:idx]...), Message: "skipped before reply"})
+				}
+				if rest := received[idx+len(want):]; len(rest) > 0 {
+					logEvent(FirmwareUpdateEvent{Type: EventStrayBytes, Chunk: chunk, Data: append([]byte(nil), rest...), Message: "discarded after reply"})
+				}
+				return nil
+			}
+
+			// Give up once more stray bytes than allowed have arrived
+			if len(received) > opts.MaxStrayBytes+len(want) {
+				return &ResponseError{Got: string(received), Want: reply}
+			}
+		}
+	}
+
+	if len(received) > 0 {
+		return &ResponseError{Got: string(received), Want: reply}
+	}
+	return fmt.Errorf("%w waiting for %q", ErrTimeout, reply)
+}
@@ -0,0 +1,238 @@
+package tc66c
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFirmwareOptions keeps failing updates fast
+func testFirmwareOptions() FirmwareUpdateOptions {
+	return FirmwareUpdateOptions{
+		HandshakeTimeout: 20 * time.Millisecond,
+		ResponseTimeout:  20 * time.Millisecond,
+		ChunkRetries:     1,
+		MaxStrayBytes:    16,
+	}
+}
+
+// testFirmwareImage returns an image of size bytes with distinct chunks
+func testFirmwareImage(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/FirmwareChunkSize)
+	}
+	return data
+}
+
+// firmwareScript returns the exchanges of an update of image, with the
+// acknowledgement of each chunk taken from acks (default "OK")
+func firmwareScript(image []byte, handshake [][]byte, acks map[int][][]byte) []fakeStep {
+	script := []fakeStep{cmdStep(CmdUpdate, handshake...)}
+	for i, chunk := range split(image, FirmwareChunkSize) {
+		ack, ok := acks[i+1]
+		if !ok {
+			ack = [][]byte{[]byte(ChunkOKResponse)}
+		}
+		script = append(script, fakeStep{expect: chunk, reply: ack})
+	}
+	return script
+}
+
+func TestUpdateFirmware(t *testing.T) {
+	image := testFirmwareImage(3*FirmwareChunkSize + 10)
+	tc, fp := newTestDevice(t, "boot", firmwareScript(image, [][]byte{[]byte("uprdy")}, nil)...)
+
+	var progress []FirmwareUpdateProgress
+	var events []string
+	opts := testFirmwareOptions()
+	opts.Log = func(ev FirmwareUpdateEvent) { events = append(events, ev.Type) }
+
+	err := tc.UpdateFirmwareWithOptions(image, opts, func(p FirmwareUpdateProgress) {
+		progress = append(progress, p)
+	})
+	fp.done()
+	if err != nil {
+		t.Fatalf("UpdateFirmware: %v", err)
+	}
+
+	if len(progress) != 4 {
+		t.Fatalf("got %d progress callbacks, want 4", len(progress))
+	}
+	last := progress[3]
+	if last.BytesSent != len(image) || last.TotalBytes != len(image) || last.ChunksSent != 4 || last.TotalChunks != 4 {
+		t.Errorf("last progress = %+v", last)
+	}
+	if events[0] != EventHandshake || events[1] != EventReady || events[len(events)-1] != EventDone {
+		t.Errorf("events = %v", events)
+	}
+}
+
+func TestUpdateFirmwareHandshake(t *testing.T) {
+	image := testFirmwareImage(FirmwareChunkSize)
+
+	tests := []struct {
+		name    string
+		reply   [][]byte
+		wantErr string
+	}{
+		{name: "split uprdy", reply: [][]byte{[]byte("up"), []byte("rdy")}},
+		{name: "noise before uprdy", reply: [][]byte{[]byte("\r\nboot v1\r\n"), []byte("uprdy")}},
+		{name: "bad uprdy", reply: [][]byte{[]byte("upbad")}, wantErr: `device replied with "upbad", expected "uprdy"`},
+		{name: "too much noise", reply: [][]byte{bytes.Repeat([]byte("x"), 40)}, wantErr: `expected "uprdy"`},
+		{name: "timeout", reply: nil, wantErr: `timeout waiting for "uprdy"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := []fakeStep{cmdStep(CmdUpdate, tt.reply...)}
+			if tt.wantErr == "" {
+				script = firmwareScript(image, tt.reply, nil)
+			}
+
+			tc, fp := newTestDevice(t, "boot", script...)
+			err := tc.UpdateFirmwareWithOptions(image, testFirmwareOptions(), nil)
+
+			if tt.wantErr == "" {
+				fp.done()
+				if err != nil {
+					t.Fatalf("UpdateFirmware: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "failed to enter update mode") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateFirmwareChunkErrors(t *testing.T) {
+	image := testFirmwareImage(3 * FirmwareChunkSize)
+
+	tests := []struct {
+		name    string
+		acks    map[int][][]byte
+		wantErr string
+		wantIs  error
+	}{
+		{name: "error reply", acks: map[int][][]byte{2: {[]byte("ERR")}}, wantErr: `chunk 2 was not acknowledged: device replied with "ERR"`},
+		{name: "no reply", acks: map[int][][]byte{3: nil}, wantErr: `chunk 3 was not acknowledged: timeout waiting for "OK"`, wantIs: ErrTimeout},
+		{name: "partial reply", acks: map[int][][]byte{1: {[]byte("O")}}, wantErr: `chunk 1 was not acknowledged: device replied with "O"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Stop the script at the failing chunk: nothing may be sent after it
+			script := firmwareScript(image, [][]byte{[]byte("uprdy")}, tt.acks)
+			for failing := range tt.acks {
+				script = script[:failing+1]
+			}
+
+			tc, fp := newTestDevice(t, "boot", script...)
+			var chunksSent int
+			err := tc.UpdateFirmwareWithOptions(image, testFirmwareOptions(), func(p FirmwareUpdateProgress) {
+				chunksSent = p.ChunksSent
+			})
+			fp.done()
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			var response *ResponseError
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("error = %v, want it to be %v", err, tt.wantIs)
+			} else if tt.wantIs == nil && !errors.As(err, &response) {
+				t.Errorf("error = %v, want a ResponseError", err)
+			}
+			for failing := range tt.acks {
+				if chunksSent != failing-1 {
+					t.Errorf("progress reported %d chunks, want %d", chunksSent, failing-1)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateFirmwareStrayBytesAroundOK(t *testing.T) {
+	image := testFirmwareImage(2 * FirmwareChunkSize)
+	acks := map[int][][]byte{1: {[]byte("\x00\x00OK")}, 2: {[]byte("O"), []byte("K\r\n")}}
+	tc, fp := newTestDevice(t, "boot", firmwareScript(image, [][]byte{[]byte("uprdy")}, acks)...)
+
+	var stray int
+	opts := testFirmwareOptions()
+	opts.Log = func(ev FirmwareUpdateEvent) {
+		if ev.Type == EventStrayBytes {
+			stray++
+		}
+	}
+
+	if err := tc.UpdateFirmwareWithOptions(image, opts, nil); err != nil {
+		t.Fatalf("UpdateFirmware: %v", err)
+	}
+	fp.done()
+
+	if stray != 2 {
+		t.Errorf("logged %d stray byte events, want 2", stray)
+	}
+}
+
+func TestUpdateFirmwarePreconditions(t *testing.T) {
+	firm, _ := newTestDevice(t, "firm")
+	if err := firm.UpdateFirmware(testFirmwareImage(64), nil); !errors.Is(err, ErrWrongMode) || !strings.Contains(err.Error(), "bootloader mode") {
+		t.Errorf("update in firmware mode: error = %v", err)
+	}
+
+	boot, _ := newTestDevice(t, "boot")
+	if err := boot.UpdateFirmware(nil, nil); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("empty image: error = %v", err)
+	}
+}
+
+func TestUpdateFirmwareReplay(t *testing.T) {
+	image := testFirmwareImage(2*FirmwareChunkSize + 1)
+
+	// Record a session against the scripted port...
+	var transcript bytes.Buffer
+	fp := newFakePort(t, append([]fakeStep{cmdStep(CmdQuery, []byte("boot"))},
+		firmwareScript(image, [][]byte{[]byte("uprdy")}, nil)...)...)
+	tc, err := NewTC66CFromPort(NewTranscriptPort(fp, &transcript))
+	if err != nil {
+		t.Fatalf("NewTC66CFromPort: %v", err)
+	}
+	if err := tc.UpdateFirmwareWithOptions(image, testFirmwareOptions(), nil); err != nil {
+		t.Fatalf("recording UpdateFirmware: %v", err)
+	}
+
+	// ...and play it back
+	entries, err := LoadTranscript(&transcript)
+	if err != nil {
+		t.Fatalf("LoadTranscript: %v", err)
+	}
+	rp, err := NewReplayPort(entries)
+	if err != nil {
+		t.Fatalf("NewReplayPort: %v", err)
+	}
+
+	tc, err = NewTC66CFromPort(rp)
+	if err != nil {
+		t.Fatalf("NewTC66CFromPort: %v", err)
+	}
+	if err := tc.UpdateFirmwareWithOptions(image, testFirmwareOptions(), nil); err != nil {
+		t.Fatalf("replayed UpdateFirmware: %v", err)
+	}
+	if !rp.Done() {
+		t.Error("transcript not fully played back")
+	}
+
+	// A different image must not match the transcript
+	rp, _ = NewReplayPort(entries)
+	tc, _ = NewTC66CFromPort(rp)
+	other := append([]byte(nil), image...)
+	other[FirmwareChunkSize] ^= 0xFF
+	if err := tc.UpdateFirmwareWithOptions(other, testFirmwareOptions(), nil); err == nil || !strings.Contains(err.Error(), "failed to write chunk 2") {
+		t.Errorf("replay with a different image: error = %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package tc66c
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fuzzSeeds returns plain packets that exercise the interesting parts of the
+// decoder: every golden reading plus malformed block layouts
+func fuzzSeeds(t testing.TB) [][]byte {
+	plain := buildPlainPacket(testReading)
+	pac1, pac2, pac3 := plain[:BlockSize], plain[BlockSize:2*BlockSize], plain[2*BlockSize:]
+
+	seeds := [][]byte{
+		plain,
+		concat(pac3, pac1, pac2),
+		concat(pac1, pac1, pac2),
+		concat(pac1, pac2, pac2),
+		concat(pac3, pac3, pac3),
+		make([]byte, PacketSize),
+		plain[:BlockSize],
+		append(append([]byte(nil), plain...), 0),
+		nil,
+	}
+
+	for _, capture := range loadGoldenCaptures(t) {
+		packet, err := capture.PacketBytes()
+		if err != nil {
+			continue
+		}
+		if decrypted, err := DecryptPacket(packet); err == nil {
+			seeds = append(seeds, decrypted)
+		}
+	}
+	return seeds
+}
+
+func concat(blocks ...[]byte) []byte {
+	var data []byte
+	for _, block := range blocks {
+		data = append(data, block...)
+	}
+	return data
+}
+
+func FuzzReorderBlocks(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reordered, err := ReorderBlocks(data)
+		if err != nil {
+			return
+		}
+
+		if len(reordered) != PacketSize {
+			t.Fatalf("reordered packet is %d bytes", len(reordered))
+		}
+		for i, prefix := range []string{Block1Prefix, Block2Prefix, Block3Prefix} {
+			if got := string(reordered[i*BlockSize : i*BlockSize+4]); got != prefix {
+				t.Fatalf("block %d is %q, want %q", i, got, prefix)
+			}
+		}
+
+		// The output must be made of the input blocks, each used once
+		used := make([]bool, NumBlocks)
+		for i := 0; i < NumBlocks; i++ {
+			out := reordered[i*BlockSize : (i+1)*BlockSize]
+			found := false
+			for j := 0; j < NumBlocks; j++ {
+				if !used[j] && bytes.Equal(out, data[j*BlockSize:(j+1)*BlockSize]) {
+					used[j] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Fatalf("output block %d is not an input block", i)
+			}
+		}
+	})
+}
+
+func FuzzParseReading(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reading, err := ParseReading(data)
+		if err != nil {
+			return
+		}
+
+		// Anything accepted must be a well-formed, checksummed packet
+		if len(data) != PacketSize {
+			t.Fatalf("accepted a %d byte packet", len(data))
+		}
+		if reading.TemperatureSign == 1 && reading.Temperature > 0 {
+			t.Fatalf("negative sign with temperature %v", reading.Temperature)
+		}
+		if _, err := reading.JSON(); err != nil {
+			t.Fatalf("JSON: %v", err)
+		}
+		_ = reading.String()
+	})
+}
+
+func FuzzDecryptPacket(f *testing.F) {
+	for _, capture := range loadGoldenCaptures(f) {
+		if packet, err := capture.PacketBytes(); err == nil {
+			f.Add(packet)
+		}
+	}
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(encryptPacket(f, padBlocks(seed)))
+	}
+
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		decrypted, err := DecryptPacket(packet)
+		if err != nil {
+			return
+		}
+		if len(decrypted) != PacketSize {
+			t.Fatalf("decrypted packet is %d bytes", len(decrypted))
+		}
+		_, _ = ParseReading(decrypted)
+	})
+}
+
+// padBlocks pads data to a whole number of AES blocks so it can be encrypted
+func padBlocks(data []byte) []byte {
+	if rem := len(data) % 16; rem != 0 {
+		data = append(append([]byte(nil), data...), make([]byte, 16-rem)...)
+	}
+	return data
+}
@@ -0,0 +1,82 @@
+package tc66c
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// goldenFiles lists the golden corpus
+func goldenFiles(t testing.TB) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.json"))
+	if err != nil {
+		t.Fatalf("listing golden files: %v", err)
+	}
+	return files
+}
+
+// loadGoldenCaptures reads every capture of the golden corpus
+func loadGoldenCaptures(t testing.TB) map[string]*PacketCapture {
+	t.Helper()
+
+	captures := make(map[string]*PacketCapture)
+	for _, path := range goldenFiles(t) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading %s: %v", path, err)
+		}
+
+		var capture PacketCapture
+		if err := json.Unmarshal(data, &capture); err != nil {
+			t.Fatalf("parsing %s: %v", path, err)
+		}
+		captures[filepath.Base(path)] = &capture
+	}
+	return captures
+}
+
+func TestGoldenPackets(t *testing.T) {
+	captures := loadGoldenCaptures(t)
+	if len(captures) == 0 {
+		t.Fatal("golden corpus is empty")
+	}
+	captured := 0
+	for _, capture := range captures {
+		if capture.Source == CaptureSourceMeter {
+			captured++
+		}
+	}
+	if captured == 0 {
+		t.Log("golden corpus has no real captures, only synthetic packets: see testdata/golden/README.md")
+	}
+
+	for name, capture := range captures {
+		t.Run(name, func(t *testing.T) {
+			if capture.Source != CaptureSourceMeter && capture.Source != CaptureSourceSynthetic {
+				t.Fatalf("unknown source %q", capture.Source)
+			}
+			if capture.Source == CaptureSourceSynthetic && !strings.HasPrefix(capture.Description, "Synthetic, not a capture") {
+				t.Errorf("synthetic packet described as %q, want it to start with \"Synthetic, not a capture\"", capture.Description)
+			}
+			if capture.Source == CaptureSourceMeter && capture.CapturedAt.IsZero() {
+				t.Error("captured packet has no capture time")
+			}
+			if capture.Reading == nil {
+				t.Fatal("capture has no reading")
+			}
+
+			reading, err := capture.Decode()
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(reading, capture.Reading) {
+				t.Errorf("decoded %+v\nwant    %+v", reading, capture.Reading)
+			}
+		})
+	}
+}
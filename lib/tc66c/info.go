@@ -0,0 +1,153 @@
+package tc66c
+
+import (
+	"fmt"
+	"strings"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// USB identity the TC66C enumerates with. It is the STM32 virtual COM port,
+// so other STM32 based devices may share it
+const (
+	TC66CUSBVID = "0483"
+	TC66CUSBPID = "5740"
+)
+
+// IsTC66CUSB reports whether a USB VID and PID, as hex strings in any case,
+// are the ones a TC66C enumerates with
+func IsTC66CUSB(vid, pid string) bool {
+	return strings.EqualFold(vid, TC66CUSBVID) && strings.EqualFold(pid, TC66CUSBPID)
+}
+
+// USBInfo holds the USB identity of a serial port
+type USBInfo struct {
+	VID          string `json:"vid"`
+	PID          string `json:"pid"`
+	SerialNumber string `json:"serial_number,omitempty"`
+}
+
+// DeviceInfo describes a connected device
+type DeviceInfo struct {
+	Port            string   `json:"port"`
+	USB             *USBInfo `json:"usb,omitempty"`
+	Mode            string   `json:"mode"`                       // firmware, bootloader or unknown
+	QueryResponse   string   `json:"query_response"`             // Raw reply to the query command (TC66C)
+	Product         string   `json:"product,omitempty"`          // Only available in firmware mode
+	FirmwareVersion string   `json:"firmware_version,omitempty"` // Only available in firmware mode
+	SerialNumber    uint32   `json:"serial_number,omitempty"`    // Device serial number (firmware mode)
+	NumRuns         uint32   `json:"num_runs,omitempty"`         // Run count (firmware mode)
+	LatestVersion   string   `json:"latest_version,omitempty"`   // Newest firmware known to the toolkit
+	Outdated        bool     `json:"outdated"`                   // Firmware is older than LatestVersion
+	UnknownLayout   bool     `json:"unknown_layout"`             // Readings are decoded with FallbackLayout
+}
+
+// Info queries the device for its identity. In bootloader mode only the
+// port, USB identity and query response are available
+func (tc *TC66C) Info() (*DeviceInfo, error) {
+	info := &DeviceInfo{
+		Port: tc.portName,
+	}
+
+	if tc.portName != "" {
+		usb, err := LookupUSB(tc.portName)
+		if err == nil {
+			info.USB = usb
+		}
+	}
+
+	response, err := tc.Query()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device: %w", err)
+	}
+	info.QueryResponse = string(response)
+	info.Mode = tc.Mode.String()
+
+	if tc.Mode != ModeFirmware {
+		return info, nil
+	}
+
+	reading, err := tc.GetReading()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device identity: %w", err)
+	}
+
+	info.Product = reading.Product
+	info.FirmwareVersion = reading.Version
+	info.SerialNumber = reading.SerialNumber
+	info.NumRuns = reading.NumRuns
+	info.LatestVersion = LatestKnownVersion(reading.Product)
+	info.Outdated = info.LatestVersion != "" && CompareVersions(info.FirmwareVersion, info.LatestVersion) < 0
+	_, err = LookupLayout(reading.Product, reading.Version)
+	info.UnknownLayout = err != nil
+
+	return info, nil
+}
+
+// LookupUSB returns the USB identity of a serial port
+func LookupUSB(portName string) (*USBInfo, error) {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list serial ports: %w", err)
+	}
+
+	for _, port := range ports {
+		if port.Name != portName {
+			continue
+		}
+		if !port.IsUSB {
+			return nil, fmt.Errorf("port %s is not a USB device", portName)
+		}
+		return &USBInfo{
+			VID:          port.VID,
+			PID:          port.PID,
+			SerialNumber: port.SerialNumber,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("port %s not found", portName)
+}
+
+// LatestKnownVersion returns the newest known-good firmware version for a
+// product, or an empty string if none is known
+func LatestKnownVersion(product string) string {
+	latest := ""
+	for _, known := range KnownFirmwareImages {
+		if !strings.EqualFold(known.Product, product) {
+			continue
+		}
+		if latest == "" || CompareVersions(known.Version, latest) > 0 {
+			latest = known.Version
+		}
+	}
+	return latest
+}
+
+// String returns a formatted string representation of the device info
+func (di *DeviceInfo) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "Port: %s\n", di.Port)
+	if di.USB != nil {
+		fmt.Fprintf(&sb, "USB: %s:%s", di.USB.VID, di.USB.PID)
+		if di.USB.SerialNumber != "" {
+			fmt.Fprintf(&sb, " (serial %s)", di.USB.SerialNumber)
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "Mode: %s", di.Mode)
+	if di.QueryResponse != "" {
+		fmt.Fprintf(&sb, "\nQuery response: %q", di.QueryResponse)
+	}
+
+	if di.Product != "" {
+		fmt.Fprintf(&sb, "\nProduct: %s", di.Product)
+	}
+	if di.FirmwareVersion != "" {
+		fmt.Fprintf(&sb, "\nFirmware: %s\n", di.FirmwareVersion)
+		fmt.Fprintf(&sb, "Serial: %d\n", di.SerialNumber)
+		fmt.Fprintf(&sb, "Runs: %d", di.NumRuns)
+	}
+
+	return sb.String()
+}
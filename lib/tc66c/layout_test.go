@@ -0,0 +1,181 @@
+package tc66c
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestKnownLayoutsValid(t *testing.T) {
+	seen := make(map[string]string)
+	for _, layout := range append(slices.Clone(KnownLayouts), FallbackLayout) {
+		if err := layout.validate(); err != nil {
+			t.Error(err)
+		}
+		if len(layout.Versions) == 0 {
+			t.Errorf("layout %s has no versions", layout.Name())
+		}
+	}
+	for _, layout := range KnownLayouts {
+		for _, version := range layout.Versions {
+			key := layout.Product + " " + version
+			if other, ok := seen[key]; ok {
+				t.Errorf("%s is in layouts %s and %s", key, other, layout.Name())
+			}
+			seen[key] = layout.Name()
+		}
+	}
+}
+
+// TestKnownLayoutsHaveGoldenPackets keeps the layout table backed by the
+// golden corpus: every known version needs a packet of that version
+func TestKnownLayoutsHaveGoldenPackets(t *testing.T) {
+	captures := loadGoldenCaptures(t)
+	for _, layout := range KnownLayouts {
+		for _, version := range layout.Versions {
+			found := false
+			for _, capture := range captures {
+				if capture.Reading != nil && layout.Matches(capture.Reading.Product, capture.Reading.Version) &&
+					CompareVersions(capture.Reading.Version, version) == 0 {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s %s is in KnownLayouts without a packet in testdata/golden", layout.Product, version)
+			}
+		}
+	}
+}
+
+func TestLookupLayout(t *testing.T) {
+	if _, err := LookupLayout("TC66", "1.18"); err != nil {
+		t.Errorf("LookupLayout(TC66, 1.18): %v", err)
+	}
+	if _, err := LookupLayout("tc66", "1.18"); err != nil {
+		t.Errorf("product should match case insensitively: %v", err)
+	}
+
+	for _, tt := range []struct{ product, version string }{
+		{"TC66", "1.09"},
+		{"TC66", "1.14"},
+		{"TC66", "2.00"},
+		{"TC99", "1.18"},
+	} {
+		_, err := LookupLayout(tt.product, tt.version)
+		var layoutErr *LayoutError
+		if !errors.Is(err, ErrUnknownLayout) || !errors.As(err, &layoutErr) ||
+			layoutErr.Product != tt.product || layoutErr.Version != tt.version {
+			t.Errorf("LookupLayout(%s, %s) error = %v, want a LayoutError", tt.product, tt.version, err)
+		}
+	}
+}
+
+func TestParseReadingUnknownVersion(t *testing.T) {
+	future := testReading
+	future.Version = "9.99"
+	plain := buildPlainPacket(future)
+
+	reading, err := ParseReading(plain)
+	if err != nil {
+		t.Fatalf("ParseReading: %v", err)
+	}
+	assertReading(t, reading, &future)
+	if !reading.LayoutFallback {
+		t.Error("LayoutFallback not set for an unknown version")
+	}
+	if known, err := ParseReading(buildPlainPacket(testReading)); err != nil {
+		t.Errorf("ParseReading of a known version: %v", err)
+	} else if known.LayoutFallback {
+		t.Error("LayoutFallback set for a known version")
+	}
+
+	_, err = ParseReadingStrict(plain)
+	if !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("ParseReadingStrict error = %v, want ErrUnknownLayout", err)
+	}
+	if errors.Is(err, ErrBadPacket) {
+		t.Error("an unknown layout must not be retried as a bad packet")
+	}
+
+	if _, err := ParseReadingStrict(buildPlainPacket(testReading)); err != nil {
+		t.Errorf("ParseReadingStrict of a known version: %v", err)
+	}
+}
+
+func TestParseReadingVersionSpecificLayout(t *testing.T) {
+	// A firmware that sends tenths of a degree and has moved D- next to D+
+	tenths := withVersions(tc66Layout, "9.99")
+	tenths.Temperature.Scale = 0.1
+	tenths.DMinusVoltage.Offset = tc66Layout.DPlusVoltage.Offset + 4
+	if err := tenths.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := KnownLayouts
+	KnownLayouts = append([]Layout{tenths}, KnownLayouts...)
+	t.Cleanup(func() { KnownLayouts = saved })
+
+	future := testReading
+	future.Version = "9.99"
+	future.Temperature = 273
+	future.DMinusVoltage = 0.6 // D- is now read from where D+ was written
+	reading, err := ParseReadingStrict(buildPlainPacket(future))
+	if err != nil {
+		t.Fatalf("ParseReadingStrict: %v", err)
+	}
+	if math.Abs(reading.Temperature-27.3) > 1e-9 || math.Abs(reading.DMinusVoltage-0.6) > 1e-9 {
+		t.Errorf("Temperature = %v, D- = %v, want 27.3 and 0.6", reading.Temperature, reading.DMinusVoltage)
+	}
+
+	// Other versions keep their own layout
+	reading, err = ParseReadingStrict(buildPlainPacket(testReading))
+	if err != nil {
+		t.Fatalf("ParseReadingStrict: %v", err)
+	}
+	assertReading(t, reading, &testReading)
+}
+
+func TestParseReadingLayout(t *testing.T) {
+	sparse := Layout{Product: "TC66", Versions: []string{"0.1"}, Voltage: tc66Layout.Voltage}
+
+	reading, err := ParseReadingLayout(buildPlainPacket(testReading), &sparse)
+	if err != nil {
+		t.Fatalf("ParseReadingLayout: %v", err)
+	}
+	if reading.Voltage != testReading.Voltage || reading.Current != 0 || reading.SerialNumber != 0 {
+		t.Errorf("reading = %+v, want only the voltage", reading)
+	}
+	if reading.Product != "TC66" || reading.Version != "1.18" {
+		t.Errorf("product %q version %q, want them from the packet", reading.Product, reading.Version)
+	}
+
+	plain := buildPlainPacket(testReading)
+	plain[60] ^= 0xFF
+	if _, err := ParseReadingLayout(plain, &sparse); !errors.Is(err, ErrBadPacket) {
+		t.Errorf("error = %v, want ErrBadPacket", err)
+	}
+}
+
+func TestLayoutValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Layout)
+	}{
+		{"over the version", func(l *Layout) { l.SerialNumber.Offset = 8 }},
+		{"over a checksum", func(l *Layout) { l.Resistance.Offset = BlockSize + 58 }},
+		{"over a prefix", func(l *Layout) { l.Group0MAh.Offset = 2 * BlockSize }},
+		{"past the end", func(l *Layout) { l.NumRuns.Offset = PacketSize }},
+		{"no scale", func(l *Layout) { l.Voltage.Scale = 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := withVersions(tc66Layout, "1.18")
+			tt.modify(&layout)
+			if err := layout.validate(); err == nil {
+				t.Error("validate accepted the layout")
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package tc66c
+
+import (
+	"fmt"
+	"strings"
+
+	"go.bug.st/serial"
+)
+
+// Supported meter models. ModelUM stands for any UM-series meter, whose
+// exact model is told by the frames it sends
+const (
+	ModelTC66C = "TC66C"
+	ModelUM24C = "UM24C"
+	ModelUM25C = "UM25C"
+	ModelUM34C = "UM34C"
+	ModelUM    = "UM"
+)
+
+// Meter is a USB power meter of any supported model: a TC66C, or a UM24C,
+// UM25C or UM34C. Its methods are safe to call from any goroutine. Methods
+// a model does not have fail with ErrUnsupported
+type Meter interface {
+	ReadingSource
+
+	// Info identifies the meter
+	Info() (*DeviceInfo, error)
+
+	GetRecordings() ([]*RecordingEntry, error)
+	NextPage() error
+	PreviousPage() error
+	RotateScreen() error
+
+	Capabilities() Capabilities
+	Stats() Stats
+	PortName() string
+	Close() error
+}
+
+// Capabilities describes what a meter model can do
+type Capabilities struct {
+	Model          string `json:"model"`           // ModelTC66C or a UM-series model
+	Recordings     bool   `json:"recordings"`      // GetRecordings downloads stored readings
+	PreviousPage   bool   `json:"previous_page"`   // PreviousPage is available
+	DataGroups     int    `json:"data_groups"`     // Charge counter groups in a Reading
+	LoadTimer      bool   `json:"load_timer"`      // Readings include Reading.UM.LoadTimer
+	FirmwareUpdate bool   `json:"firmware_update"` // Firmware can be flashed over the serial port
+	RawCommands    bool   `json:"raw_commands"`    // RawCommand and Probe work
+}
+
+// Capabilities returns what the TC66C can do
+func (tc *TC66C) Capabilities() Capabilities {
+	return Capabilities{
+		Model:          ModelTC66C,
+		Recordings:     true,
+		PreviousPage:   true,
+		DataGroups:     2,
+		FirmwareUpdate: true,
+		RawCommands:    true,
+	}
+}
+
+// ParseModel returns the model named by s, case insensitively. TC66 is
+// accepted for ModelTC66C
+func ParseModel(s string) (string, error) {
+	model := strings.ToUpper(strings.TrimSpace(s))
+	switch model {
+	case "TC66":
+		return ModelTC66C, nil
+	case ModelTC66C, ModelUM24C, ModelUM25C, ModelUM34C, ModelUM:
+		return model, nil
+	}
+	return "", fmt.Errorf("unknown meter model %q (expected tc66c, um24c, um25c, um34c or um)", s)
+}
+
+// IsUMModel reports whether model is ModelUM or a UM-series model
+func IsUMModel(model string) bool {
+	return strings.HasPrefix(model, ModelUM)
+}
+
+// OpenMeter opens the meter of the given model on portName. See ParseModel
+// for the model names
+func OpenMeter(portName, model string) (Meter, error) {
+	port, err := OpenMeterPort(portName, model)
+	if err != nil {
+		return nil, err
+	}
+
+	meter, err := NewMeterFromNamedPort(portName, port, model)
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+	return meter, nil
+}
+
+// OpenMeterPort opens a serial port with the settings used by the model
+func OpenMeterPort(portName, model string) (serial.Port, error) {
+	model, err := ParseModel(model)
+	if err != nil {
+		return nil, err
+	}
+	if IsUMModel(model) {
+		return OpenUMPort(portName)
+	}
+	return OpenPort(portName)
+}
+
+// NewMeterFromNamedPort creates a meter of the given model on an already
+// open port, as NewTC66CFromNamedPort and NewUMMeterFromNamedPort do. A
+// UM-series meter that turns out to be another model than the one asked
+// for is an error. The caller keeps ownership of the port if an error is
+// returned
+func NewMeterFromNamedPort(portName string, port serial.Port, model string) (Meter, error) {
+	model, err := ParseModel(model)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsUMModel(model) {
+		tc, err := NewTC66CFromNamedPort(portName, port)
+		if err != nil {
+			return nil, err
+		}
+		return tc, nil
+	}
+
+	um, err := NewUMMeterFromNamedPort(portName, port)
+	if err != nil {
+		return nil, err
+	}
+	if model != ModelUM && um.Model != model {
+		um.detach()
+		return nil, fmt.Errorf("found a %s, not a %s", um.Model, model)
+	}
+	return um, nil
+}
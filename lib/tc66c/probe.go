@@ -0,0 +1,151 @@
+package tc66c
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KnownCommands are the documented commands, which probing has nothing to
+// learn from
+var KnownCommands = []string{CmdQuery, CmdGetVA, CmdGetRec, CmdLastP, CmdNextP, CmdRotat, CmdUpdate}
+
+// ProbeBlocklist holds substrings of commands that look like they change
+// settings or clear data. Probe skips them unless they are allowed
+var ProbeBlocklist = []string{
+	"clr", "clear", "cls", "del", "rst", "reset", "zero", "set", "save",
+	"store", "def", "init", "off", "pwr", "sleep", "lock",
+}
+
+// ProbeHardBlocklist holds substrings of commands that look like they could
+// reflash, recalibrate or brick the meter. Probe always skips them
+var ProbeHardBlocklist = []string{
+	"upd", "upg", "boot", "bl", "dfu", "flash", "fls", "burn", "prog", "wr",
+	"era", "wipe", "fmt", "form", "fact", "cal", "key", "aes",
+}
+
+// ProbeBlocked returns the blocklist pattern cmd contains, or an empty
+// string if cmd may be probed. Patterns of ProbeBlocklist listed in allow
+// are lifted, those of ProbeHardBlocklist never are
+func ProbeBlocked(cmd string, allow []string) string {
+	lower := strings.ToLower(cmd)
+	for _, pattern := range ProbeHardBlocklist {
+		if strings.Contains(lower, pattern) {
+			return pattern
+		}
+	}
+	for _, pattern := range ProbeBlocklist {
+		if strings.Contains(lower, pattern) && !slices.Contains(allow, pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// PacBlock is a part of a response that decrypts to a block starting with
+// "pac", like the blocks of a getva packet
+type PacBlock struct {
+	Offset   int    `json:"offset"`    // Offset in the response
+	Prefix   string `json:"prefix"`    // e.g. "pac1"
+	ValidCRC bool   `json:"valid_crc"` // Only checked for whole 64-byte blocks
+}
+
+// FindPacBlocks decrypts data with the meter's AES key, one 16-byte AES
+// block at a time, and returns every block starting with "pac"
+func FindPacBlocks(data []byte) []PacBlock {
+	cipher, err := aes.NewCipher(AESKey)
+	if err != nil {
+		return nil
+	}
+
+	n := len(data) / aes.BlockSize * aes.BlockSize
+	plain := make([]byte, n)
+	for i := 0; i < n; i += aes.BlockSize {
+		cipher.Decrypt(plain[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
+	}
+
+	var blocks []PacBlock
+	for offset := 0; offset < n; offset += aes.BlockSize {
+		if string(plain[offset:offset+3]) != "pac" {
+			continue
+		}
+
+		block := PacBlock{Offset: offset, Prefix: string(plain[offset : offset+4])}
+		if offset+BlockSize <= n {
+			pac := plain[offset : offset+BlockSize]
+			block.ValidCRC = VerifyChecksum(pac[0:60], binary.LittleEndian.Uint16(pac[60:62]))
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// ProbeResult describes how the device answered a probed command
+type ProbeResult struct {
+	Command   string     `json:"command"`
+	Blocked   string     `json:"blocked,omitempty"`  // Blocklist pattern that kept the command from being sent
+	Length    int        `json:"length"`             // Bytes answered, 0 if none
+	Response  string     `json:"response,omitempty"` // Hex encoded response
+	Text      bool       `json:"text,omitempty"`     // The response is printable ASCII
+	PacBlocks []PacBlock `json:"pac_blocks,omitempty"`
+	ModeAfter string     `json:"mode_after,omitempty"` // Device mode reported by query after the command
+	Error     string     `json:"error,omitempty"`
+}
+
+// Probe sends cmd once to map an undocumented command and describes what
+// the device answers, reading until it has been quiet for timeout. Commands
+// ProbeBlocked reports are not sent. Afterwards the device mode is queried
+// to make sure the command left the device working. An error means the
+// device disconnected, stopped answering or left firmware mode, and
+// probing should stop
+func (tc *TC66C) Probe(cmd string, timeout time.Duration, allow []string) (*ProbeResult, error) {
+	result := &ProbeResult{Command: cmd}
+	if pattern := ProbeBlocked(cmd, allow); pattern != "" {
+		result.Blocked = pattern
+		return result, nil
+	}
+	if tc.Mode != ModeFirmware {
+		return nil, &ModeError{Want: ModeFirmware, Got: tc.Mode}
+	}
+
+	response, err := tc.RawCommand(cmd, 0, timeout)
+	result.Length = len(response)
+	if len(response) > 0 {
+		result.Response = hex.EncodeToString(response)
+		result.Text = isText(response)
+		result.PacBlocks = FindPacBlocks(response)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
+	mode, err := tc.queryDeviceMode()
+	if err != nil {
+		result.ModeAfter = ModeUnknown.String()
+		result.Error = err.Error()
+		return result, fmt.Errorf("device stopped answering query after %q: %w", cmd, err)
+	}
+	result.ModeAfter = mode.String()
+	if mode != ModeFirmware {
+		err := &ModeError{Want: ModeFirmware, Got: mode}
+		result.Error = err.Error()
+		return result, fmt.Errorf("device left firmware mode after %q: %w", cmd, err)
+	}
+
+	return result, nil
+}
+
+// isText reports whether data is printable ASCII, allowing line breaks
+func isText(data []byte) bool {
+	for _, b := range data {
+		if (b < 0x20 || b > 0x7e) && b != '\r' && b != '\n' && b != '\t' {
+			return false
+		}
+	}
+	return true
+}
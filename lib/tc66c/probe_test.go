@@ -0,0 +1,152 @@
+package tc66c
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProbeBlocked(t *testing.T) {
+	tests := []struct {
+		cmd   string
+		allow []string
+		want  string
+	}{
+		{cmd: "getst", want: ""},
+		{cmd: "clrgp", want: "clr"},
+		{cmd: "CLRGP", want: "clr"},
+		{cmd: "clrgp", allow: []string{"clr"}, want: ""},
+		{cmd: "reset", want: "reset"},
+		{cmd: "setth", want: "set"},
+		{cmd: "updat", want: "upd"},
+		{cmd: "updat", allow: []string{"upd"}, want: "upd"},
+		{cmd: "calib", allow: []string{"cal"}, want: "cal"},
+	}
+
+	for _, tt := range tests {
+		if got := ProbeBlocked(tt.cmd, tt.allow); got != tt.want {
+			t.Errorf("ProbeBlocked(%q, %v) = %q, want %q", tt.cmd, tt.allow, got, tt.want)
+		}
+	}
+}
+
+func TestProbeBlocklistCoversKnownDestructiveCommands(t *testing.T) {
+	if ProbeBlocked(CmdUpdate, nil) == "" {
+		t.Errorf("%q is not blocked", CmdUpdate)
+	}
+	for _, cmd := range KnownCommands {
+		if cmd != CmdUpdate && ProbeBlocked(cmd, nil) != "" {
+			t.Errorf("known command %q is blocked by %q", cmd, ProbeBlocked(cmd, nil))
+		}
+	}
+}
+
+func TestFindPacBlocks(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	corrupted := buildPlainPacket(testReading)
+	corrupted[BlockSize+60] ^= 0xFF
+
+	tests := []struct {
+		name string
+		data []byte
+		want []PacBlock
+	}{
+		{name: "packet", data: packet, want: []PacBlock{
+			{Offset: 0, Prefix: Block1Prefix, ValidCRC: true},
+			{Offset: 64, Prefix: Block2Prefix, ValidCRC: true},
+			{Offset: 128, Prefix: Block3Prefix, ValidCRC: true},
+		}},
+		{name: "bad checksum", data: encryptPacket(t, corrupted)[:BlockSize*2], want: []PacBlock{
+			{Offset: 0, Prefix: Block1Prefix, ValidCRC: true},
+			{Offset: 64, Prefix: Block2Prefix, ValidCRC: false},
+		}},
+		{name: "partial block", data: packet[128:160], want: []PacBlock{
+			{Offset: 0, Prefix: Block3Prefix, ValidCRC: false},
+		}},
+		{name: "text", data: []byte("firm"), want: nil},
+		{name: "not encrypted", data: buildPlainPacket(testReading), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindPacBlocks(tt.data); !slices.Equal(got, tt.want) {
+				t.Errorf("FindPacBlocks = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tc, fp := newTestDevice(t, "firm",
+		cmdStep("hello", []byte("hi\r\n")),
+		cmdStep(CmdQuery, []byte("firm")),
+		cmdStep("getxx", packet[:100], packet[100:]),
+		cmdStep(CmdQuery, []byte("firm")),
+		cmdStep("nothn"),
+		cmdStep(CmdQuery, []byte("firm")),
+	)
+
+	result, err := tc.Probe("hello", time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if result.Length != 4 || !result.Text || result.Response != "68690d0a" || result.ModeAfter != "firmware" {
+		t.Errorf("text result = %+v", result)
+	}
+
+	result, err = tc.Probe("getxx", time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if result.Length != PacketSize || result.Text || len(result.PacBlocks) != NumBlocks {
+		t.Errorf("packet result = %+v", result)
+	}
+
+	result, err = tc.Probe("nothn", time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if result.Length != 0 || result.Response != "" {
+		t.Errorf("silent result = %+v", result)
+	}
+
+	// Blocked commands are never written to the port
+	result, err = tc.Probe("clrgp", time.Millisecond, nil)
+	if err != nil || result.Blocked != "clr" {
+		t.Errorf("blocked result = %+v, %v", result, err)
+	}
+	fp.done()
+}
+
+func TestProbeStopsWhenModeChanges(t *testing.T) {
+	tc, fp := newTestDevice(t, "firm",
+		cmdStep("gotob"),
+		cmdStep(CmdQuery, []byte("boot")),
+	)
+
+	result, err := tc.Probe("gotob", time.Millisecond, nil)
+	fp.done()
+	if !errors.Is(err, ErrWrongMode) {
+		t.Fatalf("error = %v, want ErrWrongMode", err)
+	}
+	if result == nil || result.ModeAfter != "bootloader" {
+		t.Errorf("result = %+v, want the mode after the command", result)
+	}
+}
+
+func TestProbeStopsWhenQueryFails(t *testing.T) {
+	tc, fp := newTestDevice(t, "firm",
+		cmdStep("hangs"),
+		cmdStep(CmdQuery),
+	)
+
+	_, err := tc.Probe("hangs", time.Millisecond, nil)
+	fp.done()
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("error = %v, want ErrTimeout", err)
+	}
+}
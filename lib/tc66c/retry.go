@@ -0,0 +1,152 @@
+package tc66c
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how GetReading recovers from lost or corrupted
+// readings
+type RetryPolicy struct {
+	Attempts int           // getva commands sent per reading, at least 1
+	Backoff  time.Duration // Wait before each retry
+	Resync   bool          // Look for the packet at shifted offsets before retrying
+}
+
+// DefaultRetryPolicy returns the policy used by new devices
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts: 3,
+		Backoff:  50 * time.Millisecond,
+		Resync:   true,
+	}
+}
+
+// Stats counts reading problems since the device was opened
+type Stats struct {
+	Readings   uint64 `json:"readings"`    // Readings returned
+	CRCErrors  uint64 `json:"crc_errors"`  // Packets that failed to decode
+	ShortReads uint64 `json:"short_reads"` // Responses cut short by a timeout
+	Resyncs    uint64 `json:"resyncs"`     // Packets recovered at a shifted offset
+	Retries    uint64 `json:"retries"`     // getva commands sent again
+	Failures   uint64 `json:"failures"`    // Readings given up on
+}
+
+// String returns the counters in a human readable form
+func (s Stats) String() string {
+	return fmt.Sprintf("%d readings, %d CRC errors, %d short reads, %d resyncs, %d retries, %d failures",
+		s.Readings, s.CRCErrors, s.ShortReads, s.Resyncs, s.Retries, s.Failures)
+}
+
+// statsCounter holds the Stats of a device, safe for concurrent use
+type statsCounter struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+// get returns the current counters
+func (c *statsCounter) get() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// update changes the counters
+func (c *statsCounter) update(update func(*Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.stats)
+}
+
+// Stats returns the reading counters of this session
+func (tc *TC66C) Stats() Stats {
+	return tc.stats.get()
+}
+
+// updateStats changes the reading counters
+func (tc *TC66C) updateStats(update func(*Stats)) {
+	tc.stats.update(update)
+}
+
+// GetReading sends the 'getva' command and returns a parsed Reading. Lost
+// and corrupted packets are recovered or retried according to tc.Retry.
+// Each attempt is queued separately, so other commands can run during the
+// backoff
+func (tc *TC66C) GetReading() (*Reading, error) {
+	return retryReading(tc.Retry, &tc.stats, func() (*Reading, error) {
+		return schedule(tc.sched, priorityNormal, tc.readPacket)
+	})
+}
+
+// retryReading takes a reading with attempt, trying again according to
+// policy while it fails with a lost or corrupted reading, and counts the
+// outcome in stats
+func retryReading(policy RetryPolicy, stats *statsCounter, attempt func() (*Reading, error)) (*Reading, error) {
+	attempts := max(policy.Attempts, 1)
+
+	var err error
+	for n := 1; n <= attempts; n++ {
+		if n > 1 {
+			stats.update(func(s *Stats) { s.Retries++ })
+			time.Sleep(policy.Backoff)
+		}
+
+		var reading *Reading
+		reading, err = attempt()
+		if err == nil {
+			stats.update(func(s *Stats) { s.Readings++ })
+			return reading, nil
+		}
+
+		// Only lost and corrupted packets are worth another try
+		if !errors.Is(err, ErrTimeout) && !errors.Is(err, ErrBadPacket) {
+			break
+		}
+	}
+
+	stats.update(func(s *Stats) { s.Failures++ })
+	return nil, err
+}
+
+// readPacket sends a single 'getva' command and decodes the response,
+// resynchronising on it if it was shifted by stray bytes. Runs on the
+// device goroutine
+func (tc *TC66C) readPacket() (*Reading, error) {
+	encrypted, err := tc.getRawReading()
+	if errors.Is(err, ErrTimeout) {
+		tc.updateStats(func(s *Stats) { s.ShortReads++ })
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reading, err := decodePacket(encrypted)
+	if err == nil {
+		return reading, nil
+	}
+	tc.updateStats(func(s *Stats) { s.CRCErrors++ })
+
+	if tc.Retry.Resync {
+		if reading, ok := tc.resync(encrypted); ok {
+			tc.updateStats(func(s *Stats) { s.Resyncs++ })
+			return reading, nil
+		}
+	}
+	return nil, err
+}
+
+// resync looks for a whole packet further into the stream, for when stray
+// bytes before the response shifted it. The bytes of the response still
+// in flight are read first
+func (tc *TC66C) resync(packet []byte) (*Reading, bool) {
+	data := append(append([]byte(nil), packet...), tc.drain(50*time.Millisecond)...)
+
+	for offset := 1; offset+PacketSize <= len(data); offset++ {
+		if reading, err := decodePacket(data[offset : offset+PacketSize]); err == nil {
+			return reading, true
+		}
+	}
+	return nil, false
+}
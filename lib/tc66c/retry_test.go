@@ -0,0 +1,136 @@
+package tc66c
+
+import (
+	"errors"
+	"testing"
+)
+
+// noRetry makes GetReading send a single getva, for tests of one exchange
+var noRetry = RetryPolicy{Attempts: 1}
+
+// testRetry is the default policy without the backoff
+var testRetry = RetryPolicy{Attempts: 3, Resync: true}
+
+func TestGetReadingRetry(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+	corrupted := buildPlainPacket(testReading)
+	corrupted[BlockSize+10] ^= 0x01
+
+	tc, fp := newTestDevice(t, "firm",
+		cmdStep(CmdGetVA, packet[:50]),
+		cmdStep(CmdGetVA, encryptPacket(t, corrupted)),
+		cmdStep(CmdGetVA, packet),
+	)
+	tc.Retry = testRetry
+
+	reading, err := tc.GetReading()
+	fp.done()
+	if err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+	assertReading(t, reading, &testReading)
+
+	want := Stats{Readings: 1, ShortReads: 1, CRCErrors: 1, Retries: 2}
+	if got := tc.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetReadingGivesUp(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tc, fp := newTestDevice(t, "firm",
+		cmdStep(CmdGetVA),
+		cmdStep(CmdGetVA, packet[:10]),
+		cmdStep(CmdGetVA, packet[:100]),
+	)
+	tc.Retry = testRetry
+
+	_, err := tc.GetReading()
+	fp.done()
+
+	// The error of the last attempt is returned
+	var short *ShortReadError
+	if !errors.As(err, &short) || short.Got != 100 {
+		t.Fatalf("error = %v, want the last short read", err)
+	}
+
+	want := Stats{ShortReads: 3, Retries: 2, Failures: 1}
+	if got := tc.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetReadingResync(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tests := []struct {
+		name  string
+		reply [][]byte
+	}{
+		{name: "stray bytes first", reply: [][]byte{append([]byte("\r\nOK"), packet...)}},
+		{name: "tail still arriving", reply: [][]byte{append([]byte{0xff, 0x00, 0x13}, packet[:150]...), packet[150:]}},
+		{name: "one stray byte", reply: [][]byte{{0x00}, packet}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, fp := newTestDevice(t, "firm", cmdStep(CmdGetVA, tt.reply...))
+			tc.Retry = testRetry
+
+			reading, err := tc.GetReading()
+			fp.done()
+			if err != nil {
+				t.Fatalf("GetReading: %v", err)
+			}
+			assertReading(t, reading, &testReading)
+
+			want := Stats{Readings: 1, CRCErrors: 1, Resyncs: 1}
+			if got := tc.Stats(); got != want {
+				t.Errorf("Stats = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestGetReadingResyncDisabled(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tc, fp := newTestDevice(t, "firm",
+		cmdStep(CmdGetVA, append([]byte("xx"), packet...)),
+		cmdStep(CmdGetVA, packet),
+	)
+	tc.Retry = RetryPolicy{Attempts: 2}
+
+	if _, err := tc.GetReading(); err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+	fp.done()
+
+	want := Stats{Readings: 1, CRCErrors: 1, Retries: 1}
+	if got := tc.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetReadingNotRetried(t *testing.T) {
+	boot, fp := newTestDevice(t, "boot")
+	boot.Retry = testRetry
+	if _, err := boot.GetReading(); !errors.Is(err, ErrWrongMode) {
+		t.Fatalf("error = %v, want ErrWrongMode", err)
+	}
+	fp.done()
+
+	firm, fp := newTestDevice(t, "firm")
+	firm.Retry = testRetry
+	fp.Close()
+	if _, err := firm.GetReading(); !errors.Is(err, ErrDisconnected) {
+		t.Fatalf("error = %v, want ErrDisconnected", err)
+	}
+
+	for _, tc := range []*TC66C{boot, firm} {
+		if got := tc.Stats(); got.Retries != 0 || got.Failures != 1 {
+			t.Errorf("Stats = %+v, want no retries and one failure", got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package tc66c
+
+import (
+	"slices"
+	"sync"
+)
+
+// priority orders the commands waiting for a device. Higher priorities run
+// first, and commands of the same priority run in the order they were
+// queued. A command already talking to the device is never interrupted
+type priority int
+
+const (
+	priorityBulk   priority = iota // Recording downloads and firmware updates
+	priorityNormal                 // Readings and mode queries
+	priorityUser                   // Screen commands, sent when a user presses a button
+)
+
+// job is a command waiting for its turn on the device
+type job struct {
+	priority priority
+	fn       func()
+	err      error // Set instead of running fn if the device is closed
+	done     chan struct{}
+}
+
+// scheduler runs the commands of one device, one at a time, on its own
+// goroutine
+type scheduler struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []*job
+	closed bool
+	done   chan struct{}
+}
+
+// newScheduler starts the goroutine of a device
+func newScheduler() *scheduler {
+	s := &scheduler{done: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	go s.loop()
+	return s
+}
+
+// loop runs queued jobs until the scheduler is stopped
+func (s *scheduler) loop() {
+	defer close(s.done)
+
+	for {
+		j := s.next()
+		if j == nil {
+			return
+		}
+		j.fn()
+		close(j.done)
+	}
+}
+
+// next waits for a job and takes the oldest one of the highest priority.
+// It returns nil once the scheduler is stopped
+func (s *scheduler) next() *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return nil
+	}
+
+	best := 0
+	for i, j := range s.queue {
+		if j.priority > s.queue[best].priority {
+			best = i
+		}
+	}
+	j := s.queue[best]
+	s.queue = slices.Delete(s.queue, best, best+1)
+	return j
+}
+
+// do queues fn and waits until the scheduler has run it. It returns
+// ErrClosed without running fn if the device is closed first
+func (s *scheduler) do(p priority, fn func()) error {
+	j := &job{priority: p, fn: fn, done: make(chan struct{})}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.queue = append(s.queue, j)
+	s.cond.Signal()
+	s.mu.Unlock()
+
+	<-j.done
+	return j.err
+}
+
+// stop fails every queued job with ErrClosed and lets the goroutine exit
+// once the running job, if any, returns
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, j := range s.queue {
+		j.err = ErrClosed
+		close(j.done)
+	}
+	s.queue = nil
+	s.cond.Broadcast()
+}
+
+// wait blocks until the goroutine of a stopped scheduler has exited
+func (s *scheduler) wait() {
+	<-s.done
+}
+
+// schedule runs fn on the goroutine of s at priority p and returns its
+// result
+func schedule[T any](s *scheduler, p priority, fn func() (T, error)) (T, error) {
+	var result T
+	var err error
+	if serr := s.do(p, func() { result, err = fn() }); serr != nil {
+		return result, serr
+	}
+	return result, err
+}
+
+// run runs fn on the goroutine of s at priority p
+func (s *scheduler) run(p priority, fn func() error) error {
+	var err error
+	if serr := s.do(p, func() { err = fn() }); serr != nil {
+		return serr
+	}
+	return err
+}
@@ -0,0 +1,171 @@
+package tc66c
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockScheduler occupies the scheduler with a job that runs until the
+// returned function is called
+func blockScheduler(t *testing.T, s *scheduler) func() {
+	t.Helper()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go s.do(priorityNormal, func() {
+		close(started)
+		<-release
+	})
+	<-started
+	return func() { close(release) }
+}
+
+// waitQueued waits until n jobs are queued behind the running one
+func waitQueued(t *testing.T, s *scheduler, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		queued := len(s.queue)
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d queued jobs", n)
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := newScheduler()
+	defer func() {
+		s.stop()
+		s.wait()
+	}()
+
+	release := blockScheduler(t, s)
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	queue := func(p priority, name string) {
+		wg.Go(func() {
+			s.do(p, func() {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+			})
+		})
+	}
+
+	// Queue one at a time so jobs of the same priority have a known order
+	jobs := []struct {
+		p    priority
+		name string
+	}{
+		{priorityBulk, "gtrec"},
+		{priorityNormal, "getva 1"},
+		{priorityUser, "rotat"},
+		{priorityNormal, "getva 2"},
+		{priorityUser, "nextp"},
+	}
+	for i, j := range jobs {
+		queue(j.p, j.name)
+		waitQueued(t, s, i+1)
+	}
+
+	release()
+	wg.Wait()
+
+	want := []string{"rotat", "nextp", "getva 1", "getva 2", "gtrec"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %q, want %q", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %q, want %q", order, want)
+		}
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	s := newScheduler()
+	release := blockScheduler(t, s)
+
+	ran := false
+	result := make(chan error, 1)
+	go func() {
+		result <- s.do(priorityUser, func() { ran = true })
+	}()
+	waitQueued(t, s, 1)
+
+	s.stop()
+	err := <-result
+	if !errors.Is(err, ErrClosed) || !errors.Is(err, ErrDisconnected) {
+		t.Errorf("queued job error = %v, want ErrClosed", err)
+	}
+
+	release()
+	s.wait()
+	if ran {
+		t.Error("queued job ran after stop")
+	}
+
+	if err := s.do(priorityNormal, func() { ran = true }); !errors.Is(err, ErrClosed) {
+		t.Errorf("job after stop: error = %v, want ErrClosed", err)
+	}
+}
+
+func TestConcurrentReadings(t *testing.T) {
+	const readers = 8
+	const readingsEach = 5
+
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+	script := make([]fakeStep, readers*readingsEach)
+	for i := range script {
+		script[i] = cmdStep(CmdGetVA, split(packet, 32)...)
+	}
+	tc, fp := newTestDevice(t, "firm", script...)
+
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Go(func() {
+			for range readingsEach {
+				reading, err := tc.GetReading()
+				if err != nil {
+					t.Errorf("GetReading: %v", err)
+					return
+				}
+				assertReading(t, reading, &testReading)
+				tc.Stats()
+			}
+		})
+	}
+	wg.Wait()
+	fp.done()
+
+	if got := tc.Stats().Readings; got != readers*readingsEach {
+		t.Errorf("Stats().Readings = %d, want %d", got, readers*readingsEach)
+	}
+}
+
+func TestCloseFailsLaterCommands(t *testing.T) {
+	tc, _ := newTestDevice(t, "firm")
+
+	if err := tc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := tc.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	if _, err := tc.GetReading(); !errors.Is(err, ErrClosed) {
+		t.Errorf("GetReading after Close: error = %v, want ErrClosed", err)
+	}
+	if err := tc.RotateScreen(); !errors.Is(err, ErrClosed) {
+		t.Errorf("RotateScreen after Close: error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,177 @@
+package tc66c
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ReadingSource is anything readings can be taken from, such as a TC66C
+type ReadingSource interface {
+	GetReading() (*Reading, error)
+}
+
+// Sample is a reading delivered by Stream
+type Sample struct {
+	Time    time.Time // When the reading arrived
+	Reading *Reading
+	Latency time.Duration // How long the reading took
+}
+
+// OverrunPolicy decides what Stream does when a reading, or a consumer
+// that blocks it, makes it miss the time of the next one
+type OverrunPolicy int
+
+const (
+	// OverrunSkip drops the missed readings and carries on at the next
+	// reading time still ahead
+	OverrunSkip OverrunPolicy = iota
+
+	// OverrunQueue takes the missed readings back to back until the stream
+	// is back on schedule
+	OverrunQueue
+)
+
+// BackpressurePolicy decides what Stream does when the consumer has not
+// taken the previous samples yet
+type BackpressurePolicy int
+
+const (
+	// BackpressureDropOldest replaces the oldest unread sample, so the
+	// consumer always gets the latest readings
+	BackpressureDropOldest BackpressurePolicy = iota
+
+	// BackpressureBlock waits for the consumer, delaying the next reading.
+	// Combine it with OverrunQueue to never lose a reading
+	BackpressureBlock
+)
+
+// StreamOptions configures Stream. The zero value takes readings back to
+// back, skipping on overrun and dropping the oldest unread sample
+type StreamOptions struct {
+	// Interval between the starts of two readings. Zero takes them back to
+	// back
+	Interval time.Duration
+
+	// IntervalFunc, if set, is called after every reading to get the
+	// interval to the next one instead of using Interval
+	IntervalFunc func() time.Duration
+
+	// Wake takes a reading right away whenever it receives, e.g. after the
+	// interval got shorter. The schedule restarts from that reading
+	Wake <-chan struct{}
+
+	// Buffer is how many samples and errors are held for a slow consumer,
+	// at least 1
+	Buffer int
+
+	Overrun      OverrunPolicy
+	Backpressure BackpressurePolicy
+
+	// StopOnError decides which errors end the stream. By default it ends
+	// on ErrDisconnected and ErrWrongMode, which a TC66C does not recover
+	// from by itself
+	StopOnError func(error) bool
+}
+
+// Stream takes readings from source on a fixed schedule until ctx is
+// cancelled. Readings are delivered on the first channel and failed
+// readings on the second, so read both until they are closed. The stream
+// keeps going after an error unless opts.StopOnError says otherwise, in
+// which case that error is the last one sent. A reading already in
+// progress when ctx is cancelled is finished and discarded
+func Stream(ctx context.Context, source ReadingSource, opts StreamOptions) (<-chan Sample, <-chan error) {
+	buffer := max(opts.Buffer, 1)
+	samples := make(chan Sample, buffer)
+	errs := make(chan error, buffer)
+
+	if opts.StopOnError == nil {
+		opts.StopOnError = stopOnError
+	}
+
+	go stream(ctx, source, opts, samples, errs)
+
+	return samples, errs
+}
+
+// stopOnError is the default StreamOptions.StopOnError
+func stopOnError(err error) bool {
+	return errors.Is(err, ErrDisconnected) || errors.Is(err, ErrWrongMode)
+}
+
+// stream is the acquisition loop of Stream
+func stream(ctx context.Context, source ReadingSource, opts StreamOptions, samples chan Sample, errs chan error) {
+	defer close(samples)
+	defer close(errs)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	// Readings are due at fixed times rather than an interval after the
+	// previous one, so the time readings take does not add up
+	next := time.Now()
+	for {
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return
+		case <-opts.Wake:
+			next = time.Now()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		reading, err := source.GetReading()
+		received := time.Now()
+
+		if err != nil {
+			if !deliver(ctx, errs, err, opts.Backpressure) || opts.StopOnError(err) {
+				return
+			}
+		} else {
+			sample := Sample{Time: received, Reading: reading, Latency: received.Sub(start)}
+			if !deliver(ctx, samples, sample, opts.Backpressure) {
+				return
+			}
+		}
+
+		interval := opts.Interval
+		if opts.IntervalFunc != nil {
+			interval = opts.IntervalFunc()
+		}
+		next = next.Add(interval)
+
+		if now := time.Now(); opts.Overrun == OverrunSkip && interval > 0 && next.Before(now) {
+			missed := now.Sub(next)/interval + 1
+			next = next.Add(missed * interval)
+		}
+	}
+}
+
+// deliver sends v to the consumer according to policy. It returns false
+// if ctx was cancelled while blocked
+func deliver[T any](ctx context.Context, ch chan T, v T, policy BackpressurePolicy) bool {
+	if policy == BackpressureBlock {
+		select {
+		case ch <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		select {
+		case ch <- v:
+			return true
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package tc66c
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource is a ReadingSource whose readings are numbered in NumRuns. It
+// records when each reading started and takes delay(n) to answer reading n
+type fakeSource struct {
+	mu     sync.Mutex
+	starts []time.Time
+	delay  func(n int) time.Duration
+	err    func(n int) error
+	calls  chan int
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{calls: make(chan int, 100)}
+}
+
+func (fs *fakeSource) GetReading() (*Reading, error) {
+	fs.mu.Lock()
+	fs.starts = append(fs.starts, time.Now())
+	n := len(fs.starts)
+	fs.mu.Unlock()
+
+	if fs.delay != nil {
+		time.Sleep(fs.delay(n))
+	}
+	select {
+	case fs.calls <- n:
+	default:
+	}
+	if fs.err != nil {
+		if err := fs.err(n); err != nil {
+			return nil, err
+		}
+	}
+	return &Reading{NumRuns: uint32(n)}, nil
+}
+
+// startOffsets returns when each reading started, relative to the first
+func (fs *fakeSource) startOffsets() []time.Duration {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	offsets := make([]time.Duration, len(fs.starts))
+	for i, start := range fs.starts {
+		offsets[i] = start.Sub(fs.starts[0])
+	}
+	return offsets
+}
+
+// waitCalls waits until the source has answered n readings
+func (fs *fakeSource) waitCalls(t *testing.T, n int) {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case got := <-fs.calls:
+			if got >= n {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %d readings", n)
+		}
+	}
+}
+
+// collect reads samples and errors until both channels are closed
+func collect(t *testing.T, samples <-chan Sample, errs <-chan error) ([]Sample, []error) {
+	t.Helper()
+
+	var gotSamples []Sample
+	var gotErrs []error
+	timeout := time.After(2 * time.Second)
+	for samples != nil || errs != nil {
+		select {
+		case s, ok := <-samples:
+			if !ok {
+				samples = nil
+				continue
+			}
+			gotSamples = append(gotSamples, s)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			gotErrs = append(gotErrs, err)
+		case <-timeout:
+			t.Fatal("stream did not finish")
+		}
+	}
+	return gotSamples, gotErrs
+}
+
+func TestStreamDriftFree(t *testing.T) {
+	source := newFakeSource()
+	source.delay = func(int) time.Duration { return 8 * time.Millisecond }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples, errs := Stream(ctx, source, StreamOptions{Interval: 20 * time.Millisecond, Buffer: 10})
+	source.waitCalls(t, 6)
+	cancel()
+	collect(t, samples, errs)
+
+	// Readings start every 20ms no matter how long they take. Waiting 20ms
+	// after each reading would put the sixth one at 140ms
+	offsets := source.startOffsets()
+	if got := offsets[5]; got < 95*time.Millisecond || got > 125*time.Millisecond {
+		t.Errorf("sixth reading started at %v, want about 100ms (starts: %v)", got, offsets)
+	}
+}
+
+func TestStreamOverrun(t *testing.T) {
+	tests := []struct {
+		name    string
+		overrun OverrunPolicy
+		want    int // Readings started in the first 55ms
+	}{
+		// The slow first reading misses the readings due at 20ms and 40ms
+		{name: "skip", overrun: OverrunSkip, want: 1},
+		{name: "queue", overrun: OverrunQueue, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeSource()
+			source.delay = func(n int) time.Duration {
+				if n == 1 {
+					return 45 * time.Millisecond
+				}
+				return 0
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			samples, errs := Stream(ctx, source, StreamOptions{
+				Interval: 20 * time.Millisecond,
+				Overrun:  tt.overrun,
+				Buffer:   10,
+			})
+			source.waitCalls(t, tt.want+1)
+			cancel()
+			collect(t, samples, errs)
+
+			got := 0
+			for _, offset := range source.startOffsets() {
+				if offset < 55*time.Millisecond {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("%d readings started in the first 55ms, want %d (starts: %v)", got, tt.want, source.startOffsets())
+			}
+		})
+	}
+}
+
+func TestStreamBackpressureDropOldest(t *testing.T) {
+	source := newFakeSource()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Nobody reads while the stream takes 10 readings back to back
+	source.err = func(n int) error {
+		if n == 10 {
+			cancel()
+		}
+		return nil
+	}
+	samples, errs := Stream(ctx, source, StreamOptions{Buffer: 2})
+	source.waitCalls(t, 10)
+
+	got, _ := collect(t, samples, errs)
+	if len(got) != 2 || got[0].Reading.NumRuns != 9 || got[1].Reading.NumRuns != 10 {
+		var runs []uint32
+		for _, s := range got {
+			runs = append(runs, s.Reading.NumRuns)
+		}
+		t.Errorf("got readings %v, want [9 10]", runs)
+	}
+}
+
+func TestStreamBackpressureBlock(t *testing.T) {
+	source := newFakeSource()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	samples, _ := Stream(ctx, source, StreamOptions{Buffer: 2, Backpressure: BackpressureBlock})
+
+	// Two samples fill the buffer and the third waits to be sent
+	source.waitCalls(t, 3)
+	time.Sleep(30 * time.Millisecond)
+	if got := len(source.startOffsets()); got != 3 {
+		t.Fatalf("%d readings taken while the consumer was away, want 3", got)
+	}
+
+	for want := uint32(1); want <= 5; want++ {
+		s := <-samples
+		if s.Reading.NumRuns != want {
+			t.Fatalf("got reading %d, want %d", s.Reading.NumRuns, want)
+		}
+	}
+}
+
+func TestStreamCancelWhileBlocked(t *testing.T) {
+	source := newFakeSource()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples, errs := Stream(ctx, source, StreamOptions{Backpressure: BackpressureBlock})
+	source.waitCalls(t, 2)
+	cancel()
+
+	// Both channels close even though the stream is waiting for the consumer
+	collect(t, samples, errs)
+}
+
+func TestStreamErrors(t *testing.T) {
+	source := newFakeSource()
+	source.err = func(n int) error {
+		switch n {
+		case 2:
+			return &ShortReadError{Got: 10, Want: PacketSize}
+		case 4:
+			return portError("failed to read response", fmt.Errorf("unplugged"))
+		}
+		return nil
+	}
+
+	samples, errs := Stream(context.Background(), source, StreamOptions{Buffer: 10})
+	gotSamples, gotErrs := collect(t, samples, errs)
+
+	// The timeout is reported and skipped, the disconnect ends the stream
+	if len(gotSamples) != 2 {
+		t.Errorf("got %d samples, want 2", len(gotSamples))
+	}
+	if len(gotErrs) != 2 || !errors.Is(gotErrs[0], ErrTimeout) || !errors.Is(gotErrs[1], ErrDisconnected) {
+		t.Errorf("errors = %v, want a timeout then a disconnect", gotErrs)
+	}
+	if got := len(source.startOffsets()); got != 4 {
+		t.Errorf("%d readings taken, want 4", got)
+	}
+}
+
+func TestStreamStopOnError(t *testing.T) {
+	source := newFakeSource()
+	source.err = func(n int) error {
+		if n <= 3 {
+			return portError("failed to write command", fmt.Errorf("unplugged"))
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples, errs := Stream(ctx, source, StreamOptions{
+		Buffer:      10,
+		StopOnError: func(error) bool { return false },
+	})
+	source.waitCalls(t, 4)
+	cancel()
+
+	gotSamples, gotErrs := collect(t, samples, errs)
+	if len(gotErrs) != 3 || len(gotSamples) == 0 {
+		t.Errorf("got %d samples and %d errors, want the stream to outlive 3 disconnects", len(gotSamples), len(gotErrs))
+	}
+}
+
+func TestStreamWake(t *testing.T) {
+	source := newFakeSource()
+	wake := make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	samples, _ := Stream(ctx, source, StreamOptions{Interval: time.Hour, Wake: wake})
+
+	<-samples
+	wake <- struct{}{}
+
+	select {
+	case s := <-samples:
+		if s.Reading.NumRuns != 2 {
+			t.Errorf("got reading %d, want 2", s.Reading.NumRuns)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reading after waking the stream")
+	}
+}
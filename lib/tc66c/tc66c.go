@@ -3,6 +3,7 @@ package tc66c
 import (
 	"encoding/binary"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.bug.st/serial"
@@ -42,6 +43,10 @@ const (
 	ChunkOKResponse = "OK"       // Expected response after each chunk
 )
 
+// responseTimeout is how long to wait for the device to start or continue
+// a response
+const responseTimeout = 2 * time.Second
+
 // AES-ECB encryption key (static 32-byte key from protocol documentation)
 var AESKey = []byte{
 	0x58, 0x21, 0xfa, 0x56, 0x01, 0xb2, 0xf0, 0x26,
@@ -71,16 +76,50 @@ func (m DeviceMode) String() string {
 	}
 }
 
-// TC66C represents a connection to a TC66C device
+// TC66C represents a connection to a TC66C device. Its methods are safe to
+// call from any goroutine: commands are queued and sent one at a time by a
+// goroutine owned by the device, screen commands ahead of readings and
+// readings ahead of recording downloads and firmware updates
 type TC66C struct {
-	port serial.Port
-	Mode DeviceMode // Current device mode (firmware/bootloader)
+	port     serial.Port
+	portName string      // Serial port name, empty if created from an open port
+	Mode     DeviceMode  // Current device mode (firmware/bootloader)
+	Retry    RetryPolicy // Recovery from lost or corrupted readings, set before sharing the device
+
+	sched     *scheduler
+	closeOnce sync.Once
+	closeErr  error
+
+	stats statsCounter
+
+	readTimeout time.Duration // Read timeout currently set on the port, 0 if unknown. Only used by sched
 }
 
 // NewTC66C creates a new TC66C device connection
 func NewTC66C(portName string) (*TC66C, error) {
+	port, err := OpenPort(portName)
+	if err != nil {
+		return nil, err
+	}
+
+	tc, err := NewTC66CFromNamedPort(portName, port)
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+
+	return tc, nil
+}
+
+// OpenPort opens a serial port with the settings used by the TC66C
+func OpenPort(portName string) (serial.Port, error) {
+	return openPort(portName, 115200)
+}
+
+// openPort opens a serial port at the given baud rate, 8N1
+func openPort(portName string, baudRate int) (serial.Port, error) {
 	mode := &serial.Mode{
-		BaudRate: 115200,
+		BaudRate: baudRate,
 		Parity:   serial.NoParity,
 		DataBits: 8,
 		StopBits: serial.OneStopBit,
@@ -92,21 +131,38 @@ func NewTC66C(portName string) (*TC66C, error) {
 	}
 
 	// Set read timeout
-	err = port.SetReadTimeout(2 * time.Second)
+	err = port.SetReadTimeout(responseTimeout)
 	if err != nil {
 		port.Close()
 		return nil, fmt.Errorf("failed to set read timeout: %w", err)
 	}
 
+	return port, nil
+}
+
+// NewTC66CFromPort creates a TC66C on an already open port, e.g. one wrapped
+// by NewTranscriptPort or a ReplayPort. The caller keeps ownership of the
+// port if an error is returned
+func NewTC66CFromPort(port serial.Port) (*TC66C, error) {
+	return NewTC66CFromNamedPort("", port)
+}
+
+// NewTC66CFromNamedPort is NewTC66CFromPort for a port opened by name and
+// then wrapped, so PortName and Info still report it
+func NewTC66CFromNamedPort(portName string, port serial.Port) (*TC66C, error) {
 	tc := &TC66C{
-		port: port,
-		Mode: ModeUnknown,
+		port:     port,
+		portName: portName,
+		Mode:     ModeUnknown,
+		Retry: DefaultRetryPolicy(),
+		sched: newScheduler(),
 	}
 
 	// Query device mode
 	deviceMode, err := tc.queryDeviceMode()
 	if err != nil {
-		port.Close()
+		tc.sched.stop()
+		tc.sched.wait()
 		return nil, fmt.Errorf("failed to query device mode: %w", err)
 	}
 	tc.Mode = deviceMode
@@ -141,59 +197,94 @@ func (tc *TC66C) queryDeviceMode() (DeviceMode, error) {
 	}
 }
 
-// Close closes the serial port connection
+// PortName returns the serial port name, or an empty string if the device
+// was created from an already open port
+func (tc *TC66C) PortName() string {
+	return tc.portName
+}
+
+// Close closes the serial port connection. Queued commands fail with
+// ErrClosed and the command being sent, if any, is cut short. Close waits
+// for the device goroutine to exit and can be called more than once
 func (tc *TC66C) Close() error {
-	if tc.port != nil {
-		return tc.port.Close()
-	}
-	return nil
+	tc.closeOnce.Do(func() {
+		tc.sched.stop()
+		if tc.port != nil {
+			tc.closeErr = tc.port.Close()
+		}
+		tc.sched.wait()
+	})
+	return tc.closeErr
 }
 
-// flushBuffer drains any pending data from the serial port
+// flushBuffer discards anything the device sent that was never read, such
+// as the rest of a response that timed out
 func (tc *TC66C) flushBuffer() {
-	// Set a very short timeout to quickly drain the buffer
-	tc.port.SetReadTimeout(10 * time.Millisecond)
+	tc.port.ResetInputBuffer()
+}
+
+// setReadTimeout sets the port read timeout, skipping the system call if
+// it is already set
+func (tc *TC66C) setReadTimeout(timeout time.Duration) {
+	if tc.readTimeout != timeout {
+		tc.port.SetReadTimeout(timeout)
+		tc.readTimeout = timeout
+	}
+}
+
+// drain reads and returns whatever the device sends until it has been
+// quiet for timeout
+func (tc *TC66C) drain(timeout time.Duration) []byte {
+	tc.setReadTimeout(timeout)
+	defer tc.setReadTimeout(responseTimeout)
+
+	var data []byte
 	buf := make([]byte, 1024)
 	for {
 		n, _ := tc.port.Read(buf)
 		if n == 0 {
 			break
 		}
+		data = append(data, buf[:n]...)
 	}
-	// Restore normal timeout
-	tc.port.SetReadTimeout(2 * time.Second)
+	return data
 }
 
-// sendCommand sends a command to the device
+// sendCommand sends a command to the device. Responses are waited for by
+// readResponse as they arrive, so there is no delay after writing
 func (tc *TC66C) sendCommand(cmd string) error {
 	// Flush any pending data before sending command
 	tc.flushBuffer()
+	tc.setReadTimeout(responseTimeout)
 
 	// Commands are sent as plain text followed by \r\n
 	_, err := tc.port.Write([]byte(cmd + "\r\n"))
 	if err != nil {
-		return fmt.Errorf("failed to write command: %w", err)
+		return portError("failed to write command", err)
 	}
 
-	// Small delay to let the device process the command
-	time.Sleep(50 * time.Millisecond)
-
 	return nil
 }
 
 // readResponse reads a response of the specified size from the device
 func (tc *TC66C) readResponse(size int) ([]byte, error) {
+	return readFull(tc.port, size)
+}
+
+// readFull reads size bytes from port, failing with a ShortReadError if
+// a read times out first
+func readFull(port serial.Port, size int) ([]byte, error) {
 	buffer := make([]byte, size)
 	n := 0
 
 	// Read until we have all the expected bytes
 	for n < size {
-		bytesRead, err := tc.port.Read(buffer[n:])
+		bytesRead, err := port.Read(buffer[n:])
 		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
+			return nil, portError("failed to read response", err)
 		}
 		if bytesRead == 0 {
-			return nil, fmt.Errorf("timeout reading response (got %d of %d bytes)", n, size)
+			return nil, &ShortReadError{Got: n, Want: size}
 		}
 		n += bytesRead
 	}
@@ -203,6 +294,11 @@ func (tc *TC66C) readResponse(size int) ([]byte, error) {
 
 // Query sends the 'query' command to check device mode
 func (tc *TC66C) Query() ([]byte, error) {
+	return schedule(tc.sched, priorityNormal, tc.query)
+}
+
+// query sends the 'query' command. Runs on the device goroutine
+func (tc *TC66C) query() ([]byte, error) {
 	err := tc.sendCommand(CmdQuery)
 	if err != nil {
 		return nil, err
@@ -211,23 +307,51 @@ func (tc *TC66C) Query() ([]byte, error) {
 	return tc.readResponse(4)
 }
 
-// GetReading sends the 'getva' command and returns a parsed Reading
-func (tc *TC66C) GetReading() (*Reading, error) {
-	if tc.Mode != ModeFirmware {
-		return nil, fmt.Errorf("device must be in firmware mode (current mode: %s)", tc.Mode)
+// RawCommand sends an arbitrary command, followed by \r\n like every
+// other command, and returns the bytes the device answers with. It reads
+// until length bytes arrived, or with a length of 0 until the device has
+// been quiet for timeout (2s if 0). A response shorter than length is
+// returned along with a ShortReadError. It works in any device mode
+func (tc *TC66C) RawCommand(cmd string, length int, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = responseTimeout
 	}
 
-	err := tc.sendCommand(CmdGetVA)
-	if err != nil {
-		return nil, err
-	}
+	return schedule(tc.sched, priorityNormal, func() ([]byte, error) {
+		if err := tc.sendCommand(cmd); err != nil {
+			return nil, err
+		}
 
-	// Read the 192-byte encrypted response
-	encrypted, err := tc.readResponse(PacketSize)
-	if err != nil {
-		return nil, err
-	}
+		tc.setReadTimeout(timeout)
+		defer tc.setReadTimeout(responseTimeout)
+
+		var data []byte
+		buf := make([]byte, 256)
+		for length <= 0 || len(data) < length {
+			want := buf
+			if length > 0 {
+				want = buf[:min(len(buf), length-len(data))]
+			}
+
+			n, err := tc.port.Read(want)
+			data = append(data, want[:n]...)
+			if err != nil {
+				return data, portError("failed to read response", err)
+			}
+			if n == 0 {
+				break
+			}
+		}
 
+		if length > 0 && len(data) < length {
+			return data, &ShortReadError{Got: len(data), Want: length}
+		}
+		return data, nil
+	})
+}
+
+// decodePacket decrypts and parses a 192-byte getva packet
+func decodePacket(encrypted []byte) (*Reading, error) {
 	// Decrypt the packet
 	decrypted, err := DecryptPacket(encrypted)
 	if err != nil {
@@ -243,11 +367,36 @@ func (tc *TC66C) GetReading() (*Reading, error) {
 	return reading, nil
 }
 
+// GetRawReading sends the 'getva' command and returns the 192-byte
+// encrypted packet as received
+func (tc *TC66C) GetRawReading() ([]byte, error) {
+	return schedule(tc.sched, priorityNormal, tc.getRawReading)
+}
+
+// getRawReading sends the 'getva' command. Runs on the device goroutine
+func (tc *TC66C) getRawReading() ([]byte, error) {
+	if tc.Mode != ModeFirmware {
+		return nil, &ModeError{Want: ModeFirmware, Got: tc.Mode}
+	}
+
+	err := tc.sendCommand(CmdGetVA)
+	if err != nil {
+		return nil, err
+	}
+
+	return tc.readResponse(PacketSize)
+}
+
 // GetRecordings sends the 'gtrec' command to retrieve recordings
 // Returns a slice of RecordingEntry structs containing voltage and current pairs
 func (tc *TC66C) GetRecordings() ([]*RecordingEntry, error) {
+	return schedule(tc.sched, priorityBulk, tc.getRecordings)
+}
+
+// getRecordings downloads the recordings. Runs on the device goroutine
+func (tc *TC66C) getRecordings() ([]*RecordingEntry, error) {
 	if tc.Mode != ModeFirmware {
-		return nil, fmt.Errorf("device must be in firmware mode (current mode: %s)", tc.Mode)
+		return nil, &ModeError{Want: ModeFirmware, Got: tc.Mode}
 	}
 
 	err := tc.sendCommand(CmdGetRec)
@@ -263,7 +412,7 @@ func (tc *TC66C) GetRecordings() ([]*RecordingEntry, error) {
 	for {
 		n, err := tc.port.Read(chunk)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read recording chunk: %w", err)
+			return nil, portError("failed to read recording chunk", err)
 		}
 		if n == 0 {
 			break
@@ -299,26 +448,27 @@ func (tc *TC66C) GetRecordings() ([]*RecordingEntry, error) {
 
 // PreviousPage sends the 'lastp' command to go to the previous page
 func (tc *TC66C) PreviousPage() error {
-	if tc.Mode != ModeFirmware {
-		return fmt.Errorf("device must be in firmware mode (current mode: %s)", tc.Mode)
-	}
-	return tc.sendCommand(CmdLastP)
+	return tc.screenCommand(CmdLastP)
 }
 
 // NextPage sends the 'nextp' command to go to the next page
 func (tc *TC66C) NextPage() error {
-	if tc.Mode != ModeFirmware {
-		return fmt.Errorf("device must be in firmware mode (current mode: %s)", tc.Mode)
-	}
-	return tc.sendCommand(CmdNextP)
+	return tc.screenCommand(CmdNextP)
 }
 
 // RotateScreen sends the 'rotat' command to rotate the screen
 func (tc *TC66C) RotateScreen() error {
+	return tc.screenCommand(CmdRotat)
+}
+
+// screenCommand sends a screen command ahead of any queued reading
+func (tc *TC66C) screenCommand(cmd string) error {
 	if tc.Mode != ModeFirmware {
-		return fmt.Errorf("device must be in firmware mode (current mode: %s)", tc.Mode)
+		return &ModeError{Want: ModeFirmware, Got: tc.Mode}
 	}
-	return tc.sendCommand(CmdRotat)
+	return tc.sched.run(priorityUser, func() error {
+		return tc.sendCommand(cmd)
+	})
 }
 
 // FirmwareUpdateProgress represents the progress of a firmware update
@@ -333,78 +483,5 @@ type FirmwareUpdateProgress struct {
 // The device must be in bootloader mode before calling this function
 // progressCallback is called after each chunk is sent (can be nil)
 func (tc *TC66C) UpdateFirmware(firmwareData []byte, progressCallback func(FirmwareUpdateProgress)) error {
-	// Safety check: device must be in bootloader mode
-	if tc.Mode != ModeBootloader {
-		return fmt.Errorf("device must be in bootloader mode to update firmware (current mode: %s)", tc.Mode)
-	}
-
-	// Calculate file size and chunk count
-	fileSize := len(firmwareData)
-	if fileSize == 0 {
-		return fmt.Errorf("firmware data is empty")
-	}
-
-	chunkCount := (fileSize + FirmwareChunkSize - 1) / FirmwareChunkSize
-
-	// Enter firmware update mode
-	err := tc.sendCommand(CmdUpdate)
-	if err != nil {
-		return fmt.Errorf("failed to send update command: %w", err)
-	}
-
-	// Read the "uprdy" response (5 bytes)
-	response, err := tc.readResponse(5)
-	if err != nil {
-		return fmt.Errorf("failed to read update mode response: %w", err)
-	}
-
-	if string(response) != UpdateModeResponse {
-		return fmt.Errorf("device replied with '%s', expected '%s'", string(response), UpdateModeResponse)
-	}
-
-	// Send firmware in chunks
-	bytesSent := 0
-	chunksSent := 0
-
-	for bytesSent < fileSize {
-		// Calculate chunk size (last chunk may be smaller)
-		chunkEnd := bytesSent + FirmwareChunkSize
-		if chunkEnd > fileSize {
-			chunkEnd = fileSize
-		}
-		chunk := firmwareData[bytesSent:chunkEnd]
-
-		// Send chunk
-		_, err := tc.port.Write(chunk)
-		if err != nil {
-			return fmt.Errorf("failed to write chunk %d: %w", chunksSent+1, err)
-		}
-
-		// Wait for "OK" response (2 bytes)
-		chunkResponse, err := tc.readResponse(2)
-		if err != nil {
-			return fmt.Errorf("failed to read response for chunk %d: %w", chunksSent+1, err)
-		}
-
-		if string(chunkResponse) != ChunkOKResponse {
-			return fmt.Errorf("device replied with '%s' for chunk %d, expected '%s'. Device may not boot normally, try again",
-				string(chunkResponse), chunksSent+1, ChunkOKResponse)
-		}
-
-		// Update progress
-		bytesSent += len(chunk)
-		chunksSent++
-
-		// Call progress callback if provided
-		if progressCallback != nil {
-			progressCallback(FirmwareUpdateProgress{
-				BytesSent:   bytesSent,
-				TotalBytes:  fileSize,
-				ChunksSent:  chunksSent,
-				TotalChunks: chunkCount,
-			})
-		}
-	}
-
-	return nil
+	return tc.UpdateFirmwareWithOptions(firmwareData, DefaultFirmwareUpdateOptions(), progressCallback)
 }
@@ -0,0 +1,316 @@
+package tc66c
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryDeviceMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   [][]byte
+		want    DeviceMode
+		wantErr string
+	}{
+		{name: "firmware", reply: [][]byte{[]byte("firm")}, want: ModeFirmware},
+		{name: "bootloader", reply: [][]byte{[]byte("boot")}, want: ModeBootloader},
+		{name: "split reply", reply: [][]byte{[]byte("bo"), []byte("ot")}, want: ModeBootloader},
+		{name: "garbage", reply: [][]byte{[]byte("\xff\x00zz")}, wantErr: "unknown device mode response"},
+		{name: "timeout", reply: nil, wantErr: "timeout reading response (got 0 of 4 bytes)"},
+		{name: "short", reply: [][]byte{[]byte("fi")}, wantErr: "timeout reading response (got 2 of 4 bytes)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := newFakePort(t, cmdStep(CmdQuery, tt.reply...))
+			tc, err := NewTC66CFromPort(fp)
+			fp.done()
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTC66CFromPort: %v", err)
+			}
+			if tc.Mode != tt.want {
+				t.Errorf("Mode = %s, want %s", tc.Mode, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetReading(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tc, fp := newTestDevice(t, "firm", cmdStep(CmdGetVA, split(packet, 7)...))
+	reading, err := tc.GetReading()
+	fp.done()
+	if err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+
+	assertReading(t, reading, &testReading)
+}
+
+func TestGetReadingErrors(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tests := []struct {
+		name    string
+		reply   [][]byte
+		wantErr string
+		wantIs  error
+	}{
+		{name: "timeout", reply: nil, wantErr: "got 0 of 192 bytes", wantIs: ErrTimeout},
+		{name: "short read", reply: [][]byte{packet[:100]}, wantErr: "got 100 of 192 bytes", wantIs: ErrTimeout},
+		{name: "stall mid packet", reply: [][]byte{packet[:64], nil, packet[64:]}, wantErr: "got 64 of 192 bytes", wantIs: ErrTimeout},
+		{name: "garbage", reply: [][]byte{make([]byte, PacketSize)}, wantErr: "failed to decrypt packet", wantIs: ErrBadPacket},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, _ := newTestDevice(t, "firm", cmdStep(CmdGetVA, tt.reply...))
+			tc.Retry = noRetry
+			_, err := tc.GetReading()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, tt.wantIs) {
+				t.Errorf("error = %v, want it to be %v", err, tt.wantIs)
+			}
+		})
+	}
+}
+
+func TestGetReadingShortReadError(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	tc, _ := newTestDevice(t, "firm", cmdStep(CmdGetVA, packet[:100]))
+	tc.Retry = noRetry
+	_, err := tc.GetReading()
+
+	var short *ShortReadError
+	if !errors.As(err, &short) {
+		t.Fatalf("error = %v, want a ShortReadError", err)
+	}
+	if short.Got != 100 || short.Want != PacketSize {
+		t.Errorf("ShortReadError = %+v, want Got 100 Want %d", short, PacketSize)
+	}
+}
+
+func TestGetReadingDisconnected(t *testing.T) {
+	tc, fp := newTestDevice(t, "firm")
+	fp.Close()
+
+	_, err := tc.GetReading()
+	if !errors.Is(err, ErrDisconnected) {
+		t.Fatalf("error = %v, want ErrDisconnected", err)
+	}
+	if errors.Is(err, ErrTimeout) {
+		t.Errorf("disconnect reported as a timeout: %v", err)
+	}
+}
+
+func TestGetReadingWrongMode(t *testing.T) {
+	tc, fp := newTestDevice(t, "boot")
+	_, err := tc.GetReading()
+	fp.done()
+	if err == nil || !strings.Contains(err.Error(), "must be in firmware mode") {
+		t.Fatalf("error = %v, want firmware mode error", err)
+	}
+
+	var mode *ModeError
+	if !errors.Is(err, ErrWrongMode) || !errors.As(err, &mode) {
+		t.Fatalf("error = %v, want a ModeError", err)
+	}
+	if mode.Want != ModeFirmware || mode.Got != ModeBootloader {
+		t.Errorf("ModeError = %+v", mode)
+	}
+}
+
+func TestGetReadingChecksumFailure(t *testing.T) {
+	for block, prefix := range []string{Block1Prefix, Block2Prefix, Block3Prefix} {
+		t.Run(prefix, func(t *testing.T) {
+			plain := buildPlainPacket(testReading)
+			plain[block*BlockSize+30] ^= 0x01
+
+			tc, _ := newTestDevice(t, "firm", cmdStep(CmdGetVA, encryptPacket(t, plain)))
+			tc.Retry = noRetry
+			_, err := tc.GetReading()
+
+			want := prefix + " checksum verification failed"
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("error = %v, want it to contain %q", err, want)
+			}
+
+			var checksum *ChecksumError
+			if !errors.As(err, &checksum) || checksum.Block != prefix {
+				t.Errorf("error = %v, want a ChecksumError for %s", err, prefix)
+			}
+			if !errors.Is(err, ErrBadPacket) {
+				t.Errorf("error = %v, want it to be ErrBadPacket", err)
+			}
+		})
+	}
+}
+
+func TestGetReadingStaleBytesFlushed(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	// The tail of an earlier response is still queued when getva is sent
+	tc, fp := newTestDevice(t, "firm", cmdStep(CmdGetVA, packet))
+	fp.pending = append(fp.pending, []byte("stale bytes from a previous reply"))
+
+	reading, err := tc.GetReading()
+	if err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+	assertReading(t, reading, &testReading)
+}
+
+func TestGetRecordings(t *testing.T) {
+	record := func(voltage, current uint32) []byte {
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint32(data[0:], voltage)
+		binary.LittleEndian.PutUint32(data[4:], current)
+		return data
+	}
+
+	var stream []byte
+	for i := uint32(0); i < 5; i++ {
+		stream = append(stream, record(50000+i, 100000+i)...)
+	}
+
+	tests := []struct {
+		name  string
+		reply [][]byte
+		want  int
+	}{
+		{name: "empty", reply: nil, want: 0},
+		{name: "aligned chunks", reply: split(stream, 8), want: 5},
+		{name: "one byte at a time", reply: split(stream, 1), want: 5},
+		{name: "chunks across records", reply: [][]byte{stream[:3], stream[3:13], stream[13:14], stream[14:]}, want: 5},
+		{name: "trailing partial record", reply: [][]byte{stream[:8], stream[8:20]}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, fp := newTestDevice(t, "firm", cmdStep(CmdGetRec, tt.reply...))
+			recordings, err := tc.GetRecordings()
+			fp.done()
+			if err != nil {
+				t.Fatalf("GetRecordings: %v", err)
+			}
+
+			if len(recordings) != tt.want {
+				t.Fatalf("got %d recordings, want %d", len(recordings), tt.want)
+			}
+			for i, entry := range recordings {
+				wantV := float64(50000+i) / 10000
+				wantI := float64(100000+i) / 100000
+				if !closeTo(entry.Voltage, wantV) || !closeTo(entry.Current, wantI) {
+					t.Errorf("recording %d = %v, want V %.4f I %.5f", i, entry, wantV, wantI)
+				}
+			}
+		})
+	}
+}
+
+func TestRawCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		cmd     string
+		reply   [][]byte
+		length  int
+		want    string
+		wantErr error
+	}{
+		{name: "expected length", mode: "firm", cmd: "query", reply: [][]byte{[]byte("fi"), []byte("rm")}, length: 4, want: "firm"},
+		{name: "until quiet", mode: "firm", cmd: "hello", reply: [][]byte{[]byte("some"), []byte(" reply")}, want: "some reply"},
+		{name: "no reply", mode: "firm", cmd: "bogus", want: ""},
+		{name: "short", mode: "firm", cmd: "getva", reply: [][]byte{[]byte("part")}, length: 10, want: "part", wantErr: ErrTimeout},
+		{name: "extra bytes left", mode: "firm", cmd: "query", reply: [][]byte{[]byte("firmware")}, length: 4, want: "firm"},
+		{name: "bootloader mode", mode: "boot", cmd: "query", reply: [][]byte{[]byte("boot")}, length: 4, want: "boot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc, fp := newTestDevice(t, tt.mode, cmdStep(tt.cmd, tt.reply...))
+			got, err := tc.RawCommand(tt.cmd, tt.length, time.Millisecond)
+			fp.done()
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("RawCommand: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenCommands(t *testing.T) {
+	tc, fp := newTestDevice(t, "firm", cmdStep(CmdNextP), cmdStep(CmdLastP), cmdStep(CmdRotat))
+
+	if err := tc.NextPage(); err != nil {
+		t.Fatalf("NextPage: %v", err)
+	}
+	if err := tc.PreviousPage(); err != nil {
+		t.Fatalf("PreviousPage: %v", err)
+	}
+	if err := tc.RotateScreen(); err != nil {
+		t.Fatalf("RotateScreen: %v", err)
+	}
+	fp.done()
+
+	boot, _ := newTestDevice(t, "boot")
+	if err := boot.NextPage(); !errors.Is(err, ErrWrongMode) {
+		t.Errorf("NextPage in bootloader mode: error = %v, want ErrWrongMode", err)
+	}
+}
+
+// assertReading compares every field of a decoded reading
+func assertReading(t *testing.T, got, want *Reading) {
+	t.Helper()
+
+	if got.Product != want.Product || got.Version != want.Version ||
+		got.SerialNumber != want.SerialNumber || got.NumRuns != want.NumRuns ||
+		got.Group0MAh != want.Group0MAh || got.Group0MWh != want.Group0MWh ||
+		got.Group1MAh != want.Group1MAh || got.Group1MWh != want.Group1MWh ||
+		got.TemperatureSign != want.TemperatureSign {
+		t.Errorf("reading = %+v, want %+v", got, want)
+	}
+
+	floats := []struct {
+		name      string
+		got, want float64
+	}{
+		{"Voltage", got.Voltage, want.Voltage},
+		{"Current", got.Current, want.Current},
+		{"Power", got.Power, want.Power},
+		{"Resistance", got.Resistance, want.Resistance},
+		{"Temperature", got.Temperature, want.Temperature},
+		{"DPlusVoltage", got.DPlusVoltage, want.DPlusVoltage},
+		{"DMinusVoltage", got.DMinusVoltage, want.DMinusVoltage},
+	}
+	for _, f := range floats {
+		if !closeTo(f.got, f.want) {
+			t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+		}
+	}
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
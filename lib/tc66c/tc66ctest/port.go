@@ -0,0 +1,178 @@
+// Package tc66ctest provides an emulated TC66C for testing code built on
+// lib/tc66c without a meter attached
+package tc66ctest
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skgsergio/tc66-toolkit/lib/tc66c"
+	"go.bug.st/serial"
+)
+
+// ErrUnplugged is returned by every read and write of an unplugged Port
+var ErrUnplugged = errors.New("fake port unplugged")
+
+// Port is a serial.Port that answers like a TC66C in firmware mode: query
+// replies "firm", getva replies with the current reading and the other
+// commands with nothing. Reads with nothing queued time out immediately by
+// returning 0 bytes, as with the scripted port of the library's tests
+type Port struct {
+	mu        sync.Mutex
+	reading   tc66c.Reading
+	pending   []byte
+	commands  []string
+	unplugged bool
+	closed    bool
+}
+
+// NewPort returns a port whose meter measures reading
+func NewPort(reading tc66c.Reading) *Port {
+	return &Port{reading: reading}
+}
+
+// SetReading changes what the meter measures
+func (p *Port) SetReading(reading tc66c.Reading) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reading = reading
+}
+
+// Unplug makes every later read and write fail, as when the USB cable is
+// pulled
+func (p *Port) Unplug() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unplugged = true
+}
+
+// Commands returns the commands written to the port so far
+func (p *Port) Commands() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.commands...)
+}
+
+// Closed reports whether the port was closed
+func (p *Port) Closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+func (p *Port) Read(buf []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return 0, err
+	}
+	n := copy(buf, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+func (p *Port) Write(buf []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.check(); err != nil {
+		return 0, err
+	}
+
+	cmd := strings.TrimSuffix(string(buf), "\r\n")
+	p.commands = append(p.commands, cmd)
+	switch cmd {
+	case tc66c.CmdQuery:
+		p.pending = append(p.pending, "firm"...)
+	case tc66c.CmdGetVA:
+		p.pending = append(p.pending, EncodePacket(p.reading)...)
+	}
+	return len(buf), nil
+}
+
+// check fails once the port is unplugged or closed
+func (p *Port) check() error {
+	if p.closed {
+		return errors.New("fake port closed")
+	}
+	if p.unplugged {
+		return ErrUnplugged
+	}
+	return nil
+}
+
+// ResetInputBuffer drops every queued reply that was not read yet
+func (p *Port) ResetInputBuffer() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = nil
+	return nil
+}
+
+func (p *Port) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+func (p *Port) SetMode(mode *serial.Mode) error      { return nil }
+func (p *Port) Drain() error                         { return nil }
+func (p *Port) ResetOutputBuffer() error             { return nil }
+func (p *Port) SetDTR(dtr bool) error                { return nil }
+func (p *Port) SetRTS(rts bool) error                { return nil }
+func (p *Port) SetReadTimeout(t time.Duration) error { return nil }
+func (p *Port) Break(time.Duration) error            { return nil }
+func (p *Port) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{}, nil
+}
+
+// EncodePacket encodes r as the encrypted 192-byte packet a TC66C with
+// firmware 1.18 answers getva with
+func EncodePacket(r tc66c.Reading) []byte {
+	data := make([]byte, tc66c.PacketSize)
+	put := func(offset int, v uint32) { binary.LittleEndian.PutUint32(data[offset:], v) }
+
+	copy(data[0:], tc66c.Block1Prefix)
+	copy(data[4:8], r.Product)
+	copy(data[8:12], r.Version)
+	put(12, r.SerialNumber)
+	put(44, r.NumRuns)
+	put(48, uint32(r.Voltage*1e4+0.5))
+	put(52, uint32(r.Current*1e5+0.5))
+	put(56, uint32(r.Power*1e4+0.5))
+
+	pac2 := tc66c.BlockSize
+	copy(data[pac2:], tc66c.Block2Prefix)
+	put(pac2+4, uint32(r.Resistance*1e2+0.5))
+	put(pac2+8, r.Group0MAh)
+	put(pac2+12, r.Group0MWh)
+	put(pac2+16, r.Group1MAh)
+	put(pac2+20, r.Group1MWh)
+	put(pac2+24, r.TemperatureSign)
+	put(pac2+28, uint32(r.Temperature))
+	put(pac2+32, uint32(r.DPlusVoltage*1e2+0.5))
+	put(pac2+36, uint32(r.DMinusVoltage*1e2+0.5))
+
+	copy(data[2*tc66c.BlockSize:], tc66c.Block3Prefix)
+
+	for block := 0; block < tc66c.NumBlocks; block++ {
+		offset := block * tc66c.BlockSize
+		crc := tc66c.CalculateCRC16Modbus(data[offset : offset+60])
+		binary.LittleEndian.PutUint32(data[offset+60:], uint32(crc))
+	}
+
+	cipher, err := aes.NewCipher(tc66c.AESKey)
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < len(data); i += cipher.BlockSize() {
+		cipher.Encrypt(data[i:i+cipher.BlockSize()], data[i:i+cipher.BlockSize()])
+	}
+	return data
+}
@@ -0,0 +1,144 @@
+package tc66c
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Trace directions besides DirSent and DirReceived
+const (
+	DirFlushed = "flushed" // Bytes discarded by a flush before a command
+)
+
+// TracePort wraps a serial port and logs every byte written and read as a
+// timestamped hex dump, for debugging the protocol by eye. Unlike a
+// TranscriptPort it also logs read timeouts and errors, and the bytes a
+// flush would otherwise discard without anyone seeing them
+type TracePort struct {
+	serial.Port
+
+	name string
+	w    io.Writer
+
+	mu          sync.Mutex
+	last        time.Time
+	readTimeout time.Duration // Last read timeout set through the TracePort, 0 if unknown
+}
+
+// NewTracePort logs all traffic on port to w, one Write call per entry.
+// name identifies the port in the log when several share w. Set the read
+// timeout through the TracePort, as TC66C does, so flushes can restore it
+func NewTracePort(port serial.Port, name string, w io.Writer) *TracePort {
+	return &TracePort{
+		Port: port,
+		name: name,
+		w:    w,
+	}
+}
+
+// Read reads from the wrapped port and logs what was received, or the
+// timeout or error
+func (tp *TracePort) Read(p []byte) (int, error) {
+	n, err := tp.Port.Read(p)
+	switch {
+	case err != nil:
+		tp.log(DirReceived, p[:n], fmt.Sprintf("error: %v", err))
+	case n == 0:
+		tp.log(DirReceived, nil, "timeout")
+	default:
+		tp.log(DirReceived, p[:n], "")
+	}
+	return n, err
+}
+
+// Write logs what is sent and writes it to the wrapped port
+func (tp *TracePort) Write(p []byte) (int, error) {
+	n, err := tp.Port.Write(p)
+	if err != nil {
+		tp.log(DirSent, p, fmt.Sprintf("error after %d bytes: %v", n, err))
+	} else {
+		tp.log(DirSent, p, "")
+	}
+	return n, err
+}
+
+// SetReadTimeout sets the read timeout of the wrapped port and remembers
+// it for ResetInputBuffer
+func (tp *TracePort) SetReadTimeout(timeout time.Duration) error {
+	tp.mu.Lock()
+	tp.readTimeout = timeout
+	tp.mu.Unlock()
+	return tp.Port.SetReadTimeout(timeout)
+}
+
+// ResetInputBuffer reads out and logs the bytes waiting in the input
+// buffer before discarding the rest, so stray bytes show up in the trace
+func (tp *TracePort) ResetInputBuffer() error {
+	tp.mu.Lock()
+	timeout := tp.readTimeout
+	tp.mu.Unlock()
+
+	// A zero timeout returns what is already buffered without waiting
+	var stray []byte
+	if err := tp.Port.SetReadTimeout(0); err == nil {
+		buf := make([]byte, 256)
+		for {
+			n, err := tp.Port.Read(buf)
+			stray = append(stray, buf[:n]...)
+			if err != nil || n == 0 {
+				break
+			}
+		}
+		if timeout != 0 {
+			tp.Port.SetReadTimeout(timeout)
+		}
+	}
+	if len(stray) > 0 {
+		tp.log(DirFlushed, stray, "")
+	}
+
+	return tp.Port.ResetInputBuffer()
+}
+
+// log writes one trace entry: a header with the time, the time since the
+// previous entry, the direction and size, followed by a hex dump of data
+func (tp *TracePort) log(dir string, data []byte, note string) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	now := time.Now()
+	var since time.Duration
+	if !tp.last.IsZero() {
+		since = now.Sub(tp.last)
+	}
+	tp.last = now
+
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "%s (+%v)", now.Format("15:04:05.000000"), since.Round(time.Microsecond))
+	if tp.name != "" {
+		fmt.Fprintf(&entry, " %s", tp.name)
+	}
+	fmt.Fprintf(&entry, " %s", dir)
+	if len(data) > 0 {
+		fmt.Fprintf(&entry, " %d bytes", len(data))
+	}
+	if note != "" {
+		fmt.Fprintf(&entry, " %s", note)
+	}
+	entry.WriteString("\n")
+
+	if len(data) > 0 {
+		for line := range strings.Lines(hex.Dump(data)) {
+			entry.WriteString("  " + line)
+		}
+	}
+
+	tp.w.Write(entry.Bytes())
+}
@@ -0,0 +1,58 @@
+package tc66c
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTracePort(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	fp := newFakePort(t, cmdStep(CmdQuery, []byte("firm")), cmdStep(CmdGetVA, packet), cmdStep("bogus"))
+	var trace bytes.Buffer
+	tc, err := NewTC66CFromNamedPort("/dev/ttyTEST", NewTracePort(fp, "/dev/ttyTEST", &trace))
+	if err != nil {
+		t.Fatalf("NewTC66CFromNamedPort: %v", err)
+	}
+	defer tc.Close()
+
+	if tc.PortName() != "/dev/ttyTEST" {
+		t.Errorf("PortName = %q, want /dev/ttyTEST", tc.PortName())
+	}
+
+	// The tail of an earlier response is still queued when getva is sent
+	fp.pending = append(fp.pending, []byte("stale"))
+	if _, err := tc.GetReading(); err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+	if _, err := tc.RawCommand("bogus", 0, time.Millisecond); err != nil {
+		t.Fatalf("RawCommand: %v", err)
+	}
+	fp.done()
+
+	log := trace.String()
+	for _, want := range []string{
+		"/dev/ttyTEST tx 7 bytes\n  00000000  71 75 65 72 79 0d 0a ",
+		"|query..|",
+		"/dev/ttyTEST rx 4 bytes\n",
+		"|firm|",
+		"/dev/ttyTEST flushed 5 bytes\n",
+		"|stale|",
+		"|getva..|",
+		"/dev/ttyTEST rx 192 bytes\n",
+		"  000000b0  ",
+		"|bogus..|",
+		"/dev/ttyTEST rx timeout\n",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("trace does not contain %q:\n%s", want, log)
+		}
+	}
+
+	// Flushing reads out the stray bytes, so they are logged before getva
+	if strings.Index(log, "|stale|") > strings.Index(log, "|getva..|") {
+		t.Errorf("stray bytes logged after the command that flushed them:\n%s", log)
+	}
+}
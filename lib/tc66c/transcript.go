@@ -0,0 +1,243 @@
+package tc66c
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Transcript directions
+const (
+	DirSent     = "tx" // Bytes written to the device
+	DirReceived = "rx" // Bytes read from the device
+)
+
+// TranscriptEntry is one write to or read from the serial port
+type TranscriptEntry struct {
+	Time time.Time `json:"time"`
+	Dir  string    `json:"dir"`  // DirSent or DirReceived
+	Data string    `json:"data"` // Hex encoded bytes
+}
+
+// Bytes returns the decoded bytes of the entry
+func (e TranscriptEntry) Bytes() ([]byte, error) {
+	return hex.DecodeString(e.Data)
+}
+
+// TranscriptPort wraps a serial port and records every byte written and
+// read as JSON lines
+type TranscriptPort struct {
+	serial.Port
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewTranscriptPort records all traffic on port to w
+func NewTranscriptPort(port serial.Port, w io.Writer) *TranscriptPort {
+	return &TranscriptPort{
+		Port: port,
+		enc:  json.NewEncoder(w),
+	}
+}
+
+// Read reads from the wrapped port and records what was received
+func (tp *TranscriptPort) Read(p []byte) (int, error) {
+	n, err := tp.Port.Read(p)
+	if n > 0 {
+		tp.record(DirReceived, p[:n])
+	}
+	return n, err
+}
+
+// Write records what is sent and writes it to the wrapped port
+func (tp *TranscriptPort) Write(p []byte) (int, error) {
+	tp.record(DirSent, p)
+	return tp.Port.Write(p)
+}
+
+// record appends an entry to the transcript
+func (tp *TranscriptPort) record(dir string, data []byte) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	tp.enc.Encode(TranscriptEntry{
+		Time: time.Now(),
+		Dir:  dir,
+		Data: hex.EncodeToString(data),
+	})
+}
+
+// LoadTranscript reads a transcript written by a TranscriptPort
+func LoadTranscript(r io.Reader) ([]TranscriptEntry, error) {
+	var entries []TranscriptEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry TranscriptEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid transcript line %d: %w", line, err)
+		}
+		if entry.Dir != DirSent && entry.Dir != DirReceived {
+			return nil, fmt.Errorf("invalid transcript line %d: unknown direction %q", line, entry.Dir)
+		}
+		if _, err := entry.Bytes(); err != nil {
+			return nil, fmt.Errorf("invalid transcript line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	return entries, nil
+}
+
+// ReplayPort is a fake serial port that plays back a transcript. Writes must
+// match the recorded sent bytes, and reads return the recorded received
+// bytes that follow them. A read returns nothing (like a timeout) while
+// the transcript is waiting for the host to send something
+type ReplayPort struct {
+	mu      sync.Mutex
+	entries [][]byte
+	dirs    []string
+	idx     int // current entry
+	off     int // offset in the current entry
+	closed  bool
+}
+
+// NewReplayPort creates a fake port that plays back entries
+func NewReplayPort(entries []TranscriptEntry) (*ReplayPort, error) {
+	rp := &ReplayPort{}
+	for i, entry := range entries {
+		data, err := entry.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("invalid transcript entry %d: %w", i, err)
+		}
+		rp.entries = append(rp.entries, data)
+		rp.dirs = append(rp.dirs, entry.Dir)
+	}
+	return rp, nil
+}
+
+// advance skips entries that have been fully consumed
+func (rp *ReplayPort) advance() {
+	for rp.idx < len(rp.entries) && rp.off >= len(rp.entries[rp.idx]) {
+		rp.idx++
+		rp.off = 0
+	}
+}
+
+// Read returns recorded device bytes up to the next recorded write
+func (rp *ReplayPort) Read(p []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.closed {
+		return 0, fmt.Errorf("replay: port closed")
+	}
+
+	n := 0
+	for n < len(p) {
+		rp.advance()
+		if rp.idx >= len(rp.entries) || rp.dirs[rp.idx] != DirReceived {
+			break
+		}
+		c := copy(p[n:], rp.entries[rp.idx][rp.off:])
+		rp.off += c
+		n += c
+	}
+
+	return n, nil
+}
+
+// Write checks p against the recorded host bytes
+func (rp *ReplayPort) Write(p []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.closed {
+		return 0, fmt.Errorf("replay: port closed")
+	}
+
+	for i, b := range p {
+		rp.advance()
+		if rp.idx >= len(rp.entries) {
+			return i, fmt.Errorf("replay: unexpected write past end of transcript")
+		}
+		if rp.dirs[rp.idx] != DirSent {
+			return i, fmt.Errorf("replay: unexpected write at entry %d, device was still sending", rp.idx)
+		}
+		if want := rp.entries[rp.idx][rp.off]; b != want {
+			return i, fmt.Errorf("replay: wrote 0x%02x at entry %d offset %d, transcript has 0x%02x", b, rp.idx, rp.off, want)
+		}
+		rp.off++
+	}
+
+	return len(p), nil
+}
+
+// Done reports whether the whole transcript has been played back
+func (rp *ReplayPort) Done() bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.advance()
+	return rp.idx >= len(rp.entries)
+}
+
+// SetMode implements serial.Port
+func (rp *ReplayPort) SetMode(mode *serial.Mode) error { return nil }
+
+// Drain implements serial.Port
+func (rp *ReplayPort) Drain() error { return nil }
+
+// ResetInputBuffer discards the recorded device bytes up to the next
+// write, like a real port flushing bytes nobody read
+func (rp *ReplayPort) ResetInputBuffer() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	for rp.advance(); rp.idx < len(rp.entries) && rp.dirs[rp.idx] == DirReceived; rp.advance() {
+		rp.off = len(rp.entries[rp.idx])
+	}
+	return nil
+}
+
+// ResetOutputBuffer implements serial.Port
+func (rp *ReplayPort) ResetOutputBuffer() error { return nil }
+
+// SetDTR implements serial.Port
+func (rp *ReplayPort) SetDTR(dtr bool) error { return nil }
+
+// SetRTS implements serial.Port
+func (rp *ReplayPort) SetRTS(rts bool) error { return nil }
+
+// GetModemStatusBits implements serial.Port
+func (rp *ReplayPort) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	return &serial.ModemStatusBits{}, nil
+}
+
+// SetReadTimeout implements serial.Port
+func (rp *ReplayPort) SetReadTimeout(t time.Duration) error { return nil }
+
+// Break implements serial.Port
+func (rp *ReplayPort) Break(time.Duration) error { return nil }
+
+// Close implements serial.Port
+func (rp *ReplayPort) Close() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.closed = true
+	return nil
+}
@@ -0,0 +1,39 @@
+package tc66c
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestReplayPortDiscardsStaleBytes(t *testing.T) {
+	packet := encryptPacket(t, buildPlainPacket(testReading))
+
+	// Older transcripts recorded the stale bytes drained before each command
+	entry := func(dir string, data []byte) TranscriptEntry {
+		return TranscriptEntry{Dir: dir, Data: hex.EncodeToString(data)}
+	}
+	rp, err := NewReplayPort([]TranscriptEntry{
+		entry(DirSent, []byte(CmdQuery+"\r\n")),
+		entry(DirReceived, []byte("firm")),
+		entry(DirReceived, []byte("stale")),
+		entry(DirSent, []byte(CmdGetVA+"\r\n")),
+		entry(DirReceived, packet),
+	})
+	if err != nil {
+		t.Fatalf("NewReplayPort: %v", err)
+	}
+
+	tc, err := NewTC66CFromPort(rp)
+	if err != nil {
+		t.Fatalf("NewTC66CFromPort: %v", err)
+	}
+	reading, err := tc.GetReading()
+	if err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+	assertReading(t, reading, &testReading)
+
+	if !rp.Done() {
+		t.Error("transcript not fully played back")
+	}
+}
@@ -0,0 +1,378 @@
+package tc66c
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// UMFrameSize is the size of the data frame UM-series meters answer
+// UMCmdGetData with
+const UMFrameSize = 130
+
+// UM-series commands. Each is a single byte, and only UMCmdGetData gets a
+// response
+const (
+	UMCmdGetData  byte = 0xF0 // Request a data frame
+	UMCmdNextPage byte = 0xF1 // Next screen
+	UMCmdRotate   byte = 0xF2 // Rotate the screen
+	UMCmdPrevPage byte = 0xF3 // Previous screen (UM25C and UM34C only)
+)
+
+// umStartMarkers maps the first two bytes of a data frame to the model
+// that sent it
+var umStartMarkers = map[uint16]string{
+	0x0963: ModelUM24C,
+	0x09c9: ModelUM25C,
+	0x0d4c: ModelUM34C,
+}
+
+// umEndMarker is the last two bytes of every data frame
+const umEndMarker = 0xfff1
+
+// umNumGroups is the number of data groups UM-series meters keep
+const umNumGroups = 10
+
+// umChargingModes names the charging mode index of a data frame
+var umChargingModes = []string{
+	"Unknown", "QC2.0", "QC3.0", "APP2.4A", "APP2.1A", "APP1.0A", "APP0.5A", "DCP1.5A", "SAMSUNG",
+}
+
+// UMData holds the fields only UM-series meters report
+type UMData struct {
+	ActiveGroup   int         `json:"active_group"`   // Data group being counted, 0-9
+	Groups        []DataGroup `json:"groups"`         // All 10 data groups
+	ChargingMode  string      `json:"charging_mode"`  // Detected from D+/D-, e.g. "QC2.0" or "DCP1.5A"
+	LoadTimer     LoadTimer   `json:"load_timer"`     // Charge counted while the current is above a threshold
+	TemperatureF  float64     `json:"temperature_f"`  // Temperature in °F
+	ScreenTimeout int         `json:"screen_timeout"` // Screen off after this many minutes, 0 for never
+	Backlight     int         `json:"backlight"`      // Backlight level, 0-5
+	Screen        int         `json:"screen"`         // Page being shown
+}
+
+// DataGroup is a charge counter a UM-series meter keeps
+type DataGroup struct {
+	MAh uint32 `json:"mah"`
+	MWh uint32 `json:"mwh"`
+}
+
+// LoadTimer is the charge a UM-series meter counts, and for how long,
+// while the current is above a threshold
+type LoadTimer struct {
+	Threshold float64 `json:"threshold"` // Current threshold in A
+	MAh       uint32  `json:"mah"`
+	MWh       uint32  `json:"mwh"`
+	Seconds   uint32  `json:"seconds"` // Time spent above the threshold
+	Running   bool    `json:"running"` // The current is above the threshold
+}
+
+// ParseUMFrame parses a 130-byte UM-series data frame into a Reading. The
+// model is told by the start marker and reported in Reading.Product. The
+// first two data groups also fill Group0 and Group1
+func ParseUMFrame(frame []byte) (*Reading, error) {
+	if len(frame) != UMFrameSize {
+		return nil, fmt.Errorf("%w: invalid frame size: expected %d, got %d", ErrBadPacket, UMFrameSize, len(frame))
+	}
+
+	u16 := func(offset int) uint16 { return binary.BigEndian.Uint16(frame[offset:]) }
+	u32 := func(offset int) uint32 { return binary.BigEndian.Uint32(frame[offset:]) }
+
+	model, ok := umStartMarkers[u16(0)]
+	if !ok {
+		return nil, &MarkerError{Got: u16(0)}
+	}
+	if end := u16(UMFrameSize - 2); end != umEndMarker {
+		return nil, &MarkerError{End: true, Got: end}
+	}
+
+	// The UM25C measures with an extra digit
+	voltageScale, currentScale := 1e-2, 1e-3
+	if model == ModelUM25C {
+		voltageScale, currentScale = 1e-3, 1e-4
+	}
+
+	reading := &Reading{
+		Product:       model,
+		Voltage:       float64(u16(2)) * voltageScale,
+		Current:       float64(u16(4)) * currentScale,
+		Power:         float64(u32(6)) * 1e-3,
+		Temperature:   float64(u16(10)),
+		DPlusVoltage:  float64(u16(96)) * 1e-2,
+		DMinusVoltage: float64(u16(98)) * 1e-2,
+		Resistance:    float64(u32(122)) * 1e-1,
+	}
+
+	um := &UMData{
+		ActiveGroup:   int(u16(14)),
+		Groups:        make([]DataGroup, umNumGroups),
+		ChargingMode:  umChargingModes[0],
+		TemperatureF:  float64(u16(12)),
+		ScreenTimeout: int(u16(118)),
+		Backlight:     int(u16(120)),
+		Screen:        int(u16(126)),
+		LoadTimer: LoadTimer{
+			MAh:       u32(102),
+			MWh:       u32(106),
+			Threshold: float64(u16(110)) * 1e-2,
+			Seconds:   u32(112),
+			Running:   u16(116) != 0,
+		},
+	}
+	for i := range um.Groups {
+		um.Groups[i] = DataGroup{MAh: u32(16 + i*8), MWh: u32(20 + i*8)}
+	}
+	if mode := int(u16(100)); mode < len(umChargingModes) {
+		um.ChargingMode = umChargingModes[mode]
+	}
+
+	reading.Group0MAh, reading.Group0MWh = um.Groups[0].MAh, um.Groups[0].MWh
+	reading.Group1MAh, reading.Group1MWh = um.Groups[1].MAh, um.Groups[1].MWh
+	reading.UM = um
+
+	return reading, nil
+}
+
+// UMMeter is a connection to an RDTech UM24C, UM25C or UM34C meter over its
+// USB or Bluetooth (RFCOMM) serial port. Like TC66C, its methods are safe to
+// call from any goroutine: commands are sent one at a time by a goroutine
+// owned by the meter, screen commands first
+type UMMeter struct {
+	port     serial.Port
+	portName string      // Serial port name, empty if created from an open port
+	Model    string      // Model told by the first frame
+	Retry    RetryPolicy // Recovery from lost or corrupted readings, set before sharing the meter
+
+	sched     *scheduler
+	closeOnce sync.Once
+	closeErr  error
+
+	stats statsCounter
+}
+
+// NewUMMeter creates a new UM-series meter connection
+func NewUMMeter(portName string) (*UMMeter, error) {
+	port, err := OpenUMPort(portName)
+	if err != nil {
+		return nil, err
+	}
+
+	um, err := NewUMMeterFromNamedPort(portName, port)
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+
+	return um, nil
+}
+
+// OpenUMPort opens a serial port with the settings used by UM-series meters
+func OpenUMPort(portName string) (serial.Port, error) {
+	return openPort(portName, 9600)
+}
+
+// NewUMMeterFromPort creates a UMMeter on an already open port, e.g. one
+// wrapped by NewTranscriptPort. The caller keeps ownership of the port if
+// an error is returned
+func NewUMMeterFromPort(port serial.Port) (*UMMeter, error) {
+	return NewUMMeterFromNamedPort("", port)
+}
+
+// NewUMMeterFromNamedPort is NewUMMeterFromPort for a port opened by name
+// and then wrapped, so PortName and Info still report it. It takes a
+// reading to tell the model
+func NewUMMeterFromNamedPort(portName string, port serial.Port) (*UMMeter, error) {
+	um := &UMMeter{
+		port:     port,
+		portName: portName,
+		Retry:    DefaultRetryPolicy(),
+		sched:    newScheduler(),
+	}
+
+	reading, err := um.GetReading()
+	if err != nil {
+		um.detach()
+		return nil, fmt.Errorf("failed to identify UM meter: %w", err)
+	}
+	um.Model = reading.Product
+	um.stats.update(func(s *Stats) { *s = Stats{} })
+
+	return um, nil
+}
+
+// detach stops the meter goroutine without closing the port, for
+// constructors handing the port back to the caller
+func (um *UMMeter) detach() {
+	um.sched.stop()
+	um.sched.wait()
+}
+
+// PortName returns the serial port name, or an empty string if the meter
+// was created from an already open port
+func (um *UMMeter) PortName() string {
+	return um.portName
+}
+
+// Close closes the serial port connection. Queued commands fail with
+// ErrClosed. Close can be called more than once
+func (um *UMMeter) Close() error {
+	um.closeOnce.Do(func() {
+		um.sched.stop()
+		if um.port != nil {
+			um.closeErr = um.port.Close()
+		}
+		um.sched.wait()
+	})
+	return um.closeErr
+}
+
+// Capabilities returns what the meter can do
+func (um *UMMeter) Capabilities() Capabilities {
+	return Capabilities{
+		Model:        um.Model,
+		PreviousPage: um.Model != ModelUM24C,
+		DataGroups:   umNumGroups,
+		LoadTimer:    true,
+	}
+}
+
+// Info identifies the meter. UM-series meters report neither a firmware
+// version nor a serial number
+func (um *UMMeter) Info() (*DeviceInfo, error) {
+	info := &DeviceInfo{
+		Port:    um.portName,
+		Mode:    ModeFirmware.String(),
+		Product: um.Model,
+	}
+
+	// Bluetooth (RFCOMM) ports have no USB identity
+	if um.portName != "" {
+		if usb, err := LookupUSB(um.portName); err == nil {
+			info.USB = usb
+		}
+	}
+
+	if _, err := um.GetReading(); err != nil {
+		return nil, fmt.Errorf("failed to read from meter: %w", err)
+	}
+	return info, nil
+}
+
+// Stats returns the reading counters of this session
+func (um *UMMeter) Stats() Stats {
+	return um.stats.get()
+}
+
+// GetReading requests a data frame and returns it parsed. Lost and
+// corrupted frames are recovered or retried according to um.Retry
+func (um *UMMeter) GetReading() (*Reading, error) {
+	return retryReading(um.Retry, &um.stats, func() (*Reading, error) {
+		return schedule(um.sched, priorityNormal, um.readFrame)
+	})
+}
+
+// GetRecordings fails with ErrUnsupported: UM-series meters keep data
+// groups, which come with every reading, rather than stored readings
+func (um *UMMeter) GetRecordings() ([]*RecordingEntry, error) {
+	return nil, fmt.Errorf("%s has no stored recordings: %w", um.Model, ErrUnsupported)
+}
+
+// NextPage shows the next screen
+func (um *UMMeter) NextPage() error {
+	return um.screenCommand(UMCmdNextPage)
+}
+
+// PreviousPage shows the previous screen. The UM24C cannot go back
+func (um *UMMeter) PreviousPage() error {
+	if um.Model == ModelUM24C {
+		return fmt.Errorf("%s cannot go to the previous page: %w", um.Model, ErrUnsupported)
+	}
+	return um.screenCommand(UMCmdPrevPage)
+}
+
+// RotateScreen rotates the screen
+func (um *UMMeter) RotateScreen() error {
+	return um.screenCommand(UMCmdRotate)
+}
+
+// screenCommand sends a screen command ahead of any queued reading
+func (um *UMMeter) screenCommand(cmd byte) error {
+	return um.sched.run(priorityUser, func() error {
+		return um.sendCommand(cmd)
+	})
+}
+
+// sendCommand flushes anything left unread and sends a command. Runs on
+// the meter goroutine
+func (um *UMMeter) sendCommand(cmd byte) error {
+	um.port.ResetInputBuffer()
+	if _, err := um.port.Write([]byte{cmd}); err != nil {
+		return portError("failed to write command", err)
+	}
+	return nil
+}
+
+// readFrame requests a data frame and parses it, resynchronising on it if
+// stray bytes shifted it. Runs on the meter goroutine
+func (um *UMMeter) readFrame() (*Reading, error) {
+	if err := um.sendCommand(UMCmdGetData); err != nil {
+		return nil, err
+	}
+
+	frame, err := readFull(um.port, UMFrameSize)
+	if errors.Is(err, ErrTimeout) {
+		um.stats.update(func(s *Stats) { s.ShortReads++ })
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reading, err := ParseUMFrame(frame)
+	if err == nil {
+		return reading, nil
+	}
+	um.stats.update(func(s *Stats) { s.CRCErrors++ })
+
+	if um.Retry.Resync {
+		if reading, ok := um.resync(frame); ok {
+			um.stats.update(func(s *Stats) { s.Resyncs++ })
+			return reading, nil
+		}
+	}
+	return nil, err
+}
+
+// resync looks for a whole frame further into the stream, for when stray
+// bytes before the response shifted it. The bytes of the frame still in
+// flight are read first
+func (um *UMMeter) resync(frame []byte) (*Reading, bool) {
+	data := append(append([]byte(nil), frame...), um.drain(50*time.Millisecond)...)
+
+	for offset := 1; offset+UMFrameSize <= len(data); offset++ {
+		if reading, err := ParseUMFrame(data[offset : offset+UMFrameSize]); err == nil {
+			return reading, true
+		}
+	}
+	return nil, false
+}
+
+// drain reads and returns whatever the meter sends until it has been
+// quiet for timeout
+func (um *UMMeter) drain(timeout time.Duration) []byte {
+	um.port.SetReadTimeout(timeout)
+	defer um.port.SetReadTimeout(responseTimeout)
+
+	var data []byte
+	buf := make([]byte, 256)
+	for {
+		n, _ := um.port.Read(buf)
+		if n == 0 {
+			break
+		}
+		data = append(data, buf[:n]...)
+	}
+	return data
+}
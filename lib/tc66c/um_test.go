@@ -0,0 +1,315 @@
+package tc66c
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// umStep expects a single byte UM command and answers with reply
+func umStep(cmd byte, reply ...[]byte) fakeStep {
+	return fakeStep{expect: []byte{cmd}, reply: reply}
+}
+
+// buildUMFrame encodes a data frame as the given model sends it. Voltage
+// and current are given in the units of the model's frame
+func buildUMFrame(model string, rawVoltage, rawCurrent uint16) []byte {
+	frame := make([]byte, UMFrameSize)
+	put16 := func(offset int, v uint16) { binary.BigEndian.PutUint16(frame[offset:], v) }
+	put32 := func(offset int, v uint32) { binary.BigEndian.PutUint32(frame[offset:], v) }
+
+	for marker, m := range umStartMarkers {
+		if m == model {
+			put16(0, marker)
+		}
+	}
+	put16(2, rawVoltage)
+	put16(4, rawCurrent)
+	put32(6, 6325) // 6.325 W
+	put16(10, 27)  // °C
+	put16(12, 80)  // °F
+	put16(14, 3)   // Active group
+	for i := range umNumGroups {
+		put32(16+i*8, uint32(100*(i+1)))
+		put32(20+i*8, uint32(500*(i+1)))
+	}
+	put16(96, 60)    // D+ 0.60 V
+	put16(98, 59)    // D- 0.59 V
+	put16(100, 2)    // QC3.0
+	put32(102, 1234) // Load timer mAh
+	put32(106, 6170) // Load timer mWh
+	put16(110, 10)   // Threshold 0.10 A
+	put32(112, 3723) // 1h2m3s
+	put16(116, 1)    // Running
+	put16(118, 2)    // Screen timeout
+	put16(120, 5)    // Backlight
+	put32(122, 41)   // 4.1 Ω
+	put16(126, 1)    // Screen
+	put16(128, umEndMarker)
+	return frame
+}
+
+func TestParseUMFrame(t *testing.T) {
+	tests := []struct {
+		model       string
+		voltage     uint16
+		current     uint16
+		wantVoltage float64
+		wantCurrent float64
+	}{
+		{model: ModelUM24C, voltage: 512, current: 1234, wantVoltage: 5.12, wantCurrent: 1.234},
+		{model: ModelUM25C, voltage: 5123, current: 12345, wantVoltage: 5.123, wantCurrent: 1.2345},
+		{model: ModelUM34C, voltage: 2003, current: 3000, wantVoltage: 20.03, wantCurrent: 3.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			reading, err := ParseUMFrame(buildUMFrame(tt.model, tt.voltage, tt.current))
+			if err != nil {
+				t.Fatalf("ParseUMFrame: %v", err)
+			}
+
+			near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+			if reading.Product != tt.model || !near(reading.Voltage, tt.wantVoltage) || !near(reading.Current, tt.wantCurrent) {
+				t.Errorf("got %s %vV %vA, want %s %vV %vA", reading.Product, reading.Voltage, reading.Current, tt.model, tt.wantVoltage, tt.wantCurrent)
+			}
+			if !near(reading.Power, 6.325) || !near(reading.Resistance, 4.1) || reading.Temperature != 27 ||
+				!near(reading.DPlusVoltage, 0.6) || !near(reading.DMinusVoltage, 0.59) {
+				t.Errorf("common fields = %+v", reading)
+			}
+			if reading.Group0MAh != 100 || reading.Group0MWh != 500 || reading.Group1MAh != 200 || reading.Group1MWh != 1000 {
+				t.Errorf("groups 0 and 1 = %d/%d %d/%d", reading.Group0MAh, reading.Group0MWh, reading.Group1MAh, reading.Group1MWh)
+			}
+
+			um := reading.UM
+			if um == nil {
+				t.Fatal("UM fields missing")
+			}
+			if len(um.Groups) != 10 || um.Groups[9] != (DataGroup{MAh: 1000, MWh: 5000}) || um.ActiveGroup != 3 {
+				t.Errorf("groups = %+v, active %d", um.Groups, um.ActiveGroup)
+			}
+			if um.ChargingMode != "QC3.0" || um.TemperatureF != 80 || um.ScreenTimeout != 2 || um.Backlight != 5 || um.Screen != 1 {
+				t.Errorf("UM fields = %+v", um)
+			}
+			want := LoadTimer{Threshold: 0.1, MAh: 1234, MWh: 6170, Seconds: 3723, Running: true}
+			if um.LoadTimer != want {
+				t.Errorf("load timer = %+v, want %+v", um.LoadTimer, want)
+			}
+		})
+	}
+}
+
+func TestParseUMFrameErrors(t *testing.T) {
+	badStart := buildUMFrame(ModelUM25C, 5000, 1000)
+	badStart[0] = 0x12
+	badEnd := buildUMFrame(ModelUM25C, 5000, 1000)
+	badEnd[UMFrameSize-1] = 0x00
+
+	tests := []struct {
+		name  string
+		frame []byte
+		want  string
+	}{
+		{name: "size", frame: make([]byte, 100), want: "invalid frame size"},
+		{name: "start marker", frame: badStart, want: "unknown UM frame start marker 0x12c9"},
+		{name: "end marker", frame: badEnd, want: "invalid UM frame end marker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseUMFrame(tt.frame)
+			if !errors.Is(err, ErrBadPacket) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want an ErrBadPacket containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUMMeter(t *testing.T) {
+	frame := buildUMFrame(ModelUM25C, 5123, 12345)
+	fp := newFakePort(t,
+		umStep(UMCmdGetData, split(frame, 20)...),
+		umStep(UMCmdGetData, frame),
+		umStep(UMCmdNextPage),
+		umStep(UMCmdPrevPage),
+		umStep(UMCmdRotate),
+	)
+
+	um, err := NewUMMeterFromPort(fp)
+	if err != nil {
+		t.Fatalf("NewUMMeterFromPort: %v", err)
+	}
+	defer um.Close()
+
+	if um.Model != ModelUM25C {
+		t.Errorf("Model = %q, want %q", um.Model, ModelUM25C)
+	}
+	if caps := um.Capabilities(); caps.Recordings || !caps.PreviousPage || caps.DataGroups != 10 || !caps.LoadTimer {
+		t.Errorf("Capabilities = %+v", caps)
+	}
+
+	reading, err := um.GetReading()
+	if err != nil {
+		t.Fatalf("GetReading: %v", err)
+	}
+	if reading.UM == nil || reading.UM.ChargingMode != "QC3.0" {
+		t.Errorf("reading = %+v", reading)
+	}
+	if stats := um.Stats(); stats.Readings != 1 {
+		t.Errorf("Stats = %+v, want the identifying reading left out", stats)
+	}
+
+	for _, command := range []func() error{um.NextPage, um.PreviousPage, um.RotateScreen} {
+		if err := command(); err != nil {
+			t.Errorf("screen command: %v", err)
+		}
+	}
+
+	if _, err := um.GetRecordings(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("GetRecordings error = %v, want ErrUnsupported", err)
+	}
+	fp.done()
+}
+
+func TestUMMeterUM24CHasNoPreviousPage(t *testing.T) {
+	fp := newFakePort(t, umStep(UMCmdGetData, buildUMFrame(ModelUM24C, 500, 1000)))
+	um, err := NewUMMeterFromPort(fp)
+	if err != nil {
+		t.Fatalf("NewUMMeterFromPort: %v", err)
+	}
+	defer um.Close()
+
+	if um.Capabilities().PreviousPage {
+		t.Error("UM24C claims to go to the previous page")
+	}
+	// Nothing is written to the port
+	if err := um.PreviousPage(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("PreviousPage error = %v, want ErrUnsupported", err)
+	}
+	fp.done()
+}
+
+func TestUMMeterRecovery(t *testing.T) {
+	frame := buildUMFrame(ModelUM34C, 2003, 3000)
+	shifted := append([]byte{0x00, 0xf1, 0x09}, frame...)
+
+	fp := newFakePort(t,
+		umStep(UMCmdGetData, frame),
+		// Stray bytes shift the frame, which is found further in
+		umStep(UMCmdGetData, shifted),
+		// A frame cut short is requested again
+		umStep(UMCmdGetData, frame[:60]),
+		umStep(UMCmdGetData, frame),
+	)
+
+	um, err := NewUMMeterFromPort(fp)
+	if err != nil {
+		t.Fatalf("NewUMMeterFromPort: %v", err)
+	}
+	defer um.Close()
+	um.Retry.Backoff = 0
+
+	for i := range 2 {
+		if reading, err := um.GetReading(); err != nil || reading.Product != ModelUM34C {
+			t.Fatalf("reading %d = %+v, %v", i, reading, err)
+		}
+	}
+	fp.done()
+
+	want := Stats{Readings: 2, CRCErrors: 1, Resyncs: 1, ShortReads: 1, Retries: 1}
+	if got := um.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewUMMeterNoFrame(t *testing.T) {
+	fp := newFakePort(t,
+		umStep(UMCmdGetData),
+		umStep(UMCmdGetData),
+		umStep(UMCmdGetData),
+	)
+
+	_, err := NewUMMeterFromPort(fp)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("error = %v, want ErrTimeout", err)
+	}
+	if fp.closed {
+		t.Error("port closed, the caller owns it")
+	}
+}
+
+func TestNewMeterFromNamedPort(t *testing.T) {
+	t.Run("TC66C", func(t *testing.T) {
+		fp := newFakePort(t, cmdStep(CmdQuery, []byte("firm")))
+		meter, err := NewMeterFromNamedPort("/dev/ttyACM0", fp, "tc66c")
+		if err != nil {
+			t.Fatalf("NewMeterFromNamedPort: %v", err)
+		}
+		defer meter.Close()
+
+		if _, ok := meter.(*TC66C); !ok || meter.Capabilities().Model != ModelTC66C || meter.PortName() != "/dev/ttyACM0" {
+			t.Errorf("meter = %T %+v", meter, meter.Capabilities())
+		}
+	})
+
+	t.Run("any UM", func(t *testing.T) {
+		fp := newFakePort(t, umStep(UMCmdGetData, buildUMFrame(ModelUM34C, 500, 100)))
+		meter, err := NewMeterFromNamedPort("/dev/rfcomm0", fp, "um")
+		if err != nil {
+			t.Fatalf("NewMeterFromNamedPort: %v", err)
+		}
+		defer meter.Close()
+
+		if meter.Capabilities().Model != ModelUM34C {
+			t.Errorf("model = %s, want %s", meter.Capabilities().Model, ModelUM34C)
+		}
+	})
+
+	t.Run("wrong UM", func(t *testing.T) {
+		fp := newFakePort(t, umStep(UMCmdGetData, buildUMFrame(ModelUM34C, 500, 100)))
+		_, err := NewMeterFromNamedPort("/dev/rfcomm0", fp, "UM25C")
+		if err == nil || !strings.Contains(err.Error(), "found a UM34C, not a UM25C") {
+			t.Errorf("error = %v, want a model mismatch", err)
+		}
+		if fp.closed {
+			t.Error("port closed, the caller owns it")
+		}
+	})
+
+	t.Run("unknown model", func(t *testing.T) {
+		if _, err := NewMeterFromNamedPort("", newFakePort(t), "um99"); err == nil {
+			t.Error("unknown model accepted")
+		}
+	})
+}
+
+func TestUMReadingString(t *testing.T) {
+	reading, err := ParseUMFrame(buildUMFrame(ModelUM25C, 5123, 12345))
+	if err != nil {
+		t.Fatalf("ParseUMFrame: %v", err)
+	}
+
+	s := reading.String()
+	for _, want := range []string{
+		"Product: UM25C",
+		"Group 3: 400 mAh / 2000 mWh (active)",
+		"Group 9: 1000 mAh / 5000 mWh",
+		"Load timer: 1234 mAh / 6170 mWh over 1h2m3s above 0.10 A (running)",
+		"Charging mode: QC3.0",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("String() = %s\nwant it to contain %q", s, want)
+		}
+	}
+	if strings.Contains(s, "Serial") {
+		t.Errorf("String() = %s\nshows a serial number UM meters do not have", s)
+	}
+}
+
+// Both meter types satisfy Meter
+var (
+	_ Meter = (*TC66C)(nil)
+	_ Meter = (*UMMeter)(nil)
+)
@@ -0,0 +1,5 @@
+// Package tc66cpb contains the protobuf messages and gRPC service served by
+// `tc66c-toolkit grpc`, generated from proto/tc66c/v1/tc66c.proto
+package tc66cpb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/skgsergio/tc66-toolkit --go-grpc_out=../.. --go-grpc_opt=module=github.com/skgsergio/tc66-toolkit tc66c/v1/tc66c.proto
@@ -0,0 +1,1408 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: tc66c/v1/tc66c.proto
+
+package tc66cpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	fieldmaskpb "google.golang.org/protobuf/types/known/fieldmaskpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ScreenControlRequest_Action int32
+
+const (
+	ScreenControlRequest_ACTION_UNSPECIFIED ScreenControlRequest_Action = 0
+	ScreenControlRequest_ACTION_NEXT        ScreenControlRequest_Action = 1
+	ScreenControlRequest_ACTION_PREV        ScreenControlRequest_Action = 2
+	ScreenControlRequest_ACTION_ROTATE      ScreenControlRequest_Action = 3
+)
+
+// Enum value maps for ScreenControlRequest_Action.
+var (
+	ScreenControlRequest_Action_name = map[int32]string{
+		0: "ACTION_UNSPECIFIED",
+		1: "ACTION_NEXT",
+		2: "ACTION_PREV",
+		3: "ACTION_ROTATE",
+	}
+	ScreenControlRequest_Action_value = map[string]int32{
+		"ACTION_UNSPECIFIED": 0,
+		"ACTION_NEXT":        1,
+		"ACTION_PREV":        2,
+		"ACTION_ROTATE":      3,
+	}
+)
+
+func (x ScreenControlRequest_Action) Enum() *ScreenControlRequest_Action {
+	p := new(ScreenControlRequest_Action)
+	*p = x
+	return p
+}
+
+func (x ScreenControlRequest_Action) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ScreenControlRequest_Action) Descriptor() protoreflect.EnumDescriptor {
+	return file_tc66c_v1_tc66c_proto_enumTypes[0].Descriptor()
+}
+
+func (ScreenControlRequest_Action) Type() protoreflect.EnumType {
+	return &file_tc66c_v1_tc66c_proto_enumTypes[0]
+}
+
+func (x ScreenControlRequest_Action) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ScreenControlRequest_Action.Descriptor instead.
+func (ScreenControlRequest_Action) EnumDescriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{13, 0}
+}
+
+type ListDevicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesRequest) Reset() {
+	*x = ListDevicesRequest{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesRequest) ProtoMessage() {}
+
+func (x *ListDevicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesRequest.ProtoReflect.Descriptor instead.
+func (*ListDevicesRequest) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{0}
+}
+
+type ListDevicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Devices       []*Device              `protobuf:"bytes,1,rep,name=devices,proto3" json:"devices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesResponse) Reset() {
+	*x = ListDevicesResponse{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesResponse) ProtoMessage() {}
+
+func (x *ListDevicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesResponse.ProtoReflect.Descriptor instead.
+func (*ListDevicesResponse) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListDevicesResponse) GetDevices() []*Device {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+// Device is a serial port as reported by the OS.
+type Device struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Base name of the port, e.g. ttyACM0 or COM3.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Full port name, e.g. /dev/ttyACM0.
+	Name            string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	IsUsb           bool   `protobuf:"varint,3,opt,name=is_usb,json=isUsb,proto3" json:"is_usb,omitempty"`
+	Vid             string `protobuf:"bytes,4,opt,name=vid,proto3" json:"vid,omitempty"`
+	Pid             string `protobuf:"bytes,5,opt,name=pid,proto3" json:"pid,omitempty"`
+	UsbSerialNumber string `protobuf:"bytes,6,opt,name=usb_serial_number,json=usbSerialNumber,proto3" json:"usb_serial_number,omitempty"`
+	// True if the server currently holds the port open.
+	Active        bool `protobuf:"varint,7,opt,name=active,proto3" json:"active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Device) Reset() {
+	*x = Device{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Device) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Device) ProtoMessage() {}
+
+func (x *Device) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Device.ProtoReflect.Descriptor instead.
+func (*Device) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Device) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Device) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Device) GetIsUsb() bool {
+	if x != nil {
+		return x.IsUsb
+	}
+	return false
+}
+
+func (x *Device) GetVid() string {
+	if x != nil {
+		return x.Vid
+	}
+	return ""
+}
+
+func (x *Device) GetPid() string {
+	if x != nil {
+		return x.Pid
+	}
+	return ""
+}
+
+func (x *Device) GetUsbSerialNumber() string {
+	if x != nil {
+		return x.UsbSerialNumber
+	}
+	return ""
+}
+
+func (x *Device) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+type GetReadingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReadingRequest) Reset() {
+	*x = GetReadingRequest{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReadingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReadingRequest) ProtoMessage() {}
+
+func (x *GetReadingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReadingRequest.ProtoReflect.Descriptor instead.
+func (*GetReadingRequest) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetReadingRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+// Reading mirrors tc66c.Reading.
+type Reading struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Product      string                 `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Version      string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	SerialNumber uint32                 `protobuf:"varint,3,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	NumRuns      uint32                 `protobuf:"varint,4,opt,name=num_runs,json=numRuns,proto3" json:"num_runs,omitempty"`
+	// Volts.
+	Voltage float64 `protobuf:"fixed64,5,opt,name=voltage,proto3" json:"voltage,omitempty"`
+	// Amperes.
+	Current float64 `protobuf:"fixed64,6,opt,name=current,proto3" json:"current,omitempty"`
+	// Watts.
+	Power float64 `protobuf:"fixed64,7,opt,name=power,proto3" json:"power,omitempty"`
+	// Ohms.
+	Resistance float64 `protobuf:"fixed64,8,opt,name=resistance,proto3" json:"resistance,omitempty"`
+	Group0Mah  uint32  `protobuf:"varint,9,opt,name=group0_mah,json=group0Mah,proto3" json:"group0_mah,omitempty"`
+	Group0Mwh  uint32  `protobuf:"varint,10,opt,name=group0_mwh,json=group0Mwh,proto3" json:"group0_mwh,omitempty"`
+	Group1Mah  uint32  `protobuf:"varint,11,opt,name=group1_mah,json=group1Mah,proto3" json:"group1_mah,omitempty"`
+	Group1Mwh  uint32  `protobuf:"varint,12,opt,name=group1_mwh,json=group1Mwh,proto3" json:"group1_mwh,omitempty"`
+	// 0 = positive, 1 = negative.
+	TemperatureSign uint32 `protobuf:"varint,13,opt,name=temperature_sign,json=temperatureSign,proto3" json:"temperature_sign,omitempty"`
+	// Degrees Celsius.
+	Temperature float64 `protobuf:"fixed64,14,opt,name=temperature,proto3" json:"temperature,omitempty"`
+	// Volts.
+	DplusVoltage float64 `protobuf:"fixed64,15,opt,name=dplus_voltage,json=dplusVoltage,proto3" json:"dplus_voltage,omitempty"`
+	// Volts.
+	DminusVoltage float64 `protobuf:"fixed64,16,opt,name=dminus_voltage,json=dminusVoltage,proto3" json:"dminus_voltage,omitempty"`
+	// Set for UM-series meters only.
+	Um *UMData `protobuf:"bytes,17,opt,name=um,proto3" json:"um,omitempty"`
+	// True if no packet layout is known for the firmware version, so the
+	// reading was decoded with the layout of the newest known firmware.
+	LayoutFallback bool `protobuf:"varint,18,opt,name=layout_fallback,json=layoutFallback,proto3" json:"layout_fallback,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Reading) Reset() {
+	*x = Reading{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reading) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reading) ProtoMessage() {}
+
+func (x *Reading) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reading.ProtoReflect.Descriptor instead.
+func (*Reading) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Reading) GetProduct() string {
+	if x != nil {
+		return x.Product
+	}
+	return ""
+}
+
+func (x *Reading) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Reading) GetSerialNumber() uint32 {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return 0
+}
+
+func (x *Reading) GetNumRuns() uint32 {
+	if x != nil {
+		return x.NumRuns
+	}
+	return 0
+}
+
+func (x *Reading) GetVoltage() float64 {
+	if x != nil {
+		return x.Voltage
+	}
+	return 0
+}
+
+func (x *Reading) GetCurrent() float64 {
+	if x != nil {
+		return x.Current
+	}
+	return 0
+}
+
+func (x *Reading) GetPower() float64 {
+	if x != nil {
+		return x.Power
+	}
+	return 0
+}
+
+func (x *Reading) GetResistance() float64 {
+	if x != nil {
+		return x.Resistance
+	}
+	return 0
+}
+
+func (x *Reading) GetGroup0Mah() uint32 {
+	if x != nil {
+		return x.Group0Mah
+	}
+	return 0
+}
+
+func (x *Reading) GetGroup0Mwh() uint32 {
+	if x != nil {
+		return x.Group0Mwh
+	}
+	return 0
+}
+
+func (x *Reading) GetGroup1Mah() uint32 {
+	if x != nil {
+		return x.Group1Mah
+	}
+	return 0
+}
+
+func (x *Reading) GetGroup1Mwh() uint32 {
+	if x != nil {
+		return x.Group1Mwh
+	}
+	return 0
+}
+
+func (x *Reading) GetTemperatureSign() uint32 {
+	if x != nil {
+		return x.TemperatureSign
+	}
+	return 0
+}
+
+func (x *Reading) GetTemperature() float64 {
+	if x != nil {
+		return x.Temperature
+	}
+	return 0
+}
+
+func (x *Reading) GetDplusVoltage() float64 {
+	if x != nil {
+		return x.DplusVoltage
+	}
+	return 0
+}
+
+func (x *Reading) GetDminusVoltage() float64 {
+	if x != nil {
+		return x.DminusVoltage
+	}
+	return 0
+}
+
+func (x *Reading) GetUm() *UMData {
+	if x != nil {
+		return x.Um
+	}
+	return nil
+}
+
+func (x *Reading) GetLayoutFallback() bool {
+	if x != nil {
+		return x.LayoutFallback
+	}
+	return false
+}
+
+// UMData mirrors tc66c.UMData, the fields only UM-series meters send.
+type UMData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Data group being counted, 0-9.
+	ActiveGroup int32 `protobuf:"varint,1,opt,name=active_group,json=activeGroup,proto3" json:"active_group,omitempty"`
+	// All 10 data groups.
+	Groups []*DataGroup `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`
+	// Detected from D+/D-, e.g. "QC2.0" or "DCP1.5A".
+	ChargingMode string     `protobuf:"bytes,3,opt,name=charging_mode,json=chargingMode,proto3" json:"charging_mode,omitempty"`
+	LoadTimer    *LoadTimer `protobuf:"bytes,4,opt,name=load_timer,json=loadTimer,proto3" json:"load_timer,omitempty"`
+	// Degrees Fahrenheit.
+	TemperatureF float64 `protobuf:"fixed64,5,opt,name=temperature_f,json=temperatureF,proto3" json:"temperature_f,omitempty"`
+	// Screen off after this many minutes, 0 for never.
+	ScreenTimeout int32 `protobuf:"varint,6,opt,name=screen_timeout,json=screenTimeout,proto3" json:"screen_timeout,omitempty"`
+	// Backlight level, 0-5.
+	Backlight int32 `protobuf:"varint,7,opt,name=backlight,proto3" json:"backlight,omitempty"`
+	// Page being shown.
+	Screen        int32 `protobuf:"varint,8,opt,name=screen,proto3" json:"screen,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UMData) Reset() {
+	*x = UMData{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UMData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UMData) ProtoMessage() {}
+
+func (x *UMData) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UMData.ProtoReflect.Descriptor instead.
+func (*UMData) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UMData) GetActiveGroup() int32 {
+	if x != nil {
+		return x.ActiveGroup
+	}
+	return 0
+}
+
+func (x *UMData) GetGroups() []*DataGroup {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *UMData) GetChargingMode() string {
+	if x != nil {
+		return x.ChargingMode
+	}
+	return ""
+}
+
+func (x *UMData) GetLoadTimer() *LoadTimer {
+	if x != nil {
+		return x.LoadTimer
+	}
+	return nil
+}
+
+func (x *UMData) GetTemperatureF() float64 {
+	if x != nil {
+		return x.TemperatureF
+	}
+	return 0
+}
+
+func (x *UMData) GetScreenTimeout() int32 {
+	if x != nil {
+		return x.ScreenTimeout
+	}
+	return 0
+}
+
+func (x *UMData) GetBacklight() int32 {
+	if x != nil {
+		return x.Backlight
+	}
+	return 0
+}
+
+func (x *UMData) GetScreen() int32 {
+	if x != nil {
+		return x.Screen
+	}
+	return 0
+}
+
+// DataGroup mirrors tc66c.DataGroup.
+type DataGroup struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mah           uint32                 `protobuf:"varint,1,opt,name=mah,proto3" json:"mah,omitempty"`
+	Mwh           uint32                 `protobuf:"varint,2,opt,name=mwh,proto3" json:"mwh,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DataGroup) Reset() {
+	*x = DataGroup{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DataGroup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataGroup) ProtoMessage() {}
+
+func (x *DataGroup) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataGroup.ProtoReflect.Descriptor instead.
+func (*DataGroup) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DataGroup) GetMah() uint32 {
+	if x != nil {
+		return x.Mah
+	}
+	return 0
+}
+
+func (x *DataGroup) GetMwh() uint32 {
+	if x != nil {
+		return x.Mwh
+	}
+	return 0
+}
+
+// LoadTimer mirrors tc66c.LoadTimer, the charge counted while the current
+// is above a threshold.
+type LoadTimer struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Amperes.
+	Threshold float64 `protobuf:"fixed64,1,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	Mah       uint32  `protobuf:"varint,2,opt,name=mah,proto3" json:"mah,omitempty"`
+	Mwh       uint32  `protobuf:"varint,3,opt,name=mwh,proto3" json:"mwh,omitempty"`
+	// Time spent above the threshold.
+	Seconds uint32 `protobuf:"varint,4,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	// True while the current is above the threshold.
+	Running       bool `protobuf:"varint,5,opt,name=running,proto3" json:"running,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadTimer) Reset() {
+	*x = LoadTimer{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadTimer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadTimer) ProtoMessage() {}
+
+func (x *LoadTimer) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadTimer.ProtoReflect.Descriptor instead.
+func (*LoadTimer) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *LoadTimer) GetThreshold() float64 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+func (x *LoadTimer) GetMah() uint32 {
+	if x != nil {
+		return x.Mah
+	}
+	return 0
+}
+
+func (x *LoadTimer) GetMwh() uint32 {
+	if x != nil {
+		return x.Mwh
+	}
+	return 0
+}
+
+func (x *LoadTimer) GetSeconds() uint32 {
+	if x != nil {
+		return x.Seconds
+	}
+	return 0
+}
+
+func (x *LoadTimer) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+type StreamReadingsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Device string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	// Interval between readings in milliseconds (default 500, minimum 10).
+	// Readings are never sent faster than the meter was measured to keep up
+	// with.
+	IntervalMs uint32 `protobuf:"varint,2,opt,name=interval_ms,json=intervalMs,proto3" json:"interval_ms,omitempty"`
+	// Reading fields to send, e.g. paths: ["voltage", "current"]. All fields
+	// are sent if empty.
+	Fields        *fieldmaskpb.FieldMask `protobuf:"bytes,3,opt,name=fields,proto3" json:"fields,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamReadingsRequest) Reset() {
+	*x = StreamReadingsRequest{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamReadingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamReadingsRequest) ProtoMessage() {}
+
+func (x *StreamReadingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamReadingsRequest.ProtoReflect.Descriptor instead.
+func (*StreamReadingsRequest) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamReadingsRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *StreamReadingsRequest) GetIntervalMs() uint32 {
+	if x != nil {
+		return x.IntervalMs
+	}
+	return 0
+}
+
+func (x *StreamReadingsRequest) GetFields() *fieldmaskpb.FieldMask {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type ReadingSample struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Time the server received the reading.
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Reading       *Reading               `protobuf:"bytes,2,opt,name=reading,proto3" json:"reading,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadingSample) Reset() {
+	*x = ReadingSample{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadingSample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadingSample) ProtoMessage() {}
+
+func (x *ReadingSample) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadingSample.ProtoReflect.Descriptor instead.
+func (*ReadingSample) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReadingSample) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *ReadingSample) GetReading() *Reading {
+	if x != nil {
+		return x.Reading
+	}
+	return nil
+}
+
+type GetRecordingsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRecordingsRequest) Reset() {
+	*x = GetRecordingsRequest{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRecordingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRecordingsRequest) ProtoMessage() {}
+
+func (x *GetRecordingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRecordingsRequest.ProtoReflect.Descriptor instead.
+func (*GetRecordingsRequest) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetRecordingsRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+type GetRecordingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*RecordingEntry      `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRecordingsResponse) Reset() {
+	*x = GetRecordingsResponse{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRecordingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRecordingsResponse) ProtoMessage() {}
+
+func (x *GetRecordingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRecordingsResponse.ProtoReflect.Descriptor instead.
+func (*GetRecordingsResponse) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetRecordingsResponse) GetEntries() []*RecordingEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+// RecordingEntry mirrors tc66c.RecordingEntry.
+type RecordingEntry struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Volts.
+	Voltage float64 `protobuf:"fixed64,1,opt,name=voltage,proto3" json:"voltage,omitempty"`
+	// Amperes.
+	Current       float64 `protobuf:"fixed64,2,opt,name=current,proto3" json:"current,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecordingEntry) Reset() {
+	*x = RecordingEntry{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordingEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordingEntry) ProtoMessage() {}
+
+func (x *RecordingEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordingEntry.ProtoReflect.Descriptor instead.
+func (*RecordingEntry) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RecordingEntry) GetVoltage() float64 {
+	if x != nil {
+		return x.Voltage
+	}
+	return 0
+}
+
+func (x *RecordingEntry) GetCurrent() float64 {
+	if x != nil {
+		return x.Current
+	}
+	return 0
+}
+
+type ScreenControlRequest struct {
+	state         protoimpl.MessageState      `protogen:"open.v1"`
+	Device        string                      `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Action        ScreenControlRequest_Action `protobuf:"varint,2,opt,name=action,proto3,enum=tc66c.v1.ScreenControlRequest_Action" json:"action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScreenControlRequest) Reset() {
+	*x = ScreenControlRequest{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScreenControlRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScreenControlRequest) ProtoMessage() {}
+
+func (x *ScreenControlRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScreenControlRequest.ProtoReflect.Descriptor instead.
+func (*ScreenControlRequest) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ScreenControlRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *ScreenControlRequest) GetAction() ScreenControlRequest_Action {
+	if x != nil {
+		return x.Action
+	}
+	return ScreenControlRequest_ACTION_UNSPECIFIED
+}
+
+type ScreenControlResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScreenControlResponse) Reset() {
+	*x = ScreenControlResponse{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScreenControlResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScreenControlResponse) ProtoMessage() {}
+
+func (x *ScreenControlResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScreenControlResponse.ProtoReflect.Descriptor instead.
+func (*ScreenControlResponse) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{14}
+}
+
+type UpdateFirmwareRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Device string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	// Raw firmware image (max 128 KiB).
+	Image []byte `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	// Flash images that fail validation or are not known-good.
+	Force         bool `protobuf:"varint,3,opt,name=force,proto3" json:"force,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateFirmwareRequest) Reset() {
+	*x = UpdateFirmwareRequest{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateFirmwareRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateFirmwareRequest) ProtoMessage() {}
+
+func (x *UpdateFirmwareRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateFirmwareRequest.ProtoReflect.Descriptor instead.
+func (*UpdateFirmwareRequest) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateFirmwareRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *UpdateFirmwareRequest) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *UpdateFirmwareRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+// FirmwareUpdateProgress mirrors tc66c.FirmwareUpdateProgress.
+type FirmwareUpdateProgress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BytesSent     uint32                 `protobuf:"varint,1,opt,name=bytes_sent,json=bytesSent,proto3" json:"bytes_sent,omitempty"`
+	TotalBytes    uint32                 `protobuf:"varint,2,opt,name=total_bytes,json=totalBytes,proto3" json:"total_bytes,omitempty"`
+	ChunksSent    uint32                 `protobuf:"varint,3,opt,name=chunks_sent,json=chunksSent,proto3" json:"chunks_sent,omitempty"`
+	TotalChunks   uint32                 `protobuf:"varint,4,opt,name=total_chunks,json=totalChunks,proto3" json:"total_chunks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FirmwareUpdateProgress) Reset() {
+	*x = FirmwareUpdateProgress{}
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FirmwareUpdateProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FirmwareUpdateProgress) ProtoMessage() {}
+
+func (x *FirmwareUpdateProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_tc66c_v1_tc66c_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FirmwareUpdateProgress.ProtoReflect.Descriptor instead.
+func (*FirmwareUpdateProgress) Descriptor() ([]byte, []int) {
+	return file_tc66c_v1_tc66c_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *FirmwareUpdateProgress) GetBytesSent() uint32 {
+	if x != nil {
+		return x.BytesSent
+	}
+	return 0
+}
+
+func (x *FirmwareUpdateProgress) GetTotalBytes() uint32 {
+	if x != nil {
+		return x.TotalBytes
+	}
+	return 0
+}
+
+func (x *FirmwareUpdateProgress) GetChunksSent() uint32 {
+	if x != nil {
+		return x.ChunksSent
+	}
+	return 0
+}
+
+func (x *FirmwareUpdateProgress) GetTotalChunks() uint32 {
+	if x != nil {
+		return x.TotalChunks
+	}
+	return 0
+}
+
+var File_tc66c_v1_tc66c_proto protoreflect.FileDescriptor
+
+const file_tc66c_v1_tc66c_proto_rawDesc = "" +
+	"\n" +
+	"\x14tc66c/v1/tc66c.proto\x12\btc66c.v1\x1a google/protobuf/field_mask.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x14\n" +
+	"\x12ListDevicesRequest\"A\n" +
+	"\x13ListDevicesResponse\x12*\n" +
+	"\adevices\x18\x01 \x03(\v2\x10.tc66c.v1.DeviceR\adevices\"\xab\x01\n" +
+	"\x06Device\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x15\n" +
+	"\x06is_usb\x18\x03 \x01(\bR\x05isUsb\x12\x10\n" +
+	"\x03vid\x18\x04 \x01(\tR\x03vid\x12\x10\n" +
+	"\x03pid\x18\x05 \x01(\tR\x03pid\x12*\n" +
+	"\x11usb_serial_number\x18\x06 \x01(\tR\x0fusbSerialNumber\x12\x16\n" +
+	"\x06active\x18\a \x01(\bR\x06active\"+\n" +
+	"\x11GetReadingRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\"\xc7\x04\n" +
+	"\aReading\x12\x18\n" +
+	"\aproduct\x18\x01 \x01(\tR\aproduct\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12#\n" +
+	"\rserial_number\x18\x03 \x01(\rR\fserialNumber\x12\x19\n" +
+	"\bnum_runs\x18\x04 \x01(\rR\anumRuns\x12\x18\n" +
+	"\avoltage\x18\x05 \x01(\x01R\avoltage\x12\x18\n" +
+	"\acurrent\x18\x06 \x01(\x01R\acurrent\x12\x14\n" +
+	"\x05power\x18\a \x01(\x01R\x05power\x12\x1e\n" +
+	"\n" +
+	"resistance\x18\b \x01(\x01R\n" +
+	"resistance\x12\x1d\n" +
+	"\n" +
+	"group0_mah\x18\t \x01(\rR\tgroup0Mah\x12\x1d\n" +
+	"\n" +
+	"group0_mwh\x18\n" +
+	" \x01(\rR\tgroup0Mwh\x12\x1d\n" +
+	"\n" +
+	"group1_mah\x18\v \x01(\rR\tgroup1Mah\x12\x1d\n" +
+	"\n" +
+	"group1_mwh\x18\f \x01(\rR\tgroup1Mwh\x12)\n" +
+	"\x10temperature_sign\x18\r \x01(\rR\x0ftemperatureSign\x12 \n" +
+	"\vtemperature\x18\x0e \x01(\x01R\vtemperature\x12#\n" +
+	"\rdplus_voltage\x18\x0f \x01(\x01R\fdplusVoltage\x12%\n" +
+	"\x0edminus_voltage\x18\x10 \x01(\x01R\rdminusVoltage\x12 \n" +
+	"\x02um\x18\x11 \x01(\v2\x10.tc66c.v1.UMDataR\x02um\x12'\n" +
+	"\x0flayout_fallback\x18\x12 \x01(\bR\x0elayoutFallback\"\xb3\x02\n" +
+	"\x06UMData\x12!\n" +
+	"\factive_group\x18\x01 \x01(\x05R\vactiveGroup\x12+\n" +
+	"\x06groups\x18\x02 \x03(\v2\x13.tc66c.v1.DataGroupR\x06groups\x12#\n" +
+	"\rcharging_mode\x18\x03 \x01(\tR\fchargingMode\x122\n" +
+	"\n" +
+	"load_timer\x18\x04 \x01(\v2\x13.tc66c.v1.LoadTimerR\tloadTimer\x12#\n" +
+	"\rtemperature_f\x18\x05 \x01(\x01R\ftemperatureF\x12%\n" +
+	"\x0escreen_timeout\x18\x06 \x01(\x05R\rscreenTimeout\x12\x1c\n" +
+	"\tbacklight\x18\a \x01(\x05R\tbacklight\x12\x16\n" +
+	"\x06screen\x18\b \x01(\x05R\x06screen\"/\n" +
+	"\tDataGroup\x12\x10\n" +
+	"\x03mah\x18\x01 \x01(\rR\x03mah\x12\x10\n" +
+	"\x03mwh\x18\x02 \x01(\rR\x03mwh\"\x81\x01\n" +
+	"\tLoadTimer\x12\x1c\n" +
+	"\tthreshold\x18\x01 \x01(\x01R\tthreshold\x12\x10\n" +
+	"\x03mah\x18\x02 \x01(\rR\x03mah\x12\x10\n" +
+	"\x03mwh\x18\x03 \x01(\rR\x03mwh\x12\x18\n" +
+	"\aseconds\x18\x04 \x01(\rR\aseconds\x12\x18\n" +
+	"\arunning\x18\x05 \x01(\bR\arunning\"\x84\x01\n" +
+	"\x15StreamReadingsRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x1f\n" +
+	"\vinterval_ms\x18\x02 \x01(\rR\n" +
+	"intervalMs\x122\n" +
+	"\x06fields\x18\x03 \x01(\v2\x1a.google.protobuf.FieldMaskR\x06fields\"v\n" +
+	"\rReadingSample\x128\n" +
+	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12+\n" +
+	"\areading\x18\x02 \x01(\v2\x11.tc66c.v1.ReadingR\areading\".\n" +
+	"\x14GetRecordingsRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\"K\n" +
+	"\x15GetRecordingsResponse\x122\n" +
+	"\aentries\x18\x01 \x03(\v2\x18.tc66c.v1.RecordingEntryR\aentries\"D\n" +
+	"\x0eRecordingEntry\x12\x18\n" +
+	"\avoltage\x18\x01 \x01(\x01R\avoltage\x12\x18\n" +
+	"\acurrent\x18\x02 \x01(\x01R\acurrent\"\xc4\x01\n" +
+	"\x14ScreenControlRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12=\n" +
+	"\x06action\x18\x02 \x01(\x0e2%.tc66c.v1.ScreenControlRequest.ActionR\x06action\"U\n" +
+	"\x06Action\x12\x16\n" +
+	"\x12ACTION_UNSPECIFIED\x10\x00\x12\x0f\n" +
+	"\vACTION_NEXT\x10\x01\x12\x0f\n" +
+	"\vACTION_PREV\x10\x02\x12\x11\n" +
+	"\rACTION_ROTATE\x10\x03\"\x17\n" +
+	"\x15ScreenControlResponse\"[\n" +
+	"\x15UpdateFirmwareRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\fR\x05image\x12\x14\n" +
+	"\x05force\x18\x03 \x01(\bR\x05force\"\x9c\x01\n" +
+	"\x16FirmwareUpdateProgress\x12\x1d\n" +
+	"\n" +
+	"bytes_sent\x18\x01 \x01(\rR\tbytesSent\x12\x1f\n" +
+	"\vtotal_bytes\x18\x02 \x01(\rR\n" +
+	"totalBytes\x12\x1f\n" +
+	"\vchunks_sent\x18\x03 \x01(\rR\n" +
+	"chunksSent\x12!\n" +
+	"\ftotal_chunks\x18\x04 \x01(\rR\vtotalChunks2\xda\x03\n" +
+	"\x05Meter\x12J\n" +
+	"\vListDevices\x12\x1c.tc66c.v1.ListDevicesRequest\x1a\x1d.tc66c.v1.ListDevicesResponse\x12<\n" +
+	"\n" +
+	"GetReading\x12\x1b.tc66c.v1.GetReadingRequest\x1a\x11.tc66c.v1.Reading\x12L\n" +
+	"\x0eStreamReadings\x12\x1f.tc66c.v1.StreamReadingsRequest\x1a\x17.tc66c.v1.ReadingSample0\x01\x12P\n" +
+	"\rGetRecordings\x12\x1e.tc66c.v1.GetRecordingsRequest\x1a\x1f.tc66c.v1.GetRecordingsResponse\x12P\n" +
+	"\rScreenControl\x12\x1e.tc66c.v1.ScreenControlRequest\x1a\x1f.tc66c.v1.ScreenControlResponse\x12U\n" +
+	"\x0eUpdateFirmware\x12\x1f.tc66c.v1.UpdateFirmwareRequest\x1a .tc66c.v1.FirmwareUpdateProgress0\x01B7Z5github.com/skgsergio/tc66-toolkit/lib/tc66cpb;tc66cpbb\x06proto3"
+
+var (
+	file_tc66c_v1_tc66c_proto_rawDescOnce sync.Once
+	file_tc66c_v1_tc66c_proto_rawDescData []byte
+)
+
+func file_tc66c_v1_tc66c_proto_rawDescGZIP() []byte {
+	file_tc66c_v1_tc66c_proto_rawDescOnce.Do(func() {
+		file_tc66c_v1_tc66c_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tc66c_v1_tc66c_proto_rawDesc), len(file_tc66c_v1_tc66c_proto_rawDesc)))
+	})
+	return file_tc66c_v1_tc66c_proto_rawDescData
+}
+
+var file_tc66c_v1_tc66c_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_tc66c_v1_tc66c_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_tc66c_v1_tc66c_proto_goTypes = []any{
+	(ScreenControlRequest_Action)(0), // 0: tc66c.v1.ScreenControlRequest.Action
+	(*ListDevicesRequest)(nil),       // 1: tc66c.v1.ListDevicesRequest
+	(*ListDevicesResponse)(nil),      // 2: tc66c.v1.ListDevicesResponse
+	(*Device)(nil),                   // 3: tc66c.v1.Device
+	(*GetReadingRequest)(nil),        // 4: tc66c.v1.GetReadingRequest
+	(*Reading)(nil),                  // 5: tc66c.v1.Reading
+	(*UMData)(nil),                   // 6: tc66c.v1.UMData
+	(*DataGroup)(nil),                // 7: tc66c.v1.DataGroup
+	(*LoadTimer)(nil),                // 8: tc66c.v1.LoadTimer
+	(*StreamReadingsRequest)(nil),    // 9: tc66c.v1.StreamReadingsRequest
+	(*ReadingSample)(nil),            // 10: tc66c.v1.ReadingSample
+	(*GetRecordingsRequest)(nil),     // 11: tc66c.v1.GetRecordingsRequest
+	(*GetRecordingsResponse)(nil),    // 12: tc66c.v1.GetRecordingsResponse
+	(*RecordingEntry)(nil),           // 13: tc66c.v1.RecordingEntry
+	(*ScreenControlRequest)(nil),     // 14: tc66c.v1.ScreenControlRequest
+	(*ScreenControlResponse)(nil),    // 15: tc66c.v1.ScreenControlResponse
+	(*UpdateFirmwareRequest)(nil),    // 16: tc66c.v1.UpdateFirmwareRequest
+	(*FirmwareUpdateProgress)(nil),   // 17: tc66c.v1.FirmwareUpdateProgress
+	(*fieldmaskpb.FieldMask)(nil),    // 18: google.protobuf.FieldMask
+	(*timestamppb.Timestamp)(nil),    // 19: google.protobuf.Timestamp
+}
+var file_tc66c_v1_tc66c_proto_depIdxs = []int32{
+	3,  // 0: tc66c.v1.ListDevicesResponse.devices:type_name -> tc66c.v1.Device
+	6,  // 1: tc66c.v1.Reading.um:type_name -> tc66c.v1.UMData
+	7,  // 2: tc66c.v1.UMData.groups:type_name -> tc66c.v1.DataGroup
+	8,  // 3: tc66c.v1.UMData.load_timer:type_name -> tc66c.v1.LoadTimer
+	18, // 4: tc66c.v1.StreamReadingsRequest.fields:type_name -> google.protobuf.FieldMask
+	19, // 5: tc66c.v1.ReadingSample.timestamp:type_name -> google.protobuf.Timestamp
+	5,  // 6: tc66c.v1.ReadingSample.reading:type_name -> tc66c.v1.Reading
+	13, // 7: tc66c.v1.GetRecordingsResponse.entries:type_name -> tc66c.v1.RecordingEntry
+	0,  // 8: tc66c.v1.ScreenControlRequest.action:type_name -> tc66c.v1.ScreenControlRequest.Action
+	1,  // 9: tc66c.v1.Meter.ListDevices:input_type -> tc66c.v1.ListDevicesRequest
+	4,  // 10: tc66c.v1.Meter.GetReading:input_type -> tc66c.v1.GetReadingRequest
+	9,  // 11: tc66c.v1.Meter.StreamReadings:input_type -> tc66c.v1.StreamReadingsRequest
+	11, // 12: tc66c.v1.Meter.GetRecordings:input_type -> tc66c.v1.GetRecordingsRequest
+	14, // 13: tc66c.v1.Meter.ScreenControl:input_type -> tc66c.v1.ScreenControlRequest
+	16, // 14: tc66c.v1.Meter.UpdateFirmware:input_type -> tc66c.v1.UpdateFirmwareRequest
+	2,  // 15: tc66c.v1.Meter.ListDevices:output_type -> tc66c.v1.ListDevicesResponse
+	5,  // 16: tc66c.v1.Meter.GetReading:output_type -> tc66c.v1.Reading
+	10, // 17: tc66c.v1.Meter.StreamReadings:output_type -> tc66c.v1.ReadingSample
+	12, // 18: tc66c.v1.Meter.GetRecordings:output_type -> tc66c.v1.GetRecordingsResponse
+	15, // 19: tc66c.v1.Meter.ScreenControl:output_type -> tc66c.v1.ScreenControlResponse
+	17, // 20: tc66c.v1.Meter.UpdateFirmware:output_type -> tc66c.v1.FirmwareUpdateProgress
+	15, // [15:21] is the sub-list for method output_type
+	9,  // [9:15] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_tc66c_v1_tc66c_proto_init() }
+func file_tc66c_v1_tc66c_proto_init() {
+	if File_tc66c_v1_tc66c_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tc66c_v1_tc66c_proto_rawDesc), len(file_tc66c_v1_tc66c_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tc66c_v1_tc66c_proto_goTypes,
+		DependencyIndexes: file_tc66c_v1_tc66c_proto_depIdxs,
+		EnumInfos:         file_tc66c_v1_tc66c_proto_enumTypes,
+		MessageInfos:      file_tc66c_v1_tc66c_proto_msgTypes,
+	}.Build()
+	File_tc66c_v1_tc66c_proto = out.File
+	file_tc66c_v1_tc66c_proto_goTypes = nil
+	file_tc66c_v1_tc66c_proto_depIdxs = nil
+}
@@ -0,0 +1,342 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tc66c/v1/tc66c.proto
+
+package tc66cpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Meter_ListDevices_FullMethodName    = "/tc66c.v1.Meter/ListDevices"
+	Meter_GetReading_FullMethodName     = "/tc66c.v1.Meter/GetReading"
+	Meter_StreamReadings_FullMethodName = "/tc66c.v1.Meter/StreamReadings"
+	Meter_GetRecordings_FullMethodName  = "/tc66c.v1.Meter/GetRecordings"
+	Meter_ScreenControl_FullMethodName  = "/tc66c.v1.Meter/ScreenControl"
+	Meter_UpdateFirmware_FullMethodName = "/tc66c.v1.Meter/UpdateFirmware"
+)
+
+// MeterClient is the client API for Meter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Meter gives typed access to the meters attached to the host running
+// `tc66c-toolkit grpc`, TC66C or UM-series as selected with --meter. Devices are addressed like everywhere else in the
+// toolkit: serial port path or base name, label:<name> or serial:<number>.
+type MeterClient interface {
+	// ListDevices lists the serial ports of the host.
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	// GetReading takes a single reading.
+	GetReading(ctx context.Context, in *GetReadingRequest, opts ...grpc.CallOption) (*Reading, error)
+	// StreamReadings streams readings until the client cancels. Connections
+	// are shared, so several streams can watch the same meter.
+	StreamReadings(ctx context.Context, in *StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReadingSample], error)
+	// GetRecordings retrieves the recordings stored on the meter.
+	GetRecordings(ctx context.Context, in *GetRecordingsRequest, opts ...grpc.CallOption) (*GetRecordingsResponse, error)
+	// ScreenControl switches pages or rotates the screen.
+	ScreenControl(ctx context.Context, in *ScreenControlRequest, opts ...grpc.CallOption) (*ScreenControlResponse, error)
+	// UpdateFirmware flashes a firmware image to a meter in bootloader mode,
+	// streaming progress. The stream ends after the last chunk is written.
+	UpdateFirmware(ctx context.Context, in *UpdateFirmwareRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FirmwareUpdateProgress], error)
+}
+
+type meterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMeterClient(cc grpc.ClientConnInterface) MeterClient {
+	return &meterClient{cc}
+}
+
+func (c *meterClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, Meter_ListDevices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterClient) GetReading(ctx context.Context, in *GetReadingRequest, opts ...grpc.CallOption) (*Reading, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Reading)
+	err := c.cc.Invoke(ctx, Meter_GetReading_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterClient) StreamReadings(ctx context.Context, in *StreamReadingsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReadingSample], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Meter_ServiceDesc.Streams[0], Meter_StreamReadings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamReadingsRequest, ReadingSample]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Meter_StreamReadingsClient = grpc.ServerStreamingClient[ReadingSample]
+
+func (c *meterClient) GetRecordings(ctx context.Context, in *GetRecordingsRequest, opts ...grpc.CallOption) (*GetRecordingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRecordingsResponse)
+	err := c.cc.Invoke(ctx, Meter_GetRecordings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterClient) ScreenControl(ctx context.Context, in *ScreenControlRequest, opts ...grpc.CallOption) (*ScreenControlResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ScreenControlResponse)
+	err := c.cc.Invoke(ctx, Meter_ScreenControl_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *meterClient) UpdateFirmware(ctx context.Context, in *UpdateFirmwareRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FirmwareUpdateProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Meter_ServiceDesc.Streams[1], Meter_UpdateFirmware_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UpdateFirmwareRequest, FirmwareUpdateProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Meter_UpdateFirmwareClient = grpc.ServerStreamingClient[FirmwareUpdateProgress]
+
+// MeterServer is the server API for Meter service.
+// All implementations must embed UnimplementedMeterServer
+// for forward compatibility.
+//
+// Meter gives typed access to the meters attached to the host running
+// `tc66c-toolkit grpc`, TC66C or UM-series as selected with --meter. Devices are addressed like everywhere else in the
+// toolkit: serial port path or base name, label:<name> or serial:<number>.
+type MeterServer interface {
+	// ListDevices lists the serial ports of the host.
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	// GetReading takes a single reading.
+	GetReading(context.Context, *GetReadingRequest) (*Reading, error)
+	// StreamReadings streams readings until the client cancels. Connections
+	// are shared, so several streams can watch the same meter.
+	StreamReadings(*StreamReadingsRequest, grpc.ServerStreamingServer[ReadingSample]) error
+	// GetRecordings retrieves the recordings stored on the meter.
+	GetRecordings(context.Context, *GetRecordingsRequest) (*GetRecordingsResponse, error)
+	// ScreenControl switches pages or rotates the screen.
+	ScreenControl(context.Context, *ScreenControlRequest) (*ScreenControlResponse, error)
+	// UpdateFirmware flashes a firmware image to a meter in bootloader mode,
+	// streaming progress. The stream ends after the last chunk is written.
+	UpdateFirmware(*UpdateFirmwareRequest, grpc.ServerStreamingServer[FirmwareUpdateProgress]) error
+	mustEmbedUnimplementedMeterServer()
+}
+
+// UnimplementedMeterServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMeterServer struct{}
+
+func (UnimplementedMeterServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedMeterServer) GetReading(context.Context, *GetReadingRequest) (*Reading, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReading not implemented")
+}
+func (UnimplementedMeterServer) StreamReadings(*StreamReadingsRequest, grpc.ServerStreamingServer[ReadingSample]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamReadings not implemented")
+}
+func (UnimplementedMeterServer) GetRecordings(context.Context, *GetRecordingsRequest) (*GetRecordingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecordings not implemented")
+}
+func (UnimplementedMeterServer) ScreenControl(context.Context, *ScreenControlRequest) (*ScreenControlResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScreenControl not implemented")
+}
+func (UnimplementedMeterServer) UpdateFirmware(*UpdateFirmwareRequest, grpc.ServerStreamingServer[FirmwareUpdateProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method UpdateFirmware not implemented")
+}
+func (UnimplementedMeterServer) mustEmbedUnimplementedMeterServer() {}
+func (UnimplementedMeterServer) testEmbeddedByValue()               {}
+
+// UnsafeMeterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MeterServer will
+// result in compilation errors.
+type UnsafeMeterServer interface {
+	mustEmbedUnimplementedMeterServer()
+}
+
+func RegisterMeterServer(s grpc.ServiceRegistrar, srv MeterServer) {
+	// If the following call pancis, it indicates UnimplementedMeterServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Meter_ServiceDesc, srv)
+}
+
+func _Meter_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Meter_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Meter_GetReading_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReadingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterServer).GetReading(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Meter_GetReading_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterServer).GetReading(ctx, req.(*GetReadingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Meter_StreamReadings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamReadingsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MeterServer).StreamReadings(m, &grpc.GenericServerStream[StreamReadingsRequest, ReadingSample]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Meter_StreamReadingsServer = grpc.ServerStreamingServer[ReadingSample]
+
+func _Meter_GetRecordings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecordingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterServer).GetRecordings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Meter_GetRecordings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterServer).GetRecordings(ctx, req.(*GetRecordingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Meter_ScreenControl_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScreenControlRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MeterServer).ScreenControl(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Meter_ScreenControl_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MeterServer).ScreenControl(ctx, req.(*ScreenControlRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Meter_UpdateFirmware_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UpdateFirmwareRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MeterServer).UpdateFirmware(m, &grpc.GenericServerStream[UpdateFirmwareRequest, FirmwareUpdateProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Meter_UpdateFirmwareServer = grpc.ServerStreamingServer[FirmwareUpdateProgress]
+
+// Meter_ServiceDesc is the grpc.ServiceDesc for Meter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Meter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tc66c.v1.Meter",
+	HandlerType: (*MeterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDevices",
+			Handler:    _Meter_ListDevices_Handler,
+		},
+		{
+			MethodName: "GetReading",
+			Handler:    _Meter_GetReading_Handler,
+		},
+		{
+			MethodName: "GetRecordings",
+			Handler:    _Meter_GetRecordings_Handler,
+		},
+		{
+			MethodName: "ScreenControl",
+			Handler:    _Meter_ScreenControl_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReadings",
+			Handler:       _Meter_StreamReadings_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "UpdateFirmware",
+			Handler:       _Meter_UpdateFirmware_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tc66c/v1/tc66c.proto",
+}